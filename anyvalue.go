@@ -0,0 +1,267 @@
+package tel
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"strconv"
+)
+
+// AnyKind is the kind of an AnyValue.
+type AnyKind int
+
+const (
+	// AnyKindEmpty is the kind of the zero AnyValue, which holds nothing.
+	AnyKindEmpty AnyKind = iota
+	// AnyKindValue is the kind of an AnyValue holding a Value.
+	AnyKindValue
+	// AnyKindBytes is the kind of an AnyValue holding a byte slice.
+	AnyKindBytes
+	// AnyKindMap is the kind of an AnyValue holding a list of key-values,
+	// whose values are themselves AnyValues.
+	AnyKindMap
+	// AnyKindArray is the kind of an AnyValue holding a list of
+	// AnyValues, not necessarily of the same kind.
+	AnyKindArray
+)
+
+// String returns the name of k.
+func (k AnyKind) String() string {
+	switch k {
+	case AnyKindEmpty:
+		return "EMPTY"
+	case AnyKindValue:
+		return "VALUE"
+	case AnyKindBytes:
+		return "BYTES"
+	case AnyKindMap:
+		return "MAP"
+	case AnyKindArray:
+		return "ARRAY"
+	}
+	return "AnyKind(" + strconv.Itoa(int(k)) + ")"
+}
+
+// AnyValue is a value of the log record model, as the AnyValue of OTLP: a
+// Value, or a byte slice, a map or an array of AnyValues. It is used for
+// the body and attributes of a LogRecord.
+//
+// Span and metric attributes are Values, which the OpenTelemetry SDK and
+// exporters handle, so they can't hold byte slices, maps or arrays of
+// mixed types: span exporters, such as those of Zipkin and Jaeger, and
+// attribute sets, and so DefaultEncoder, never see AnyValues. Use Flatten
+// to record such a value as a STRING Value instead, keeping it readable
+// but losing its structure.
+//
+// The log exporters of tel export AnyValues as follows: the OTLP exporters
+// and telfile as the matching OTLP AnyValues, without loss, and the stdout
+// exporter as JSON objects holding the kind and the value.
+type AnyValue struct {
+	kind  AnyKind
+	value Value
+	bytes []byte
+	kvs   []AnyKeyValue
+	vals  []AnyValue
+}
+
+// AnyKeyValue holds a key and an AnyValue.
+type AnyKeyValue struct {
+	Key   Key
+	Value AnyValue
+}
+
+// AnyOf returns an AnyValue holding v, or the empty AnyValue if v is
+// INVALID.
+func AnyOf(v Value) AnyValue {
+	if v.Type() == INVALID {
+		return AnyValue{}
+	}
+	return AnyValue{kind: AnyKindValue, value: v}
+}
+
+// AnyString returns an AnyValue holding a STRING Value.
+func AnyString(v string) AnyValue {
+	return AnyOf(StringValue(v))
+}
+
+// AnyBytes returns an AnyValue holding v.
+func AnyBytes(v []byte) AnyValue {
+	return AnyValue{kind: AnyKindBytes, bytes: v}
+}
+
+// AnyMap returns an AnyValue holding the key-values in the order given.
+func AnyMap(kvs ...AnyKeyValue) AnyValue {
+	return AnyValue{kind: AnyKindMap, kvs: kvs}
+}
+
+// AnyArray returns an AnyValue holding the values in the order given, which
+// may be of different kinds.
+func AnyArray(vals ...AnyValue) AnyValue {
+	return AnyValue{kind: AnyKindArray, vals: vals}
+}
+
+// AnyAttribute creates an AnyKeyValue.
+func AnyAttribute(k string, v AnyValue) AnyKeyValue {
+	return AnyKeyValue{Key: Key(k), Value: v}
+}
+
+// AnyAttributes converts kvs to AnyKeyValues holding their Values.
+func AnyAttributes(kvs ...KeyValue) []AnyKeyValue {
+	if kvs == nil {
+		return nil
+	}
+	akvs := make([]AnyKeyValue, len(kvs))
+	for i, kv := range kvs {
+		akvs[i] = AnyKeyValue{Key: kv.Key, Value: AnyOf(kv.Value)}
+	}
+	return akvs
+}
+
+// Kind returns the kind of v.
+func (v AnyValue) Kind() AnyKind {
+	return v.kind
+}
+
+// AsValue returns the Value held by v, or an INVALID Value for other kinds.
+func (v AnyValue) AsValue() Value {
+	return v.value
+}
+
+// AsBytes returns the byte slice held by v, or nil for other kinds.
+func (v AnyValue) AsBytes() []byte {
+	return v.bytes
+}
+
+// AsMap returns the key-values held by v, or nil for other kinds.
+func (v AnyValue) AsMap() []AnyKeyValue {
+	return v.kvs
+}
+
+// AsArray returns the values held by v, or nil for other kinds.
+func (v AnyValue) AsArray() []AnyValue {
+	return v.vals
+}
+
+// Emit returns v as a string: a Value as by its Emit method, a byte slice
+// in base64 and maps and arrays as JSON objects and arrays.
+func (v AnyValue) Emit() string {
+	switch v.kind {
+	case AnyKindValue:
+		return v.value.Emit()
+	case AnyKindBytes:
+		return base64.StdEncoding.EncodeToString(v.bytes)
+	case AnyKindMap, AnyKindArray:
+		return string(v.appendJSON(nil))
+	}
+	return ""
+}
+
+// Flatten returns the Value held by v, or a STRING Value holding the Emit
+// of other kinds, for backends that only support Values.
+func (v AnyValue) Flatten() Value {
+	switch v.kind {
+	case AnyKindValue:
+		return v.value
+	case AnyKindEmpty:
+		return Value{}
+	}
+	return StringValue(v.Emit())
+}
+
+// MarshalJSON returns the JSON encoding of v, an object holding its kind as
+// Type and its value as Value, as for a Value. The Type of an AnyValue
+// holding a Value is that of the Value.
+func (v AnyValue) MarshalJSON() ([]byte, error) {
+	var jsonVal struct {
+		Type  string
+		Value interface{}
+	}
+	switch v.kind {
+	case AnyKindBytes:
+		jsonVal.Value = v.bytes
+	case AnyKindMap:
+		jsonVal.Value = v.kvs
+	case AnyKindArray:
+		jsonVal.Value = v.vals
+	default:
+		return v.value.MarshalJSON()
+	}
+	jsonVal.Type = v.kind.String()
+	return json.Marshal(jsonVal)
+}
+
+// appendJSON appends v as plain JSON.
+func (v AnyValue) appendJSON(b []byte) []byte {
+	switch v.kind {
+	case AnyKindValue:
+		return appendValueJSON(b, v.value)
+	case AnyKindBytes:
+		return appendJSONString(b, v.Emit())
+	case AnyKindMap:
+		b = append(b, '{')
+		for i, kv := range v.kvs {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(appendJSONString(b, string(kv.Key)), ':')
+			b = kv.Value.appendJSON(b)
+		}
+		return append(b, '}')
+	case AnyKindArray:
+		b = append(b, '[')
+		for i, e := range v.vals {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = e.appendJSON(b)
+		}
+		return append(b, ']')
+	}
+	return append(b, "null"...)
+}
+
+// appendValueJSON appends v as plain JSON.
+func appendValueJSON(b []byte, v Value) []byte {
+	switch v.Type() {
+	case BOOL:
+		return strconv.AppendBool(b, v.AsBool())
+	case INT64:
+		return strconv.AppendInt(b, v.AsInt64(), 10)
+	case FLOAT64:
+		return appendJSONFloat(b, v.AsFloat64())
+	case STRING:
+		return appendJSONString(b, v.AsString())
+	case FLOAT64SLICE:
+		b = append(b, '[')
+		for i, f := range v.AsFloat64Slice() {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = appendJSONFloat(b, f)
+		}
+		return append(b, ']')
+	case BOOLSLICE, INT64SLICE, STRINGSLICE:
+		j, _ := json.Marshal(v.AsInterface())
+		return append(b, j...)
+	}
+	return append(b, "null"...)
+}
+
+func appendJSONString(b []byte, s string) []byte {
+	j, _ := json.Marshal(s)
+	return append(b, j...)
+}
+
+// appendJSONFloat appends f as a JSON number, or as the strings NaN,
+// Infinity and -Infinity, which JSON numbers can't represent.
+func appendJSONFloat(b []byte, f float64) []byte {
+	switch {
+	case math.IsNaN(f):
+		return append(b, `"NaN"`...)
+	case math.IsInf(f, 1):
+		return append(b, `"Infinity"`...)
+	case math.IsInf(f, -1):
+		return append(b, `"-Infinity"`...)
+	}
+	return strconv.AppendFloat(b, f, 'g', -1, 64)
+}
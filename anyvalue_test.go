@@ -0,0 +1,119 @@
+package tel
+
+import (
+	"encoding/json"
+	"math"
+	"testing"
+)
+
+func nested() AnyValue {
+	return AnyMap(
+		AnyAttribute("name", AnyString("a\"b")),
+		AnyAttribute("raw", AnyBytes([]byte("hi"))),
+		AnyAttribute("mixed", AnyArray(AnyOf(Int64Value(1)), AnyOf(BoolValue(true)), AnyArray())),
+		AnyAttribute("floats", AnyOf(Float64SliceValue([]float64{1.5, math.Inf(1)}))),
+		AnyAttribute("empty", AnyValue{}),
+	)
+}
+
+func TestAnyValueKinds(t *testing.T) {
+	tests := []struct {
+		v    AnyValue
+		kind AnyKind
+		name string
+	}{
+		{AnyValue{}, AnyKindEmpty, "EMPTY"},
+		{AnyOf(Value{}), AnyKindEmpty, "EMPTY"},
+		{AnyString("x"), AnyKindValue, "VALUE"},
+		{AnyBytes(nil), AnyKindBytes, "BYTES"},
+		{AnyMap(), AnyKindMap, "MAP"},
+		{AnyArray(), AnyKindArray, "ARRAY"},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Kind(); got != tt.kind || got.String() != tt.name {
+			t.Errorf("Kind() = %v, want %v", got, tt.name)
+		}
+	}
+	if got := AnyKind(42).String(); got != "AnyKind(42)" {
+		t.Errorf("String() = %q, want AnyKind(42)", got)
+	}
+}
+
+func TestAnyValueAccessors(t *testing.T) {
+	v := nested()
+	kvs := v.AsMap()
+	if len(kvs) != 5 || kvs[0].Key != "name" || kvs[0].Value.AsValue().AsString() != "a\"b" {
+		t.Fatalf("AsMap() = %v", kvs)
+	}
+	if got := string(kvs[1].Value.AsBytes()); got != "hi" {
+		t.Errorf("AsBytes() = %q, want hi", got)
+	}
+	if got := kvs[2].Value.AsArray(); len(got) != 3 || got[0].AsValue().AsInt64() != 1 {
+		t.Errorf("AsArray() = %v", got)
+	}
+	if v.AsValue().Type() != INVALID || v.AsBytes() != nil || v.AsArray() != nil {
+		t.Error("accessors of other kinds returned values for a MAP")
+	}
+}
+
+func TestAnyValueEmit(t *testing.T) {
+	tests := []struct {
+		v    AnyValue
+		want string
+	}{
+		{AnyValue{}, ""},
+		{AnyOf(Int64Value(3)), "3"},
+		{AnyBytes([]byte("hi")), "aGk="},
+		{nested(), `{"name":"a\"b","raw":"aGk=","mixed":[1,true,[]],"floats":[1.5,"Infinity"],"empty":null}`},
+	}
+	for _, tt := range tests {
+		if got := tt.v.Emit(); got != tt.want {
+			t.Errorf("Emit() = %s, want %s", got, tt.want)
+		}
+	}
+	if !json.Valid([]byte(nested().Emit())) {
+		t.Errorf("Emit() = %s, not valid JSON", nested().Emit())
+	}
+}
+
+func TestAnyValueFlatten(t *testing.T) {
+	if got := AnyOf(Int64Value(3)).Flatten(); got.Type() != INT64 || got.AsInt64() != 3 {
+		t.Errorf("Flatten() = %v, want the INT64 Value", got)
+	}
+	if got := (AnyValue{}).Flatten(); got.Type() != INVALID {
+		t.Errorf("Flatten() = %v, want an INVALID Value", got)
+	}
+	if got := AnyBytes([]byte("hi")).Flatten(); got.Type() != STRING || got.AsString() != "aGk=" {
+		t.Errorf("Flatten() = %v, want the STRING Value aGk=", got)
+	}
+}
+
+func TestAnyValueMarshalJSON(t *testing.T) {
+	v := AnyMap(
+		AnyAttribute("s", AnyString("x")),
+		AnyAttribute("b", AnyBytes([]byte("hi"))),
+		AnyAttribute("a", AnyArray(AnyOf(Int64Value(1)))),
+	)
+	b, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"Type":"MAP","Value":[` +
+		`{"Key":"s","Value":{"Type":"STRING","Value":"x"}},` +
+		`{"Key":"b","Value":{"Type":"BYTES","Value":"aGk="}},` +
+		`{"Key":"a","Value":{"Type":"ARRAY","Value":[{"Type":"INT64","Value":1}]}}]}`
+	if string(b) != want {
+		t.Errorf("Marshal() = %s, want %s", b, want)
+	}
+}
+
+func TestAnyAttributes(t *testing.T) {
+	if got := AnyAttributes(); got != nil {
+		t.Errorf("AnyAttributes() = %v, want nil", got)
+	}
+	got := AnyAttributes(AttributeString("a", "x"), AttributeInt("b", 2))
+	if len(got) != 2 || got[0].Key != "a" || got[0].Value.AsValue().AsString() != "x" ||
+		got[1].Key != "b" || got[1].Value.AsValue().AsInt64() != 2 {
+		t.Errorf("AnyAttributes() = %v", got)
+	}
+}
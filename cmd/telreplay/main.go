@@ -0,0 +1,392 @@
+// Command telreplay re-exports traces and metrics captured to files, such as
+// the ones written by export/telfile, through any of the exporters in
+// export/*.
+//
+// Capture files hold one ExportTraceServiceRequest or
+// ExportMetricsServiceRequest per line using the OTLP/JSON encoding, or
+// length-delimited protobuf messages. Both may be gzip compressed.
+//
+// Usage:
+//
+//	telreplay [flags] file...
+//
+// For example, to replay production traces against a local Jaeger as if
+// they had just happened, without clashing with an earlier replay:
+//
+//	telreplay -exporter jaeger -now -remap-ids -rate 10 traces.jsonl
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"time"
+
+	"github.com/henvic/tel/export/teljaeger"
+	"github.com/henvic/tel/export/telotlp"
+	telstdout "github.com/henvic/tel/export/telstdout"
+	telzipkin "github.com/henvic/tel/export/telzipkin"
+	"github.com/henvic/tel/internal/metricdata"
+	"github.com/henvic/tel/internal/otlpconv"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+const (
+	formatAuto  = "auto"
+	formatJSON  = "json"
+	formatProto = "proto"
+
+	signalAuto    = "auto"
+	signalTraces  = "traces"
+	signalMetrics = "metrics"
+
+	exporterStdout   = "stdout"
+	exporterOTLP     = "otlp"
+	exporterOTLPGRPC = "otlp-grpc"
+	exporterJaeger   = "jaeger"
+	exporterZipkin   = "zipkin"
+)
+
+type options struct {
+	format   string
+	signal   string
+	exporter string
+	endpoint string
+	insecure bool
+	headers  headers
+	shift    time.Duration
+	now      bool
+	remapIDs bool
+	rate     float64
+	timeout  time.Duration
+}
+
+// headers is a flag.Value accumulating key=value pairs.
+type headers map[string]string
+
+func (h headers) String() string {
+	var pairs []string
+	for k, v := range h {
+		pairs = append(pairs, k+"="+v)
+	}
+	return strings.Join(pairs, ",")
+}
+
+func (h headers) Set(s string) error {
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || k == "" {
+			return fmt.Errorf("invalid header %q, expected key=value", pair)
+		}
+		h[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "telreplay: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	opts := options{headers: headers{}}
+	fs := flag.NewFlagSet("telreplay", flag.ContinueOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "usage: telreplay [flags] file...\n\n")
+		fs.PrintDefaults()
+	}
+	fs.StringVar(&opts.format, "format", formatAuto, "input format: auto, json (OTLP/JSON lines) or proto (length-delimited protobuf); auto uses the file extension, .jsonl or .pb, or else tries decoding the first line as JSON")
+	fs.StringVar(&opts.signal, "signal", signalAuto, "signal in the files: auto, traces or metrics; auto assumes traces for protobuf input")
+	fs.StringVar(&opts.exporter, "exporter", exporterStdout, "exporter: stdout, otlp (HTTP), otlp-grpc, jaeger or zipkin")
+	fs.StringVar(&opts.endpoint, "endpoint", "", "exporter endpoint; host:port for OTLP and the Jaeger agent, a URL for the Jaeger collector and Zipkin")
+	fs.BoolVar(&opts.insecure, "insecure", false, "disable TLS for OTLP exporters")
+	fs.Var(opts.headers, "header", "key=value header sent by OTLP exporters; can be repeated")
+	fs.DurationVar(&opts.shift, "shift", 0, "shift all timestamps by this duration")
+	fs.BoolVar(&opts.now, "now", false, "shift timestamps so the first one read is the time replay started; -shift is applied on top")
+	fs.BoolVar(&opts.remapIDs, "remap-ids", false, "replace trace and span IDs with random ones, consistently across all files")
+	fs.Float64Var(&opts.rate, "rate", 0, "maximum batches exported per second; zero is unlimited")
+	fs.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout for exporting each batch")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errors.New("missing input files")
+	}
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	r := &replayer{opts: opts, rewriter: newRewriter(opts.shift, opts.now, opts.remapIDs)}
+	err := r.replay(ctx, fs.Args())
+	// Shut down even when interrupted, so exporters flush what they got.
+	sctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+	if serr := r.shutdown(sctx); err == nil {
+		err = serr
+	}
+	fmt.Fprintf(os.Stderr, "telreplay: exported %d span(s) and %d metric(s) in %d batch(es)\n", r.spans, r.metrics, r.batches)
+	return err
+}
+
+func (opts options) validate() error {
+	switch opts.format {
+	case formatAuto, formatJSON, formatProto:
+	default:
+		return fmt.Errorf("unknown format %q", opts.format)
+	}
+	switch opts.signal {
+	case signalAuto, signalTraces, signalMetrics:
+	default:
+		return fmt.Errorf("unknown signal %q", opts.signal)
+	}
+	switch opts.exporter {
+	case exporterStdout, exporterOTLP, exporterOTLPGRPC, exporterJaeger, exporterZipkin:
+	default:
+		return fmt.Errorf("unknown exporter %q", opts.exporter)
+	}
+	if opts.signal == signalMetrics && (opts.exporter == exporterJaeger || opts.exporter == exporterZipkin) {
+		return fmt.Errorf("exporter %s does not support metrics", opts.exporter)
+	}
+	if opts.rate < 0 {
+		return errors.New("rate must not be negative")
+	}
+	return nil
+}
+
+type replayer struct {
+	opts     options
+	rewriter *rewriter
+
+	spanExporter   telsdk.SpanExporter
+	metricExporter telsdk.Exporter
+
+	last    time.Time
+	batches int
+	spans   int
+	metrics int
+}
+
+func (r *replayer) replay(ctx context.Context, files []string) error {
+	for _, name := range files {
+		if err := r.replayFile(ctx, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *replayer) replayFile(ctx context.Context, name string) error {
+	br, err := openBatchReader(name, r.opts.format, r.opts.signal)
+	if err != nil {
+		return err
+	}
+	defer br.close()
+	for {
+		b, err := br.next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := r.wait(ctx); err != nil {
+			return err
+		}
+		r.rewriter.rewrite(b)
+		if err := r.export(ctx, b); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+}
+
+// wait blocks until the next batch can be exported without going over the
+// configured rate.
+func (r *replayer) wait(ctx context.Context) error {
+	if r.opts.rate == 0 {
+		return ctx.Err()
+	}
+	interval := time.Duration(float64(time.Second) / r.opts.rate)
+	if d := time.Until(r.last.Add(interval)); d > 0 {
+		t := time.NewTimer(d)
+		defer t.Stop()
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+	r.last = time.Now()
+	return ctx.Err()
+}
+
+func (r *replayer) export(ctx context.Context, b batch) error {
+	ctx, cancel := context.WithTimeout(ctx, r.opts.timeout)
+	defer cancel()
+	if b.traces != nil {
+		spans := otlpconv.SpanStubs(b.traces.ResourceSpans).Snapshots()
+		if len(spans) == 0 {
+			return nil
+		}
+		exp, err := r.spanExp(ctx)
+		if err != nil {
+			return err
+		}
+		if err := exp.ExportSpans(ctx, spans); err != nil {
+			return err
+		}
+		r.batches++
+		r.spans += len(spans)
+		return nil
+	}
+	if len(b.metrics.ResourceMetrics) == 0 {
+		return nil
+	}
+	exp, err := r.metricExp(ctx)
+	if err != nil {
+		return err
+	}
+	for _, rm := range b.metrics.ResourceMetrics {
+		res, libs, err := otlpconv.Metrics(rm)
+		if err != nil {
+			return err
+		}
+		if err := exp.Export(ctx, res, metricdata.NewInstrumentationLibraryReader(libs...)); err != nil {
+			return err
+		}
+		for _, lib := range libs {
+			r.metrics += len(lib.Records)
+		}
+	}
+	r.batches++
+	return nil
+}
+
+// spanExp returns the span exporter, creating it on first use.
+func (r *replayer) spanExp(ctx context.Context) (telsdk.SpanExporter, error) {
+	if r.spanExporter != nil {
+		return r.spanExporter, nil
+	}
+	var err error
+	switch r.opts.exporter {
+	case exporterStdout:
+		r.spanExporter, err = telstdout.NewStdoutTrace(telstdout.WithStdoutTracePrettyPrint())
+	case exporterOTLP:
+		hopts := []telotlp.TraceHTTPOption{telotlp.WithTraceHTTPHeaders(r.opts.headers)}
+		if r.opts.endpoint != "" {
+			hopts = append(hopts, telotlp.WithTraceHTTPEndpoint(r.opts.endpoint))
+		}
+		if r.opts.insecure {
+			hopts = append(hopts, telotlp.WithTraceHTTPInsecure())
+		}
+		r.spanExporter, err = telotlp.NewTraceHTTP(ctx, hopts...)
+	case exporterOTLPGRPC:
+		gopts := []telotlp.TraceGRPCOption{telotlp.WithTraceGRPCHeaders(r.opts.headers)}
+		if r.opts.endpoint != "" {
+			gopts = append(gopts, telotlp.WithTraceGRPCEndpoint(r.opts.endpoint))
+		}
+		if r.opts.insecure {
+			gopts = append(gopts, telotlp.WithTraceGRPCInsecure())
+		}
+		r.spanExporter, err = telotlp.NewTraceGRPC(ctx, gopts...)
+	case exporterJaeger:
+		r.spanExporter, err = teljaeger.New(jaegerEndpoint(r.opts.endpoint))
+	case exporterZipkin:
+		endpoint := r.opts.endpoint
+		if endpoint == "" {
+			endpoint = "http://localhost:9411/api/v2/spans"
+		}
+		r.spanExporter, err = telzipkin.New(endpoint)
+	}
+	if err != nil {
+		// Don't keep a typed nil around to be shut down later.
+		r.spanExporter = nil
+	}
+	return r.spanExporter, err
+}
+
+// jaegerEndpoint uses the collector for URLs and the agent otherwise.
+func jaegerEndpoint(endpoint string) teljaeger.EndpointOption {
+	if endpoint == "" || strings.Contains(endpoint, "://") {
+		var copts []teljaeger.CollectorEndpointOption
+		if endpoint != "" {
+			copts = append(copts, teljaeger.WithEndpoint(endpoint))
+		}
+		return teljaeger.WithCollectorEndpoint(copts...)
+	}
+	var aopts []teljaeger.AgentEndpointOption
+	if host, port, ok := strings.Cut(endpoint, ":"); ok {
+		aopts = append(aopts, teljaeger.WithAgentHost(host), teljaeger.WithAgentPort(port))
+	} else {
+		aopts = append(aopts, teljaeger.WithAgentHost(endpoint))
+	}
+	return teljaeger.WithAgentEndpoint(aopts...)
+}
+
+// metricExp returns the metric exporter, creating it on first use.
+//
+// The exporters use the stateless temporality selector as the instrument
+// kinds of replayed records are chosen so it reproduces the temporality
+// they were captured with. They also export exponential histograms.
+func (r *replayer) metricExp(ctx context.Context) (telsdk.Exporter, error) {
+	if r.metricExporter != nil {
+		return r.metricExporter, nil
+	}
+	var err error
+	temporality := telotlp.WithMetricMetricAggregationTemporalitySelector(aggregation.StatelessTemporalitySelector())
+	switch r.opts.exporter {
+	case exporterStdout:
+		r.metricExporter, err = telstdout.NewStdoutExponentialMetric(telstdout.WithStdoutExponentialMetricPrettyPrint())
+	case exporterOTLP:
+		hopts := []telotlp.MetricHTTPOption{telotlp.WithMetricHTTPHeaders(r.opts.headers)}
+		if r.opts.endpoint != "" {
+			hopts = append(hopts, telotlp.WithMetricHTTPEndpoint(r.opts.endpoint))
+		}
+		if r.opts.insecure {
+			hopts = append(hopts, telotlp.WithMetricHTTPInsecure())
+		}
+		r.metricExporter, err = telotlp.NewExponentialMetric(ctx, telotlp.NewMetricHTTPClient(hopts...), temporality)
+	case exporterOTLPGRPC:
+		gopts := []telotlp.GRPCOption{telotlp.WithGRPCHeaders(r.opts.headers)}
+		if r.opts.endpoint != "" {
+			gopts = append(gopts, telotlp.WithGRPCEndpoint(r.opts.endpoint))
+		}
+		if r.opts.insecure {
+			gopts = append(gopts, telotlp.WithGRPCInsecure())
+		}
+		r.metricExporter, err = telotlp.NewExponentialMetric(ctx, telotlp.NewOTLPGRPCMetricClient(gopts...), temporality)
+	default:
+		err = fmt.Errorf("exporter %s does not support metrics", r.opts.exporter)
+	}
+	if err != nil {
+		r.metricExporter = nil
+	}
+	return r.metricExporter, err
+}
+
+func (r *replayer) shutdown(ctx context.Context) error {
+	var err error
+	if r.spanExporter != nil {
+		err = r.spanExporter.Shutdown(ctx)
+	}
+	if s, ok := r.metricExporter.(interface{ Shutdown(context.Context) error }); ok {
+		if serr := s.Shutdown(ctx); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
@@ -0,0 +1,205 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/henvic/tel/internal/otlpjson"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxMessageSize bounds the size of a single length-delimited message, so a
+// corrupt or mismatched file doesn't make us allocate unbounded memory.
+const maxMessageSize = 64 << 20
+
+// batch is a single request read from a capture file: either traces or
+// metrics is set.
+type batch struct {
+	traces  *coltracepb.ExportTraceServiceRequest
+	metrics *colmetricpb.ExportMetricsServiceRequest
+}
+
+// batchReader reads batches from a capture file.
+type batchReader struct {
+	name   string
+	format string
+	signal string
+
+	f    *os.File
+	gz   *gzip.Reader
+	r    *bufio.Reader
+	line int
+}
+
+func openBatchReader(name, format, signal string) (*batchReader, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	br := &batchReader{name: name, format: format, signal: signal, f: f}
+	r := bufio.NewReader(f)
+	// Detect gzip from its magic number rather than the file name, so
+	// rotated files keep working whatever they are named.
+	if magic, _ := r.Peek(2); len(magic) == 2 && magic[0] == 0x1f && magic[1] == 0x8b {
+		if br.gz, err = gzip.NewReader(r); err != nil {
+			f.Close()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		r = bufio.NewReader(br.gz)
+	}
+	br.r = r
+	if br.format == formatAuto {
+		br.format = formatFromName(name)
+	}
+	if br.format == formatAuto {
+		if br.format, err = br.detectFormat(); err != nil {
+			br.close()
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	return br, nil
+}
+
+// formatFromName returns the format matching the extension of a capture
+// file, ignoring a .gz suffix, or formatAuto if it has no known extension.
+func formatFromName(name string) string {
+	ext := filepath.Ext(strings.TrimSuffix(name, ".gz"))
+	switch strings.ToLower(ext) {
+	case ".json", ".jsonl", ".ndjson":
+		return formatJSON
+	case ".pb", ".binpb", ".protobuf":
+		return formatProto
+	}
+	return formatAuto
+}
+
+// detectFormat reads the first non-blank line of the capture and picks
+// OTLP/JSON if it decodes as a request, or protobuf otherwise. The first byte alone
+// isn't enough, as '{' or white space are also valid varint lengths of a
+// delimited protobuf message. The line is put back for next to read.
+func (br *batchReader) detectFormat() (string, error) {
+	var read []byte
+	defer func() {
+		br.r = bufio.NewReader(io.MultiReader(bytes.NewReader(read), br.r))
+	}()
+	for len(read) < maxMessageSize {
+		line, err := readPrefix(br.r, maxMessageSize)
+		read = append(read, line...)
+		if err != nil && err != io.EOF {
+			return "", err
+		}
+		if line = bytes.TrimSpace(line); len(line) > 0 {
+			if _, err := br.decode(line, br.signalJSON(line), otlpjson.Unmarshal); err == nil {
+				return formatJSON, nil
+			}
+			return formatProto, nil
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return formatJSON, nil
+}
+
+// readPrefix reads up to and including the first newline, stopping after
+// limit bytes.
+func readPrefix(r *bufio.Reader, limit int) ([]byte, error) {
+	var line []byte
+	for len(line) < limit {
+		chunk, err := r.ReadSlice('\n')
+		line = append(line, chunk...)
+		if err != bufio.ErrBufferFull {
+			return line, err
+		}
+	}
+	return line, nil
+}
+
+// next returns the next batch, or io.EOF when there are no more.
+func (br *batchReader) next() (batch, error) {
+	if br.format == formatJSON {
+		return br.nextJSON()
+	}
+	return br.nextProto()
+}
+
+func (br *batchReader) nextJSON() (batch, error) {
+	for {
+		line, err := br.r.ReadBytes('\n')
+		if err != nil && (err != io.EOF || len(line) == 0) {
+			return batch{}, err
+		}
+		br.line++
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		b, err := br.decode(line, br.signalJSON(line), otlpjson.Unmarshal)
+		if err != nil {
+			return batch{}, fmt.Errorf("%s:%d: %w", br.name, br.line, err)
+		}
+		return b, nil
+	}
+}
+
+// signalJSON returns the signal of an OTLP/JSON line, looking at the name
+// of its top-level field when the signal is not set explicitly.
+func (br *batchReader) signalJSON(line []byte) string {
+	if br.signal != signalAuto {
+		return br.signal
+	}
+	if bytes.Contains(line, []byte(`"resourceMetrics"`)) {
+		return signalMetrics
+	}
+	return signalTraces
+}
+
+func (br *batchReader) nextProto() (batch, error) {
+	size, err := binary.ReadUvarint(br.r)
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return batch{}, io.EOF
+		}
+		return batch{}, fmt.Errorf("%s: %w", br.name, err)
+	}
+	if size > maxMessageSize {
+		return batch{}, fmt.Errorf("%s: message of %d bytes is too large", br.name, size)
+	}
+	buf := make([]byte, size)
+	if _, err := io.ReadFull(br.r, buf); err != nil {
+		return batch{}, fmt.Errorf("%s: %w", br.name, err)
+	}
+	// Delimited protobuf carries no hint of which request it holds, so
+	// auto detection assumes traces.
+	signal := br.signal
+	if signal == signalAuto {
+		signal = signalTraces
+	}
+	return br.decode(buf, signal, proto.Unmarshal)
+}
+
+func (br *batchReader) decode(b []byte, signal string, unmarshal func([]byte, proto.Message) error) (batch, error) {
+	if signal == signalMetrics {
+		m := &colmetricpb.ExportMetricsServiceRequest{}
+		return batch{metrics: m}, unmarshal(b, m)
+	}
+	m := &coltracepb.ExportTraceServiceRequest{}
+	return batch{traces: m}, unmarshal(b, m)
+}
+
+func (br *batchReader) close() error {
+	if br.gz != nil {
+		br.gz.Close()
+	}
+	return br.f.Close()
+}
@@ -0,0 +1,174 @@
+package main
+
+import (
+	"crypto/rand"
+	"math"
+	"time"
+
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// rewriter changes timestamps and IDs of batches before they are exported.
+type rewriter struct {
+	shift time.Duration
+	// rebase moves the first timestamp read to the time replay started.
+	rebase  bool
+	started time.Time
+	based   bool
+
+	remap   bool
+	traces  map[string][]byte
+	spanIDs map[string][]byte
+}
+
+func newRewriter(shift time.Duration, rebase, remap bool) *rewriter {
+	return &rewriter{
+		shift:   shift,
+		rebase:  rebase,
+		started: time.Now(),
+		remap:   remap,
+		traces:  map[string][]byte{},
+		spanIDs: map[string][]byte{},
+	}
+}
+
+func (rw *rewriter) rewrite(b batch) {
+	if rw.rebase && !rw.based {
+		if first := firstTimestamp(b); first != 0 {
+			rw.shift += time.Duration(uint64(rw.started.UnixNano()) - first)
+			rw.based = true
+		}
+	}
+	if b.traces != nil {
+		rw.rewriteTraces(b.traces)
+	}
+	if b.metrics != nil {
+		rw.rewriteMetrics(b.metrics)
+	}
+}
+
+func (rw *rewriter) rewriteTraces(req *coltracepb.ExportTraceServiceRequest) {
+	for _, rs := range req.ResourceSpans {
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				s.StartTimeUnixNano = rw.time(s.StartTimeUnixNano)
+				s.EndTimeUnixNano = rw.time(s.EndTimeUnixNano)
+				for _, e := range s.Events {
+					e.TimeUnixNano = rw.time(e.TimeUnixNano)
+				}
+				if !rw.remap {
+					continue
+				}
+				s.TraceId = rw.id(rw.traces, s.TraceId)
+				s.SpanId = rw.id(rw.spanIDs, s.SpanId)
+				s.ParentSpanId = rw.id(rw.spanIDs, s.ParentSpanId)
+				for _, l := range s.Links {
+					l.TraceId = rw.id(rw.traces, l.TraceId)
+					l.SpanId = rw.id(rw.spanIDs, l.SpanId)
+				}
+			}
+		}
+	}
+}
+
+func (rw *rewriter) rewriteMetrics(req *colmetricpb.ExportMetricsServiceRequest) {
+	forEachDataPoint(req, func(start, t *uint64, exemplars []*metricpb.Exemplar) {
+		*start = rw.time(*start)
+		*t = rw.time(*t)
+		for _, e := range exemplars {
+			e.TimeUnixNano = rw.time(e.TimeUnixNano)
+			if rw.remap {
+				e.TraceId = rw.id(rw.traces, e.TraceId)
+				e.SpanId = rw.id(rw.spanIDs, e.SpanId)
+			}
+		}
+	})
+}
+
+// time shifts a timestamp, leaving unset ones alone.
+func (rw *rewriter) time(t uint64) uint64 {
+	if t == 0 || rw.shift == 0 {
+		return t
+	}
+	return uint64(int64(t) + int64(rw.shift))
+}
+
+// id returns the random ID replacing id, generating it on first use so
+// every reference to the same span or trace keeps pointing to it.
+func (rw *rewriter) id(ids map[string][]byte, id []byte) []byte {
+	if len(id) == 0 {
+		return id
+	}
+	if v, ok := ids[string(id)]; ok {
+		return v
+	}
+	v := make([]byte, len(id))
+	if _, err := rand.Read(v); err != nil {
+		panic(err)
+	}
+	ids[string(id)] = v
+	return v
+}
+
+// firstTimestamp returns the earliest timestamp in b, or zero if it has
+// none.
+func firstTimestamp(b batch) uint64 {
+	var first uint64 = math.MaxUint64
+	min := func(t uint64) {
+		if t != 0 && t < first {
+			first = t
+		}
+	}
+	if b.traces != nil {
+		for _, rs := range b.traces.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				for _, s := range ss.Spans {
+					min(s.StartTimeUnixNano)
+				}
+			}
+		}
+	}
+	if b.metrics != nil {
+		forEachDataPoint(b.metrics, func(start, t *uint64, _ []*metricpb.Exemplar) {
+			min(*start)
+			min(*t)
+		})
+	}
+	if first == math.MaxUint64 {
+		return 0
+	}
+	return first
+}
+
+func forEachDataPoint(req *colmetricpb.ExportMetricsServiceRequest, fn func(start, t *uint64, exemplars []*metricpb.Exemplar)) {
+	for _, rm := range req.ResourceMetrics {
+		for _, sm := range rm.ScopeMetrics {
+			for _, m := range sm.Metrics {
+				switch data := m.Data.(type) {
+				case *metricpb.Metric_Gauge:
+					for _, dp := range data.Gauge.DataPoints {
+						fn(&dp.StartTimeUnixNano, &dp.TimeUnixNano, dp.Exemplars)
+					}
+				case *metricpb.Metric_Sum:
+					for _, dp := range data.Sum.DataPoints {
+						fn(&dp.StartTimeUnixNano, &dp.TimeUnixNano, dp.Exemplars)
+					}
+				case *metricpb.Metric_Histogram:
+					for _, dp := range data.Histogram.DataPoints {
+						fn(&dp.StartTimeUnixNano, &dp.TimeUnixNano, dp.Exemplars)
+					}
+				case *metricpb.Metric_ExponentialHistogram:
+					for _, dp := range data.ExponentialHistogram.DataPoints {
+						fn(&dp.StartTimeUnixNano, &dp.TimeUnixNano, dp.Exemplars)
+					}
+				case *metricpb.Metric_Summary:
+					for _, dp := range data.Summary.DataPoints {
+						fn(&dp.StartTimeUnixNano, &dp.TimeUnixNano, nil)
+					}
+				}
+			}
+		}
+	}
+}
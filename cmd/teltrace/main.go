@@ -0,0 +1,203 @@
+// Command teltrace is a trace viewer for local development.
+//
+// It receives traces using OTLP over gRPC and HTTP, and the Zipkin v2 JSON
+// API, on their default ports, so services using the exporters in
+// export/telotlp or export/telzipkin work with it without configuration.
+// The most recent traces are kept in memory.
+//
+// Traces are shown as a waterfall with the attributes and events of each
+// span, and can be searched by service, span name, duration or error. The
+// views are served as HTML for browsers and as text for terminals:
+//
+//	curl 'localhost:16686/?service=frontend&min=100ms&error=1'
+//	curl localhost:16686/trace/<trace-id>
+//
+// With -print, traces are also written to stdout once they stop receiving
+// new spans.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	"google.golang.org/grpc"
+)
+
+type options struct {
+	otlpGRPC  string
+	otlpHTTP  string
+	zipkin    string
+	ui        string
+	maxTraces int
+	print     bool
+	settle    time.Duration
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "teltrace: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	var opts options
+	fs := flag.NewFlagSet("teltrace", flag.ContinueOnError)
+	fs.StringVar(&opts.otlpGRPC, "otlp-grpc", "localhost:4317", "address of the OTLP/gRPC receiver; empty disables it")
+	fs.StringVar(&opts.otlpHTTP, "otlp-http", "localhost:4318", "address of the OTLP/HTTP receiver; empty disables it")
+	fs.StringVar(&opts.zipkin, "zipkin", "localhost:9411", "address of the Zipkin receiver; empty disables it")
+	fs.StringVar(&opts.ui, "ui", "localhost:16686", "address serving the HTML and text views; empty disables it")
+	fs.IntVar(&opts.maxTraces, "max-traces", 1000, "number of traces kept in memory")
+	fs.BoolVar(&opts.print, "print", false, "print traces to stdout once they stop receiving spans")
+	fs.DurationVar(&opts.settle, "settle", 2*time.Second, "time without new spans after which a trace is printed")
+	if err := fs.Parse(args); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return nil
+		}
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	st := newStore(opts.maxTraces)
+	if opts.print {
+		p := &printer{store: st, settle: opts.settle, pending: map[string]time.Time{}}
+		st.onAdd = p.touch
+		go p.run(ctx)
+	}
+	rcv := &otlpReceiver{store: st}
+
+	var (
+		servers []*http.Server
+		grpcSrv *grpc.Server
+	)
+	defer func() {
+		sctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		for _, srv := range servers {
+			srv.Shutdown(sctx)
+		}
+	}()
+	errc := make(chan error, 4)
+	serveHTTP := func(name, addr string, h http.Handler) error {
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{Handler: h, ReadHeaderTimeout: 10 * time.Second}
+		servers = append(servers, srv)
+		log.Printf("%s listening on %s", name, l.Addr())
+		go func() {
+			if err := srv.Serve(l); err != nil && err != http.ErrServerClosed {
+				errc <- fmt.Errorf("%s: %w", name, err)
+			}
+		}()
+		return nil
+	}
+	if opts.otlpGRPC != "" {
+		l, err := net.Listen("tcp", opts.otlpGRPC)
+		if err != nil {
+			return err
+		}
+		grpcSrv = grpc.NewServer()
+		coltracepb.RegisterTraceServiceServer(grpcSrv, rcv)
+		log.Printf("OTLP/gRPC receiver listening on %s", l.Addr())
+		go func() {
+			if err := grpcSrv.Serve(l); err != nil {
+				errc <- fmt.Errorf("OTLP/gRPC receiver: %w", err)
+			}
+		}()
+		defer grpcSrv.Stop()
+	}
+	if opts.otlpHTTP != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/v1/traces", rcv)
+		if err := serveHTTP("OTLP/HTTP receiver", opts.otlpHTTP, mux); err != nil {
+			return err
+		}
+	}
+	if opts.zipkin != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/api/v2/spans", &zipkinReceiver{store: st})
+		if err := serveHTTP("Zipkin receiver", opts.zipkin, mux); err != nil {
+			return err
+		}
+	}
+	if opts.ui != "" {
+		if err := serveHTTP("UI", opts.ui, (&ui{store: st}).handler()); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return nil
+	case err := <-errc:
+		return err
+	}
+}
+
+// printer writes traces to stdout once they settle.
+type printer struct {
+	store  *store
+	settle time.Duration
+
+	mu      sync.Mutex
+	pending map[string]time.Time
+}
+
+func (p *printer) touch(ids []string) {
+	now := time.Now()
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, id := range ids {
+		p.pending[id] = now
+	}
+}
+
+func (p *printer) run(ctx context.Context) {
+	interval := p.settle / 4
+	if interval < 10*time.Millisecond {
+		interval = 10 * time.Millisecond
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			for _, id := range p.settled(now) {
+				if t, ok := p.store.get(id); ok {
+					writeWaterfall(os.Stdout, t, false)
+				}
+			}
+		}
+	}
+}
+
+// settled returns the IDs of pending traces without spans for the settle
+// time, removing them from pending.
+func (p *printer) settled(now time.Time) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var ids []string
+	for id, t := range p.pending {
+		if now.Sub(t) >= p.settle {
+			ids = append(ids, id)
+			delete(p.pending, id)
+		}
+	}
+	return ids
+}
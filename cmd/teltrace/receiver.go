@@ -0,0 +1,245 @@
+package main
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/henvic/tel/internal/otlpconv"
+	"github.com/henvic/tel/internal/otlpjson"
+	zkmodel "github.com/openzipkin/zipkin-go/model"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxRequestSize bounds the size of a decompressed request body.
+const maxRequestSize = 32 << 20
+
+// otlpReceiver receives traces using OTLP over gRPC and HTTP.
+type otlpReceiver struct {
+	coltracepb.UnimplementedTraceServiceServer
+	store *store
+}
+
+// Export implements the OTLP/gRPC trace service.
+func (rcv *otlpReceiver) Export(ctx context.Context, req *coltracepb.ExportTraceServiceRequest) (*coltracepb.ExportTraceServiceResponse, error) {
+	rcv.store.add(otlpSpans(req.ResourceSpans))
+	return &coltracepb.ExportTraceServiceResponse{}, nil
+}
+
+// ServeHTTP implements OTLP/HTTP for traces, accepting both binary protobuf
+// and JSON encoded requests.
+func (rcv *otlpReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	var (
+		req         coltracepb.ExportTraceServiceRequest
+		contentType string
+		marshal     func(proto.Message) ([]byte, error)
+	)
+	switch mediaType(r) {
+	case "application/x-protobuf":
+		err = proto.Unmarshal(body, &req)
+		contentType, marshal = "application/x-protobuf", proto.Marshal
+	case "application/json":
+		err = otlpjson.Unmarshal(body, &req)
+		contentType, marshal = "application/json", otlpjson.Marshal
+	default:
+		http.Error(w, "unsupported content type", http.StatusUnsupportedMediaType)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rcv.store.add(otlpSpans(req.ResourceSpans))
+
+	b, err := marshal(&coltracepb.ExportTraceServiceResponse{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(b)
+}
+
+// zipkinReceiver receives spans using the Zipkin v2 JSON API.
+type zipkinReceiver struct {
+	store *store
+}
+
+func (rcv *zipkinReceiver) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if mt := mediaType(r); mt != "" && mt != "application/json" {
+		http.Error(w, "only JSON encoded spans are supported", http.StatusUnsupportedMediaType)
+		return
+	}
+	body, err := readBody(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var models []zkmodel.SpanModel
+	if err := json.Unmarshal(body, &models); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	rcv.store.add(zipkinSpans(models))
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func mediaType(r *http.Request) string {
+	mt, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return mt
+}
+
+func readBody(r *http.Request) ([]byte, error) {
+	var body io.Reader = r.Body
+	if strings.EqualFold(r.Header.Get("Content-Encoding"), "gzip") {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		body = gz
+	}
+	b, err := io.ReadAll(io.LimitReader(body, maxRequestSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(b) > maxRequestSize {
+		return nil, fmt.Errorf("request is larger than %d bytes", maxRequestSize)
+	}
+	return b, nil
+}
+
+func otlpSpans(rss []*tracepb.ResourceSpans) []span {
+	var spans []span
+	for _, rs := range rss {
+		service := "unknown_service"
+		for _, kv := range rs.GetResource().GetAttributes() {
+			if kv.Key == "service.name" {
+				service = kv.Value.GetStringValue()
+			}
+		}
+		for _, ss := range rs.ScopeSpans {
+			for _, s := range ss.Spans {
+				spans = append(spans, span{
+					TraceID:       hex.EncodeToString(s.TraceId),
+					SpanID:        hex.EncodeToString(s.SpanId),
+					ParentID:      hex.EncodeToString(s.ParentSpanId),
+					Service:       service,
+					Name:          s.Name,
+					Kind:          strings.ToLower(strings.TrimPrefix(s.Kind.String(), "SPAN_KIND_")),
+					Scope:         ss.GetScope().GetName(),
+					Start:         time.Unix(0, int64(s.StartTimeUnixNano)),
+					End:           time.Unix(0, int64(s.EndTimeUnixNano)),
+					Error:         s.GetStatus().GetCode() == tracepb.Status_STATUS_CODE_ERROR,
+					StatusMessage: s.GetStatus().GetMessage(),
+					Attributes:    otlpAttrs(s.Attributes),
+					Events:        otlpEvents(s.Events),
+				})
+			}
+		}
+	}
+	return spans
+}
+
+func otlpEvents(events []*tracepb.Span_Event) []event {
+	var es []event
+	for _, e := range events {
+		es = append(es, event{
+			Time:       time.Unix(0, int64(e.TimeUnixNano)),
+			Name:       e.Name,
+			Attributes: otlpAttrs(e.Attributes),
+		})
+	}
+	return es
+}
+
+func otlpAttrs(kvs []*commonpb.KeyValue) []attr {
+	var attrs []attr
+	for _, kv := range otlpconv.Attributes(kvs) {
+		attrs = append(attrs, attr{Key: string(kv.Key), Value: kv.Value.Emit()})
+	}
+	return attrs
+}
+
+func zipkinSpans(models []zkmodel.SpanModel) []span {
+	spans := make([]span, 0, len(models))
+	for _, m := range models {
+		s := span{
+			TraceID: m.TraceID.String(),
+			SpanID:  m.ID.String(),
+			Service: "unknown_service",
+			Name:    m.Name,
+			Kind:    strings.ToLower(string(m.Kind)),
+			Start:   m.Timestamp,
+			End:     m.Timestamp.Add(m.Duration),
+		}
+		if m.ParentID != nil {
+			s.ParentID = m.ParentID.String()
+		}
+		if m.LocalEndpoint != nil && m.LocalEndpoint.ServiceName != "" {
+			s.Service = m.LocalEndpoint.ServiceName
+		}
+		// Zipkin marks errors with the error tag, which the OpenTelemetry
+		// exporter sets to the status description.
+		if msg, ok := m.Tags["error"]; ok {
+			s.Error, s.StatusMessage = true, msg
+		}
+		s.Scope = m.Tags["otel.library.name"]
+		for k, v := range m.Tags {
+			s.Attributes = append(s.Attributes, attr{Key: k, Value: v})
+		}
+		sort.Slice(s.Attributes, func(i, j int) bool { return s.Attributes[i].Key < s.Attributes[j].Key })
+		for _, a := range m.Annotations {
+			s.Events = append(s.Events, zipkinEvent(a))
+		}
+		spans = append(spans, s)
+	}
+	return spans
+}
+
+// zipkinEvent reverses the OpenTelemetry exporter encoding of events with
+// attributes as "name: {json}" annotations.
+func zipkinEvent(a zkmodel.Annotation) event {
+	e := event{Time: a.Timestamp, Name: a.Value}
+	i := strings.Index(a.Value, ": {")
+	if i < 0 {
+		return e
+	}
+	var attrs map[string]interface{}
+	if err := json.Unmarshal([]byte(a.Value[i+2:]), &attrs); err != nil {
+		return e
+	}
+	e.Name = a.Value[:i]
+	for k, v := range attrs {
+		e.Attributes = append(e.Attributes, attr{Key: k, Value: fmt.Sprint(v)})
+	}
+	sort.Slice(e.Attributes, func(i, j int) bool { return e.Attributes[i].Key < e.Attributes[j].Key })
+	return e
+}
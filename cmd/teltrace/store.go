@@ -0,0 +1,299 @@
+package main
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// span is the receiver-independent form of a span kept in memory.
+type span struct {
+	TraceID       string
+	SpanID        string
+	ParentID      string
+	Service       string
+	Name          string
+	Kind          string
+	Scope         string
+	Start         time.Time
+	End           time.Time
+	Error         bool
+	StatusMessage string
+	Attributes    []attr
+	Events        []event
+}
+
+func (s span) Duration() time.Duration {
+	return s.End.Sub(s.Start)
+}
+
+type attr struct {
+	Key   string
+	Value string
+}
+
+type event struct {
+	Time       time.Time
+	Name       string
+	Attributes []attr
+}
+
+// trace groups the spans received for a trace ID.
+type trace struct {
+	ID      string
+	Spans   []span
+	updated time.Time
+}
+
+// Root returns the span without a parent in the trace, or the earliest
+// span if it has not arrived (yet).
+func (t *trace) Root() span {
+	var root span
+	for i, s := range t.Spans {
+		if s.ParentID == "" {
+			return s
+		}
+		if i == 0 || s.Start.Before(root.Start) {
+			root = s
+		}
+	}
+	return root
+}
+
+func (t *trace) Start() time.Time {
+	var start time.Time
+	for _, s := range t.Spans {
+		if start.IsZero() || s.Start.Before(start) {
+			start = s.Start
+		}
+	}
+	return start
+}
+
+func (t *trace) End() time.Time {
+	var end time.Time
+	for _, s := range t.Spans {
+		if s.End.After(end) {
+			end = s.End
+		}
+	}
+	return end
+}
+
+func (t *trace) Duration() time.Duration {
+	return t.End().Sub(t.Start())
+}
+
+func (t *trace) Errors() int {
+	var n int
+	for _, s := range t.Spans {
+		if s.Error {
+			n++
+		}
+	}
+	return n
+}
+
+func (t *trace) Services() []string {
+	seen := map[string]bool{}
+	var services []string
+	for _, s := range t.Spans {
+		if !seen[s.Service] {
+			seen[s.Service] = true
+			services = append(services, s.Service)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+// row is a span placed in the waterfall of its trace.
+type row struct {
+	span
+	Depth int
+	// Offset and Width are percentages of the trace duration.
+	Offset float64
+	Width  float64
+}
+
+// Waterfall returns the spans ordered depth-first from the root, children
+// sorted by start time. Spans whose parent is missing are shown as roots.
+func (t *trace) Waterfall() []row {
+	ids := map[string]bool{}
+	for _, s := range t.Spans {
+		ids[s.SpanID] = true
+	}
+	children := map[string][]span{}
+	for _, s := range t.Spans {
+		parent := s.ParentID
+		if !ids[parent] {
+			parent = ""
+		}
+		children[parent] = append(children[parent], s)
+	}
+	for _, c := range children {
+		sort.SliceStable(c, func(i, j int) bool { return c[i].Start.Before(c[j].Start) })
+	}
+
+	start, total := t.Start(), t.Duration()
+	rows := make([]row, 0, len(t.Spans))
+	visited := map[string]bool{}
+	var visit func(parent string, depth int)
+	visit = func(parent string, depth int) {
+		for _, s := range children[parent] {
+			// Guard against parent cycles and repeated span IDs.
+			if visited[s.SpanID] {
+				continue
+			}
+			visited[s.SpanID] = true
+			r := row{span: s, Depth: depth, Width: 100}
+			if total > 0 {
+				r.Offset = 100 * float64(s.Start.Sub(start)) / float64(total)
+				r.Width = 100 * float64(s.Duration()) / float64(total)
+			}
+			rows = append(rows, r)
+			visit(s.SpanID, depth+1)
+		}
+	}
+	visit("", 0)
+	// Spans in a parent cycle are never reached from a root.
+	for _, s := range t.Spans {
+		if !visited[s.SpanID] {
+			visited[s.SpanID] = true
+			rows = append(rows, row{span: s, Width: 100})
+		}
+	}
+	return rows
+}
+
+// query filters traces. Zero fields match everything.
+type query struct {
+	Service     string
+	Name        string
+	MinDuration time.Duration
+	MaxDuration time.Duration
+	ErrorsOnly  bool
+	Limit       int
+}
+
+// match reports whether any span of t matches service and name, and the
+// trace as a whole matches the duration and error filters.
+func (q query) match(t *trace) bool {
+	if q.ErrorsOnly && t.Errors() == 0 {
+		return false
+	}
+	d := t.Duration()
+	if q.MinDuration > 0 && d < q.MinDuration {
+		return false
+	}
+	if q.MaxDuration > 0 && d > q.MaxDuration {
+		return false
+	}
+	for _, s := range t.Spans {
+		if q.Service != "" && !strings.EqualFold(s.Service, q.Service) {
+			continue
+		}
+		if q.Name != "" && !strings.Contains(strings.ToLower(s.Name), strings.ToLower(q.Name)) {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// store keeps the most recently received traces in memory.
+type store struct {
+	maxTraces int
+
+	mu     sync.RWMutex
+	traces map[string]*trace
+	// order lists trace IDs in the order they were first seen.
+	order []string
+	// onAdd is called with the IDs of traces that got new spans.
+	onAdd func(ids []string)
+}
+
+func newStore(maxTraces int) *store {
+	return &store{maxTraces: maxTraces, traces: map[string]*trace{}}
+}
+
+func (st *store) add(spans []span) {
+	if len(spans) == 0 {
+		return
+	}
+	now := time.Now()
+	st.mu.Lock()
+	var ids []string
+	seen := map[string]bool{}
+	for _, s := range spans {
+		t, ok := st.traces[s.TraceID]
+		if !ok {
+			t = &trace{ID: s.TraceID}
+			st.traces[s.TraceID] = t
+			st.order = append(st.order, s.TraceID)
+		}
+		if !seen[s.TraceID] {
+			seen[s.TraceID] = true
+			ids = append(ids, s.TraceID)
+		}
+		t.Spans = append(t.Spans, s)
+		t.updated = now
+	}
+	for st.maxTraces > 0 && len(st.order) > st.maxTraces {
+		delete(st.traces, st.order[0])
+		st.order = st.order[1:]
+	}
+	onAdd := st.onAdd
+	st.mu.Unlock()
+	if onAdd != nil {
+		onAdd(ids)
+	}
+}
+
+// get returns a copy of the trace, or false if it is not stored.
+func (st *store) get(id string) (*trace, bool) {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	t, ok := st.traces[id]
+	if !ok {
+		return nil, false
+	}
+	return &trace{ID: t.ID, Spans: append([]span(nil), t.Spans...), updated: t.updated}, true
+}
+
+// search returns copies of the traces matching q, the most recent first.
+func (st *store) search(q query) []*trace {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	var traces []*trace
+	for i := len(st.order) - 1; i >= 0; i-- {
+		t := st.traces[st.order[i]]
+		if !q.match(t) {
+			continue
+		}
+		traces = append(traces, &trace{ID: t.ID, Spans: append([]span(nil), t.Spans...), updated: t.updated})
+		if q.Limit > 0 && len(traces) == q.Limit {
+			break
+		}
+	}
+	return traces
+}
+
+// services returns the names of all services with stored spans.
+func (st *store) services() []string {
+	st.mu.RLock()
+	defer st.mu.RUnlock()
+	seen := map[string]bool{}
+	var services []string
+	for _, t := range st.traces {
+		for _, s := range t.Spans {
+			if !seen[s.Service] {
+				seen[s.Service] = true
+				services = append(services, s.Service)
+			}
+		}
+	}
+	sort.Strings(services)
+	return services
+}
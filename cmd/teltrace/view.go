@@ -0,0 +1,268 @@
+package main
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ui serves the HTML and plain text views of the stored traces.
+type ui struct {
+	store *store
+}
+
+func (u *ui) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", u.search)
+	mux.HandleFunc("/trace/", u.trace)
+	return mux
+}
+
+// wantsText reports whether the client asked for the terminal view, either
+// with ?format=text or by not accepting HTML, as curl does by default.
+func wantsText(r *http.Request) bool {
+	if f := r.URL.Query().Get("format"); f != "" {
+		return f == "text"
+	}
+	return !strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+func (u *ui) search(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	q, err := parseQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	traces := u.store.search(q)
+	if wantsText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, t := range traces {
+			writeSummary(w, t)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	err = searchTemplate.Execute(w, map[string]interface{}{
+		"Query":    r.URL.Query(),
+		"Services": u.store.services(),
+		"Traces":   traces,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func (u *ui) trace(w http.ResponseWriter, r *http.Request) {
+	t, ok := u.store.get(strings.TrimPrefix(r.URL.Path, "/trace/"))
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if wantsText(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		writeWaterfall(w, t, true)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := traceTemplate.Execute(w, t); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+func parseQuery(r *http.Request) (query, error) {
+	v := r.URL.Query()
+	q := query{
+		Service:    v.Get("service"),
+		Name:       v.Get("name"),
+		ErrorsOnly: v.Get("error") != "",
+		Limit:      100,
+	}
+	var err error
+	if s := v.Get("min"); s != "" {
+		if q.MinDuration, err = time.ParseDuration(s); err != nil {
+			return q, fmt.Errorf("invalid min duration: %w", err)
+		}
+	}
+	if s := v.Get("max"); s != "" {
+		if q.MaxDuration, err = time.ParseDuration(s); err != nil {
+			return q, fmt.Errorf("invalid max duration: %w", err)
+		}
+	}
+	if s := v.Get("limit"); s != "" {
+		if q.Limit, err = strconv.Atoi(s); err != nil {
+			return q, fmt.Errorf("invalid limit: %w", err)
+		}
+	}
+	return q, nil
+}
+
+// barWidth is the number of columns of the terminal waterfall bars.
+const barWidth = 40
+
+func writeSummary(w io.Writer, t *trace) {
+	root := t.Root()
+	errs := ""
+	if n := t.Errors(); n > 0 {
+		errs = fmt.Sprintf(" %d error(s)", n)
+	}
+	fmt.Fprintf(w, "%s  %s  %s: %s  %v  %d span(s)%s\n",
+		t.ID, t.Start().Format("15:04:05.000"), root.Service, root.Name, round(t.Duration()), len(t.Spans), errs)
+}
+
+// writeWaterfall writes the trace as a text waterfall. With details, the
+// attributes and events of each span follow it.
+func writeWaterfall(w io.Writer, t *trace, details bool) {
+	writeSummary(w, t)
+	for _, r := range t.Waterfall() {
+		from := int(r.Offset * barWidth / 100)
+		to := int((r.Offset + r.Width) * barWidth / 100)
+		if to <= from {
+			to = from + 1
+		}
+		if to > barWidth {
+			to = barWidth
+		}
+		if from >= to {
+			from = to - 1
+		}
+		fill := "="
+		if r.Error {
+			fill = "!"
+		}
+		bar := strings.Repeat(" ", from) + strings.Repeat(fill, to-from) + strings.Repeat(" ", barWidth-to)
+		fmt.Fprintf(w, "  [%s] %10v %s%s: %s\n", bar, round(r.Duration()), strings.Repeat("  ", r.Depth), r.Service, r.Name)
+		if !details {
+			continue
+		}
+		indent := strings.Repeat(" ", barWidth+17) + strings.Repeat("  ", r.Depth)
+		if r.Error && r.StatusMessage != "" {
+			fmt.Fprintf(w, "%s  error: %s\n", indent, r.StatusMessage)
+		}
+		for _, a := range r.Attributes {
+			fmt.Fprintf(w, "%s  %s=%s\n", indent, a.Key, a.Value)
+		}
+		for _, e := range r.Events {
+			fmt.Fprintf(w, "%s  @%v %s", indent, round(e.Time.Sub(r.Start)), e.Name)
+			for _, a := range e.Attributes {
+				fmt.Fprintf(w, " %s=%s", a.Key, a.Value)
+			}
+			fmt.Fprintln(w)
+		}
+	}
+	fmt.Fprintln(w)
+}
+
+// round makes durations easier to read, dropping nanoseconds above a
+// millisecond and microseconds above a second.
+func round(d time.Duration) time.Duration {
+	switch {
+	case d > time.Second:
+		return d.Round(time.Millisecond)
+	case d > time.Millisecond:
+		return d.Round(time.Microsecond)
+	}
+	return d
+}
+
+var funcs = template.FuncMap{
+	"round": round,
+	"pct": func(f float64) string {
+		return strconv.FormatFloat(f, 'f', 3, 64) + "%"
+	},
+	"indent": func(depth int) string {
+		return strconv.Itoa(depth*16) + "px"
+	},
+	"since": func(t, start time.Time) time.Duration {
+		return round(t.Sub(start))
+	},
+}
+
+const style = `<style>
+body { font: 14px sans-serif; margin: 1em 2em; }
+a { color: #1565c0; text-decoration: none; }
+table { border-collapse: collapse; width: 100%; }
+th, td { text-align: left; padding: 4px 8px; border-bottom: 1px solid #eee; }
+.error { color: #c62828; }
+form input { margin-right: 1em; }
+.row summary { display: flex; cursor: pointer; list-style: none; }
+.name { width: 35%; overflow: hidden; white-space: nowrap; text-overflow: ellipsis; }
+.lane { position: relative; flex: 1; background: #fafafa; }
+.bar { position: absolute; top: 3px; bottom: 3px; min-width: 2px; background: #42a5f5; }
+.row.error .bar { background: #ef5350; }
+.dur { width: 8em; text-align: right; color: #555; }
+.details { margin: 4px 0 8px 35%; font-size: 12px; }
+.details td { padding: 1px 8px; }
+</style>`
+
+var searchTemplate = template.Must(template.New("search").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><head><title>teltrace</title>` + style + `</head><body>
+<h1>Traces</h1>
+<form>
+<label>Service <select name="service"><option value="">all</option>
+{{- range .Services}}<option{{if eq . ($.Query.Get "service")}} selected{{end}}>{{.}}</option>{{end -}}
+</select></label>
+<label>Name <input name="name" value="{{.Query.Get "name"}}"></label>
+<label>Min <input name="min" size="6" placeholder="10ms" value="{{.Query.Get "min"}}"></label>
+<label>Max <input name="max" size="6" placeholder="1s" value="{{.Query.Get "max"}}"></label>
+<label><input type="checkbox" name="error" value="1"{{if .Query.Get "error"}} checked{{end}}> Errors only</label>
+<button>Search</button>
+</form>
+<table>
+<tr><th>Start</th><th>Root</th><th>Services</th><th>Spans</th><th>Duration</th></tr>
+{{- range .Traces}}{{$root := .Root}}
+<tr{{if .Errors}} class="error"{{end}}>
+<td>{{.Start.Format "15:04:05.000"}}</td>
+<td><a href="/trace/{{.ID}}">{{$root.Service}}: {{$root.Name}}</a></td>
+<td>{{range $i, $s := .Services}}{{if $i}}, {{end}}{{$s}}{{end}}</td>
+<td>{{len .Spans}}{{if .Errors}} ({{.Errors}} errors){{end}}</td>
+<td>{{round .Duration}}</td>
+</tr>
+{{- else}}
+<tr><td colspan="5">No traces found.</td></tr>
+{{- end}}
+</table>
+</body></html>
+`))
+
+var traceTemplate = template.Must(template.New("trace").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html><head><title>teltrace: {{.ID}}</title>` + style + `</head><body>
+<p><a href="/">&larr; Traces</a></p>
+{{$root := .Root}}{{$start := .Start}}
+<h1>{{$root.Service}}: {{$root.Name}}</h1>
+<p>Trace {{.ID}}, started {{.Start.Format "2006-01-02 15:04:05.000"}}, {{round .Duration}}, {{len .Spans}} spans.</p>
+{{- range .Waterfall}}
+<details class="row{{if .Error}} error{{end}}">
+<summary>
+<span class="name" style="padding-left: {{indent .Depth}}">{{.Service}}: {{.Name}}</span>
+<span class="lane"><span class="bar" style="left: {{pct .Offset}}; width: {{pct .Width}}"></span></span>
+<span class="dur">{{round .Duration}}</span>
+</summary>
+<div class="details">
+<table>
+<tr><td>span</td><td>{{.SpanID}}{{if .ParentID}} (parent {{.ParentID}}){{end}}</td></tr>
+{{- if .Kind}}<tr><td>kind</td><td>{{.Kind}}</td></tr>{{end}}
+{{- if .Scope}}<tr><td>scope</td><td>{{.Scope}}</td></tr>{{end}}
+<tr><td>start</td><td>+{{since .Start $start}}</td></tr>
+{{- if .Error}}<tr class="error"><td>error</td><td>{{.StatusMessage}}</td></tr>{{end}}
+{{- range .Attributes}}<tr><td>{{.Key}}</td><td>{{.Value}}</td></tr>{{end}}
+</table>
+{{- if .Events}}
+<p>Events</p>
+<table>
+{{- $span := .}}
+{{- range .Events}}<tr><td>+{{since .Time $span.Start}}</td><td>{{.Name}}</td><td>{{range .Attributes}}{{.Key}}={{.Value}} {{end}}</td></tr>{{end}}
+</table>
+{{- end}}
+</div>
+</details>
+{{- end}}
+</body></html>
+`))
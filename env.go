@@ -0,0 +1,130 @@
+package tel
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+)
+
+// EnvPropagator propagates the context through the TRACEPARENT, TRACESTATE
+// and BAGGAGE environment variables, in the W3C Trace Context and Baggage
+// formats. It is used instead of the global propagator, which is usually
+// not set yet when a process extracts the context of its parent.
+var EnvPropagator TextMapPropagator = NewCompositeTextMapPropagator(TraceContext{}, PropagationBaggage{})
+
+// EnvCarrier is a TextMapCarrier over environment variables, mapping keys
+// such as traceparent to the variables named after them in upper case, such
+// as TRACEPARENT.
+type EnvCarrier map[string]string
+
+var _ TextMapCarrier = EnvCarrier(nil)
+
+// NewEnvCarrier returns an EnvCarrier holding the variables of environ, in
+// the "key=value" form of os.Environ.
+func NewEnvCarrier(environ []string) EnvCarrier {
+	c := make(EnvCarrier, len(environ))
+	for _, kv := range environ {
+		if k, v, ok := strings.Cut(kv, "="); ok {
+			c[k] = v
+		}
+	}
+	return c
+}
+
+// envName returns the name of the environment variable of key.
+func envName(key string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		}
+		return '_'
+	}, key)
+}
+
+// Get returns the value of the variable of key.
+func (c EnvCarrier) Get(key string) string {
+	return c[envName(key)]
+}
+
+// Set sets the variable of key to value.
+func (c EnvCarrier) Set(key, value string) {
+	c[envName(key)] = value
+}
+
+// Keys lists the names of the variables, in lower case.
+func (c EnvCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, strings.ToLower(k))
+	}
+	return keys
+}
+
+// Environ returns the variables in the "key=value" form of os.Environ,
+// sorted by name.
+func (c EnvCarrier) Environ() []string {
+	environ := make([]string, 0, len(c))
+	for k, v := range c {
+		environ = append(environ, k+"="+v)
+	}
+	sort.Strings(environ)
+	return environ
+}
+
+// InjectEnv returns a copy of environ with the context of ctx set with
+// EnvPropagator. The variables of a previous context are replaced, so that
+// a stale TRACESTATE or BAGGAGE isn't kept along with a new TRACEPARENT.
+// If ctx has nothing to propagate, environ is returned unchanged.
+func InjectEnv(ctx context.Context, environ []string) []string {
+	c := EnvCarrier{}
+	EnvPropagator.Inject(ctx, c)
+	if len(c) == 0 {
+		return environ
+	}
+	fields := make(map[string]bool)
+	for _, f := range EnvPropagator.Fields() {
+		fields[envName(f)] = true
+	}
+	out := make([]string, 0, len(environ)+len(c))
+	for _, kv := range environ {
+		if k, _, _ := strings.Cut(kv, "="); !fields[k] {
+			out = append(out, kv)
+		}
+	}
+	return append(out, c.Environ()...)
+}
+
+// InjectCommand sets the context of ctx in the environment of cmd, so that
+// the process it starts can continue the trace with ExtractEnv. If cmd.Env
+// is nil, the environment of the current process is used as the base, as
+// exec.Cmd does.
+//
+//	cmd := exec.CommandContext(ctx, "go", "build", "./...")
+//	tel.InjectCommand(ctx, cmd)
+//	err := cmd.Run()
+func InjectCommand(ctx context.Context, cmd *exec.Cmd) {
+	environ := cmd.Env
+	if environ == nil {
+		environ = os.Environ()
+	}
+	cmd.Env = InjectEnv(ctx, environ)
+}
+
+// ExtractEnv returns a copy of ctx with the context set in the environment
+// of the process by its parent, as by InjectCommand. Spans started with it
+// as their parent continue the trace of the parent process:
+//
+//	func main() {
+//		ctx := tel.ExtractEnv(context.Background())
+//		ctx, span := tracer.Start(ctx, "main")
+//		defer span.End()
+//		// ...
+//	}
+func ExtractEnv(ctx context.Context) context.Context {
+	return EnvPropagator.Extract(ctx, NewEnvCarrier(os.Environ()))
+}
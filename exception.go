@@ -0,0 +1,228 @@
+package tel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"runtime"
+	"sync"
+)
+
+// ErrorClassifier decides the status code of a span ending with an error.
+// Errors that are part of the normal operation, such as a canceled request
+// or a resource not found, can be classified as Unset so that they don't
+// mark the span as failed.
+type ErrorClassifier interface {
+	Classify(err error) Code
+}
+
+// ErrorClassifierFunc is a convenience adapter to allow the use of a
+// function as an ErrorClassifier.
+type ErrorClassifierFunc func(err error) Code
+
+// Classify returns f(err).
+func (f ErrorClassifierFunc) Classify(err error) Code {
+	return f(err)
+}
+
+// ErrorAttributer is implemented by errors contributing attributes to the
+// exception events recording them, such as an HTTP status code.
+type ErrorAttributer interface {
+	ErrorAttributes() []KeyValue
+}
+
+// DefaultErrorClassifier classifies errors as Error, except for
+// context.Canceled and the errors wrapping it, which are classified as
+// Unset. An error wrapping several errors, such as one created with
+// errors.Join, is classified as Unset only if every error it wraps is.
+var DefaultErrorClassifier ErrorClassifier = ErrorClassifierFunc(classifyError)
+
+func classifyError(err error) Code {
+	// Check the errors wrapped by a joined error first, as errors.Is
+	// would match it if any of them is context.Canceled.
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range u.Unwrap() {
+			if e != nil && classifyError(e) == Error {
+				return Error
+			}
+		}
+		return Unset
+	}
+	if err == context.Canceled {
+		return Unset
+	}
+	if is, ok := err.(interface{ Is(error) bool }); ok && is.Is(context.Canceled) {
+		return Unset
+	}
+	if e := errors.Unwrap(err); e != nil {
+		return classifyError(e)
+	}
+	return Error
+}
+
+var (
+	errorClassifierMu sync.RWMutex
+	errorClassifier   = DefaultErrorClassifier
+)
+
+// GetErrorClassifier returns the global ErrorClassifier, used by
+// RecordException and the EndFunc returned by Start. If none has been set,
+// DefaultErrorClassifier is returned.
+func GetErrorClassifier() ErrorClassifier {
+	errorClassifierMu.RLock()
+	defer errorClassifierMu.RUnlock()
+	return errorClassifier
+}
+
+// SetErrorClassifier sets c as the global ErrorClassifier.
+func SetErrorClassifier(c ErrorClassifier) {
+	errorClassifierMu.Lock()
+	defer errorClassifierMu.Unlock()
+	errorClassifier = c
+}
+
+type exceptionConfig struct {
+	classifier ErrorClassifier
+	attributes []KeyValue
+	stackTrace bool
+}
+
+// ExceptionOption applies an option to RecordException.
+type ExceptionOption func(*exceptionConfig)
+
+// ExceptionWithClassifier sets the ErrorClassifier deciding the status of
+// the span, instead of the global one.
+func ExceptionWithClassifier(c ErrorClassifier) ExceptionOption {
+	return func(cfg *exceptionConfig) {
+		cfg.classifier = c
+	}
+}
+
+// ExceptionWithAttributes adds attributes to the exception event.
+func ExceptionWithAttributes(attributes ...KeyValue) ExceptionOption {
+	return func(cfg *exceptionConfig) {
+		cfg.attributes = append(cfg.attributes, attributes...)
+	}
+}
+
+// ExceptionWithStackTrace sets whether the stack trace is recorded in the
+// exception event.
+func ExceptionWithStackTrace(b bool) ExceptionOption {
+	return func(cfg *exceptionConfig) {
+		cfg.stackTrace = b
+	}
+}
+
+// Attribute keys of the exception events recorded by RecordException, in
+// addition to exception.type, exception.message and exception.stacktrace.
+const (
+	// ExceptionCauseTypesKey lists the types of the errors err wraps, in
+	// depth-first order.
+	ExceptionCauseTypesKey = Key("exception.cause.types")
+	// ExceptionCauseMessagesKey lists the messages of the errors err wraps,
+	// in the same order as ExceptionCauseTypesKey.
+	ExceptionCauseMessagesKey = Key("exception.cause.messages")
+)
+
+// RecordException records err on span as an exception event and returns the
+// status code the classifier gave to err. The status of span is set to
+// Error if the code is Error, and left unchanged otherwise.
+//
+// The exception.type attribute is the type of the cause of err, the last
+// error of its Unwrap() error chain, rather than that of a wrapper such as
+// the one of fmt.Errorf.
+//
+// The errors err wraps, through Unwrap() error as for fmt.Errorf with %w or
+// Unwrap() []error as for errors.Join, are listed in the
+// exception.cause.types and exception.cause.messages attributes. The errors
+// in the chain implementing ErrorAttributer contribute their attributes to
+// the event.
+func RecordException(span Span, err error, opts ...ExceptionOption) Code {
+	if err == nil {
+		return Unset
+	}
+	cfg := exceptionConfig{classifier: GetErrorClassifier()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	code := cfg.classifier.Classify(err)
+	if !span.IsRecording() {
+		return code
+	}
+
+	var types, messages []string
+	attrs := cfg.attributes
+	if ea, ok := err.(ErrorAttributer); ok {
+		attrs = append(attrs, ea.ErrorAttributes()...)
+	}
+	walkErrors(unwrapErrors(err), func(e error) {
+		types = append(types, fmt.Sprintf("%T", e))
+		messages = append(messages, e.Error())
+		if ea, ok := e.(ErrorAttributer); ok {
+			attrs = append(attrs, ea.ErrorAttributes()...)
+		}
+	})
+	if len(types) > 0 {
+		attrs = append(attrs,
+			ExceptionCauseTypesKey.StringSlice(types),
+			ExceptionCauseMessagesKey.StringSlice(messages))
+	}
+	attrs = append(attrs,
+		AttributeString("exception.type", exceptionType(err)),
+		AttributeString("exception.message", err.Error()))
+	if cfg.stackTrace {
+		attrs = append(attrs, AttributeString("exception.stacktrace", stackTrace()))
+	}
+	span.AddEvent("exception", WithAttributes(attrs...))
+	if code == Error {
+		span.SetStatus(Error, err.Error())
+	}
+	return code
+}
+
+// exceptionType returns the type of the cause of err, formatted as by the
+// RecordError method of the SDK spans.
+func exceptionType(err error) string {
+	for {
+		u, ok := err.(interface{ Unwrap() error })
+		if !ok || u.Unwrap() == nil {
+			break
+		}
+		err = u.Unwrap()
+	}
+	t := reflect.TypeOf(err)
+	if t.PkgPath() == "" && t.Name() == "" {
+		return t.String()
+	}
+	return t.PkgPath() + "." + t.Name()
+}
+
+func stackTrace() string {
+	b := make([]byte, 2048)
+	return string(b[:runtime.Stack(b, false)])
+}
+
+// walkErrors calls fn for each error of errs and the errors they wrap, in
+// depth-first order.
+func walkErrors(errs []error, fn func(error)) {
+	for _, e := range errs {
+		if e == nil {
+			continue
+		}
+		fn(e)
+		walkErrors(unwrapErrors(e), fn)
+	}
+}
+
+// unwrapErrors returns the errors err wraps, supporting both Unwrap() error
+// and Unwrap() []error.
+func unwrapErrors(err error) []error {
+	if u, ok := err.(interface{ Unwrap() []error }); ok {
+		return u.Unwrap()
+	}
+	if e := errors.Unwrap(err); e != nil {
+		return []error{e}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package tel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// joinedError wraps several errors, as the errors.Join of Go 1.20.
+type joinedError []error
+
+func (e joinedError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+func (e joinedError) Unwrap() []error {
+	return e
+}
+
+// canceledError reports itself as context.Canceled through Is.
+type canceledError struct{}
+
+func (canceledError) Error() string { return "stopped" }
+
+func (canceledError) Is(target error) bool { return target == context.Canceled }
+
+func TestDefaultErrorClassifier(t *testing.T) {
+	boom := errors.New("boom")
+	tests := []struct {
+		name string
+		err  error
+		want Code
+	}{
+		{"plain", boom, Error},
+		{"canceled", context.Canceled, Unset},
+		{"deadline", context.DeadlineExceeded, Error},
+		{"wrapped canceled", fmt.Errorf("fetch: %w", context.Canceled), Unset},
+		{"wrapped twice", fmt.Errorf("a: %w", fmt.Errorf("b: %w", context.Canceled)), Unset},
+		{"wrapped plain", fmt.Errorf("fetch: %w", boom), Error},
+		{"Is method", canceledError{}, Unset},
+		{"joined canceled", joinedError{context.Canceled, fmt.Errorf("b: %w", context.Canceled)}, Unset},
+		{"joined mixed", joinedError{context.Canceled, boom}, Error},
+		{"joined mixed reversed", joinedError{boom, context.Canceled}, Error},
+		{"wrapped joined mixed", fmt.Errorf("all: %w", joinedError{context.Canceled, boom}), Error},
+		{"nested joined", joinedError{joinedError{context.Canceled}, fmt.Errorf("x: %w", canceledError{})}, Unset},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DefaultErrorClassifier.Classify(tt.err); got != tt.want {
+				t.Errorf("Classify(%q) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,258 @@
+package telfile
+
+import (
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when exporting to a file that was shut down.
+var ErrClosed = errors.New("telfile: file is closed")
+
+// rotatingFile appends lines to a file, moving it aside when it grows too
+// large or too old. Rotated files are named after the original with the
+// rotation time inserted before the extension, e.g. traces.jsonl becomes
+// traces-20220102T150405.000.jsonl.
+type rotatingFile struct {
+	path string
+	cfg  config
+
+	mu     sync.Mutex
+	file   *os.File
+	gz     *gzip.Writer
+	w      io.Writer
+	size   int64
+	opened time.Time
+	closed bool
+}
+
+func openRotatingFile(path string, cfg config) (*rotatingFile, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, err
+	}
+	f := &rotatingFile{path: path, cfg: cfg}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func (f *rotatingFile) open() error {
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	f.file, f.w = file, file
+	f.size = info.Size()
+	if f.cfg.gzip {
+		// Appending to an existing file adds a new gzip member, which
+		// gzip readers decode as a continuation of the previous ones.
+		f.gz = gzip.NewWriter(file)
+		f.w = f.gz
+		if f.size > 0 {
+			f.size = uncompressedSize(f.path)
+		}
+	}
+	f.opened = time.Now()
+	return nil
+}
+
+// uncompressedSize returns the size of the gzip file at path once
+// decompressed, as counted by the size limit. A member torn by a crash
+// counts as far as it can be decompressed.
+func uncompressedSize(path string) int64 {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return 0
+	}
+	defer gz.Close()
+	n, _ := io.Copy(io.Discard, gz)
+	return n
+}
+
+func (f *rotatingFile) closeFile() error {
+	var err error
+	if f.gz != nil {
+		err = f.gz.Close()
+		f.gz = nil
+	}
+	if cerr := f.file.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// writeLine writes b followed by a newline.
+func (f *rotatingFile) writeLine(b []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return ErrClosed
+	}
+
+	n := int64(len(b) + 1)
+	if f.size > 0 && f.shouldRotate(n, time.Now()) {
+		if err := f.rotate(); err != nil {
+			return err
+		}
+	}
+	if _, err := f.w.Write(append(b, '\n')); err != nil {
+		return err
+	}
+	f.size += n
+	if f.gz != nil {
+		return f.gz.Flush()
+	}
+	return nil
+}
+
+func (f *rotatingFile) shouldRotate(n int64, now time.Time) bool {
+	if f.cfg.maxSize > 0 && f.size+n > f.cfg.maxSize {
+		return true
+	}
+	return f.cfg.rotationInterval > 0 && now.Sub(f.opened) >= f.cfg.rotationInterval
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.closeFile(); err != nil {
+		return err
+	}
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+	dir, prefix, ext := f.split()
+	stamp := time.Now().UTC().Format(stampLayout)
+	name := filepath.Join(dir, prefix+"-"+stamp+ext)
+	// Files rotated within the same millisecond get increasing suffixes,
+	// even once the first ones were pruned, so they keep their order.
+	at, _ := time.Parse(stampLayout, stamp)
+	n := -1
+	for _, b := range backups {
+		if b.stamp.Equal(at) && b.n > n {
+			n = b.n
+		}
+	}
+	if n >= 0 || fileExists(name) {
+		name = filepath.Join(dir, prefix+"-"+stamp+"."+strconv.Itoa(n+1)+ext)
+	}
+	if err := os.Rename(f.path, name); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	return f.prune()
+}
+
+// stampLayout is the layout of the rotation time in the name of rotated
+// files.
+const stampLayout = "20060102T150405.000"
+
+// backup is a rotated file.
+type backup struct {
+	path  string
+	stamp time.Time
+	n     int // suffix telling apart files rotated at the same time.
+}
+
+// backups returns the rotated files, oldest first.
+func (f *rotatingFile) backups() ([]backup, error) {
+	dir, prefix, ext := f.split()
+	candidates, err := filepath.Glob(filepath.Join(dir, prefix+"-*"+ext))
+	if err != nil {
+		return nil, err
+	}
+	// Only list the files named by rotate, leaving others matching the
+	// glob, such as traces-old.jsonl, alone.
+	backupName := regexp.MustCompile(`^` + regexp.QuoteMeta(prefix) + `-(\d{8}T\d{6}\.\d{3})(?:\.(\d+))?` + regexp.QuoteMeta(ext) + `$`)
+	var backups []backup
+	for _, path := range candidates {
+		m := backupName.FindStringSubmatch(filepath.Base(path))
+		if m == nil {
+			continue
+		}
+		stamp, err := time.Parse(stampLayout, m[1])
+		if err != nil {
+			continue
+		}
+		b := backup{path: path, stamp: stamp}
+		if m[2] != "" {
+			if b.n, err = strconv.Atoi(m[2]); err != nil {
+				continue
+			}
+		}
+		backups = append(backups, b)
+	}
+	// Files rotated within the same millisecond are told apart by their
+	// suffix, which sorts after the one without: traces-<stamp>.jsonl,
+	// then traces-<stamp>.1.jsonl, traces-<stamp>.2.jsonl, and so on.
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].stamp.Equal(backups[j].stamp) {
+			return backups[i].stamp.Before(backups[j].stamp)
+		}
+		return backups[i].n < backups[j].n
+	})
+	return backups, nil
+}
+
+// prune removes the oldest rotated files beyond the configured maximum.
+func (f *rotatingFile) prune() error {
+	if f.cfg.maxBackups <= 0 {
+		return nil
+	}
+	backups, err := f.backups()
+	if err != nil {
+		return err
+	}
+	for len(backups) > f.cfg.maxBackups {
+		if err := os.Remove(backups[0].path); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
+
+// split returns the directory, name and extensions of the file, where the
+// extensions start at the first dot of the name, e.g. .jsonl.gz.
+func (f *rotatingFile) split() (dir, prefix, ext string) {
+	dir, name := filepath.Split(f.path)
+	if i := strings.Index(name, "."); i > 0 {
+		return dir, name[:i], name[i:]
+	}
+	return dir, name, ""
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+func (f *rotatingFile) close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil
+	}
+	f.closed = true
+	return f.closeFile()
+}
@@ -0,0 +1,70 @@
+package telfile
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+type config struct {
+	maxSize             int64
+	rotationInterval    time.Duration
+	maxBackups          int
+	gzip                bool
+	temporalitySelector aggregation.TemporalitySelector
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		temporalitySelector: aggregation.CumulativeTemporalitySelector(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Option configures a file exporter.
+type Option func(*config)
+
+// WithMaxSize rotates the file once writing the next line would make it
+// larger than bytes. The size is measured before compression. If unset, or
+// zero, the file is not rotated by size.
+func WithMaxSize(bytes int64) Option {
+	return func(cfg *config) {
+		cfg.maxSize = bytes
+	}
+}
+
+// WithRotationInterval rotates the file once it has been open for longer
+// than interval. If unset, or zero, the file is not rotated by time.
+func WithRotationInterval(interval time.Duration) Option {
+	return func(cfg *config) {
+		cfg.rotationInterval = interval
+	}
+}
+
+// WithMaxBackups sets how many rotated files are kept. The oldest are
+// removed first. If unset, or zero, all rotated files are kept.
+func WithMaxBackups(n int) Option {
+	return func(cfg *config) {
+		cfg.maxBackups = n
+	}
+}
+
+// WithGzip compresses the file with gzip. Each batch is flushed as it is
+// written, so the file can be read while it is being written. Give the
+// file a .gz suffix to let other tools detect the compression.
+func WithGzip() Option {
+	return func(cfg *config) {
+		cfg.gzip = true
+	}
+}
+
+// WithTemporalitySelector sets the aggregation.TemporalitySelector used by
+// the metric exporter. If unset, cumulative temporality is used.
+func WithTemporalitySelector(selector aggregation.TemporalitySelector) Option {
+	return func(cfg *config) {
+		cfg.temporalitySelector = selector
+	}
+}
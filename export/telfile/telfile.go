@@ -0,0 +1,142 @@
+package telfile
+
+import (
+	"context"
+
+	"github.com/henvic/tel/internal/otlpconv"
+	"github.com/henvic/tel/internal/otlpjson"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	colmetricpb "go.opentelemetry.io/proto/otlp/collector/metrics/v1"
+	coltracepb "go.opentelemetry.io/proto/otlp/collector/trace/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+// SpanExporter writes spans to a file using the OTLP/JSON encoding of
+// ExportTraceServiceRequest, one request per line, as read by collectors
+// supporting the OTLP file format.
+type SpanExporter struct {
+	file *rotatingFile
+}
+
+// NewSpanExporter returns a SpanExporter appending to the file at path.
+func NewSpanExporter(path string, opts ...Option) (*SpanExporter, error) {
+	f, err := openRotatingFile(path, newConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &SpanExporter{file: f}, nil
+}
+
+// ExportSpans writes spans to the file as a single line.
+func (e *SpanExporter) ExportSpans(ctx context.Context, spans []telsdk.ReadOnlySpan) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(spans) == 0 {
+		return nil
+	}
+	b, err := otlpjson.Marshal(&coltracepb.ExportTraceServiceRequest{
+		ResourceSpans: otlpconv.Spans(spans),
+	})
+	if err != nil {
+		return err
+	}
+	return e.file.writeLine(b)
+}
+
+// Shutdown closes the file.
+func (e *SpanExporter) Shutdown(ctx context.Context) error {
+	return e.file.close()
+}
+
+// MetricExporter writes metrics to a file using the OTLP/JSON encoding of
+// ExportMetricsServiceRequest, one request per line, as read by collectors
+// supporting the OTLP file format.
+type MetricExporter struct {
+	file                *rotatingFile
+	temporalitySelector aggregation.TemporalitySelector
+}
+
+// NewMetricExporter returns a MetricExporter appending to the file at path.
+func NewMetricExporter(path string, opts ...Option) (*MetricExporter, error) {
+	cfg := newConfig(opts)
+	f, err := openRotatingFile(path, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &MetricExporter{file: f, temporalitySelector: cfg.temporalitySelector}, nil
+}
+
+// Export writes the checkpoint to the file as a single line.
+func (e *MetricExporter) Export(ctx context.Context, res *telsdk.Resource, reader telsdk.InstrumentationLibraryReader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	rm, err := otlpconv.ResourceMetrics(e, res, reader)
+	if rm == nil {
+		return err
+	}
+	b, merr := otlpjson.Marshal(&colmetricpb.ExportMetricsServiceRequest{
+		ResourceMetrics: []*metricpb.ResourceMetrics{rm},
+	})
+	if merr != nil {
+		return merr
+	}
+	if werr := e.file.writeLine(b); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// TemporalityFor returns the temporality set with WithTemporalitySelector.
+func (e *MetricExporter) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind) aggregation.Temporality {
+	return e.temporalitySelector.TemporalityFor(desc, kind)
+}
+
+// Shutdown closes the file. Stop the controller using the exporter first.
+func (e *MetricExporter) Shutdown(ctx context.Context) error {
+	return e.file.close()
+}
+
+// LogExporter writes log records to a file using the OTLP/JSON encoding of
+// ExportLogsServiceRequest, one request per line, as read by collectors
+// supporting the OTLP file format.
+type LogExporter struct {
+	file *rotatingFile
+}
+
+var _ telsdk.LogExporter = (*LogExporter)(nil)
+
+// NewLogExporter returns a LogExporter appending to the file at path.
+func NewLogExporter(path string, opts ...Option) (*LogExporter, error) {
+	f, err := openRotatingFile(path, newConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+	return &LogExporter{file: f}, nil
+}
+
+// ExportLogs writes records to the file as a single line.
+func (e *LogExporter) ExportLogs(ctx context.Context, records []telsdk.LogData) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return nil
+	}
+	b, err := otlpjson.Marshal(&collogspb.ExportLogsServiceRequest{
+		ResourceLogs: otlpconv.Logs(records),
+	})
+	if err != nil {
+		return err
+	}
+	return e.file.writeLine(b)
+}
+
+// Shutdown closes the file.
+func (e *LogExporter) Shutdown(ctx context.Context) error {
+	return e.file.close()
+}
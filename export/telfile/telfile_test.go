@@ -0,0 +1,240 @@
+package telfile
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/internal/otlpconv"
+	"github.com/henvic/tel/internal/otlpjson"
+	"github.com/henvic/tel/telsdk"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+)
+
+func TestLogExporterAnyValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs.jsonl")
+	exp, err := NewLogExporter(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := tel.AnyMap(
+		tel.AnyAttribute("raw", tel.AnyBytes([]byte{0, 1})),
+		tel.AnyAttribute("list", tel.AnyArray(tel.AnyString("a"), tel.AnyOf(tel.BoolValue(true)))),
+	)
+	err = exp.ExportLogs(context.Background(), []telsdk.LogData{{
+		LogRecord: tel.LogRecord{Body: body},
+		Resource:  telsdk.Empty(),
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := exp.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	if !sc.Scan() {
+		t.Fatal("no line written")
+	}
+	var req collogspb.ExportLogsServiceRequest
+	if err := otlpjson.Unmarshal(sc.Bytes(), &req); err != nil {
+		t.Fatal(err)
+	}
+	lr := req.ResourceLogs[0].ScopeLogs[0].LogRecords[0]
+	if got := otlpconv.LogValue(lr.Body); got.Emit() != body.Emit() {
+		t.Errorf("got body %s, want %s", got.Emit(), body.Emit())
+	}
+}
+
+func writeLines(t *testing.T, f *rotatingFile, lines ...string) {
+	t.Helper()
+	for _, line := range lines {
+		if err := f.writeLine([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// backups returns the contents of the files rotated from path, oldest
+// first.
+func backups(t *testing.T, path string) []string {
+	t.Helper()
+	f := &rotatingFile{path: path}
+	dir, prefix, ext := f.split()
+	paths, err := filepath.Glob(filepath.Join(dir, prefix+"-*"+ext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var contents []string
+	for _, p := range paths {
+		b, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		contents = append(contents, string(b))
+	}
+	sort.Strings(contents)
+	return contents
+}
+
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestRotateBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	f, err := openRotatingFile(path, newConfig([]Option{WithMaxSize(10)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, f, "aaaa", "bbbb", "cccc")
+	if err := f.close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "cccc\n" {
+		t.Errorf("got %q, want the line past the limit in a new file", got)
+	}
+	if got := backups(t, path); len(got) != 1 || got[0] != "aaaa\nbbbb\n" {
+		t.Errorf("got backups %q, want the lines within the limit", got)
+	}
+}
+
+func TestRotateByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	f, err := openRotatingFile(path, newConfig([]Option{WithRotationInterval(time.Hour)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, f, "a", "b")
+	f.opened = f.opened.Add(-time.Hour)
+	writeLines(t, f, "c")
+	if err := f.close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := readFile(t, path); got != "c\n" {
+		t.Errorf("got %q, want the line written after the interval in a new file", got)
+	}
+	if got := backups(t, path); len(got) != 1 || got[0] != "a\nb\n" {
+		t.Errorf("got backups %q, want the lines written within the interval", got)
+	}
+}
+
+func TestGzipReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl.gz")
+	cfg := newConfig([]Option{WithGzip(), WithMaxSize(8)})
+	f, err := openRotatingFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLines(t, f, "aaa")
+	if err := f.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = openRotatingFile(path, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.size != 4 {
+		t.Errorf("got size %d after reopening, want the uncompressed size 4", f.size)
+	}
+	writeLines(t, f, "bbb", "ccc")
+	if err := f.close(); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := string(b); got != "ccc\n" {
+		t.Errorf("got %q, want the line past the limit in a new file", got)
+	}
+	backups, err := filepath.Glob(filepath.Join(filepath.Dir(path), "traces-*.jsonl.gz"))
+	if err != nil || len(backups) != 1 {
+		t.Fatalf("got backups %v (%v), want one", backups, err)
+	}
+	if got := uncompressedSize(backups[0]); got != 8 {
+		t.Errorf("got backup of %d bytes, want both members of 4 bytes", got)
+	}
+}
+
+func TestMaxBackups(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "traces.jsonl")
+	f, err := openRotatingFile(path, newConfig([]Option{WithMaxSize(2), WithMaxBackups(2)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rotations likely happen within the same millisecond, telling
+	// the files apart by their suffix.
+	writeLines(t, f, "0", "1", "2", "3", "4")
+	if err := f.close(); err != nil {
+		t.Fatal(err)
+	}
+	if got := backups(t, path); len(got) != 2 || got[0] != "2\n" || got[1] != "3\n" {
+		t.Errorf("got backups %q, want the two most recent", got)
+	}
+}
+
+func TestPruneOrder(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{
+		"traces-20220102T150405.000.jsonl",
+		"traces-20220102T150405.000.2.jsonl",
+		"traces-20220102T150405.000.10.jsonl",
+		"traces-20220102T150406.000.jsonl",
+		"traces-old.jsonl",
+	}
+	for _, name := range names {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f := &rotatingFile{path: filepath.Join(dir, "traces.jsonl"), cfg: newConfig([]Option{WithMaxBackups(2)})}
+	if err := f.prune(); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	want := []string{
+		"traces-20220102T150405.000.10.jsonl",
+		"traces-20220102T150406.000.jsonl",
+		"traces-old.jsonl",
+	}
+	if strings.Join(got, " ") != strings.Join(want, " ") {
+		t.Errorf("got files %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,205 @@
+package teljaeger
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/jaeger"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const (
+	// udpPacketMaxLength is the largest packet the Jaeger agent reads.
+	udpPacketMaxLength = 65000
+	// emitBatchOverhead is the size of the envelope of agent packets.
+	emitBatchOverhead = 70
+)
+
+// Jaeger exports OpenTelemetry spans to a Jaeger agent or collector.
+//
+// Jaeger wraps the upstream jaeger.Exporter, which it used to be an alias
+// of, to split batches and truncate spans that are too large. Code holding
+// a *jaeger.Exporter should use a tracesdk.SpanExporter instead.
+type Jaeger struct {
+	exporter *jaeger.Exporter
+	// maxBatchSize is the largest encoded size of a single upload, or zero
+	// to send batches whole.
+	maxBatchSize int
+	// maxSpanSize is the largest encoded size of a span together with its
+	// process, or zero to send spans whole.
+	maxSpanSize int
+}
+
+var _ tracesdk.SpanExporter = (*Jaeger)(nil)
+
+func newAgent(cfg *agentConfig) (*Jaeger, error) {
+	exp, err := jaeger.New(jaeger.WithAgentEndpoint(cfg.options...))
+	if err != nil {
+		return nil, err
+	}
+	// The agent client already splits batches into packets, but drops
+	// spans that don't fit in one.
+	return &Jaeger{exporter: exp, maxSpanSize: cfg.maxPacketSize - emitBatchOverhead}, nil
+}
+
+func newCollector(cfg *collectorConfig) (*Jaeger, error) {
+	if cfg == nil {
+		cfg = &collectorConfig{}
+	}
+	opts := cfg.options
+	if client := cfg.httpClient(); client != nil {
+		opts = append(opts, jaeger.WithHTTPClient(client))
+	}
+	exp, err := jaeger.New(jaeger.WithCollectorEndpoint(opts...))
+	if err != nil {
+		return nil, err
+	}
+	return &Jaeger{exporter: exp, maxBatchSize: cfg.maxBatchSize, maxSpanSize: cfg.maxBatchSize}, nil
+}
+
+// httpClient returns the client to use for the collector, or nil for the
+// default.
+func (cfg *collectorConfig) httpClient() *http.Client {
+	if len(cfg.headers) == 0 && !cfg.gzip {
+		return cfg.client
+	}
+	client := http.DefaultClient
+	if cfg.client != nil {
+		client = cfg.client
+	}
+	c := *client
+	c.Transport = &transport{
+		base:    client.Transport,
+		headers: cfg.headers,
+		gzip:    cfg.gzip,
+	}
+	return &c
+}
+
+// ExportSpans exports spans to Jaeger, splitting them into batches that fit
+// the maximum size.
+func (e *Jaeger) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	if e.maxSpanSize > 0 {
+		spans = truncateSpans(spans, e.maxSpanSize)
+	}
+	if e.maxBatchSize <= 0 {
+		return e.exporter.ExportSpans(ctx, spans)
+	}
+	var err error
+	for _, batch := range splitBatches(spans, e.maxBatchSize) {
+		if berr := e.exporter.ExportSpans(ctx, batch); berr != nil && err == nil {
+			err = berr
+		}
+	}
+	return err
+}
+
+// Shutdown stops the exporter. This will close all connections and release
+// all resources held by the exporter.
+func (e *Jaeger) Shutdown(ctx context.Context) error {
+	return e.exporter.Shutdown(ctx)
+}
+
+// MarshalLog is the marshaling function used by the logging system to represent this exporter.
+func (e *Jaeger) MarshalLog() interface{} {
+	return e.exporter.MarshalLog()
+}
+
+// truncateSpans truncates the spans whose estimated encoded size, including
+// the process of the resource they belong to, is larger than max.
+func truncateSpans(spans []tracesdk.ReadOnlySpan, max int) []tracesdk.ReadOnlySpan {
+	var out []tracesdk.ReadOnlySpan
+	for i, s := range spans {
+		if s == nil {
+			continue
+		}
+		if limit := max - processSize(s.Resource()); spanSize(s) > limit {
+			if out == nil {
+				out = append(make([]tracesdk.ReadOnlySpan, 0, len(spans)), spans[:i]...)
+			}
+			s = truncate(s, limit)
+		}
+		if out != nil {
+			out = append(out, s)
+		}
+	}
+	if out == nil {
+		return spans
+	}
+	return out
+}
+
+// splitBatches splits spans into batches whose estimated encoded size,
+// including the process of the resource they belong to, is at most max.
+func splitBatches(spans []tracesdk.ReadOnlySpan, max int) [][]tracesdk.ReadOnlySpan {
+	var (
+		batches [][]tracesdk.ReadOnlySpan
+		batch   []tracesdk.ReadOnlySpan
+		size    int
+		res     attribute.Distinct
+	)
+	for _, s := range spans {
+		if s == nil {
+			continue
+		}
+		// Spans from different resources are sent as separate Jaeger
+		// batches, each with its own process.
+		psize, ssize := processSize(s.Resource()), spanSize(s)
+		if key := s.Resource().Equivalent(); len(batch) > 0 && (size+ssize > max || key != res) {
+			batches = append(batches, batch)
+			batch = nil
+		}
+		if len(batch) == 0 {
+			size = psize
+			res = s.Resource().Equivalent()
+		}
+		batch = append(batch, s)
+		size += ssize
+	}
+	if len(batch) > 0 {
+		batches = append(batches, batch)
+	}
+	return batches
+}
+
+// transport adds headers and compression to requests to the collector.
+type transport struct {
+	base    http.RoundTripper
+	headers map[string]string
+	gzip    bool
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for k, v := range t.headers {
+		req.Header.Set(k, v)
+	}
+	if t.gzip && req.Body != nil {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		_, err := io.Copy(gz, req.Body)
+		req.Body.Close()
+		if err == nil {
+			err = gz.Close()
+		}
+		if err != nil {
+			return nil, err
+		}
+		b := buf.Bytes()
+		req.Body = io.NopCloser(bytes.NewReader(b))
+		req.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(b)), nil
+		}
+		req.ContentLength = int64(len(b))
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	base := t.base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
@@ -0,0 +1,95 @@
+package teljaeger
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// The sizes below are upper bounds of the Thrift binary protocol encoding
+// used to send spans to the collector, which are also upper bounds of the
+// compact protocol used by the agent. The thrift encoder of the Jaeger
+// exporter is internal, so the encoded size is estimated from the span
+// data instead.
+const (
+	fieldSize  = 3      // field header
+	stringSize = 4      // length prefix
+	listSize   = 5      // list header
+	stopSize   = 1      // struct end
+	i64Size    = 3 + 10 // field header and largest varint
+	i32Size    = 3 + 5
+
+	// tagSize is the size of a tag without its key and value: the key
+	// header, the value type and the value header.
+	tagSize = fieldSize + stringSize + i32Size + fieldSize + stringSize + stopSize
+	// refSize is the size of a span reference.
+	refSize = i32Size + 3*i64Size + stopSize
+	// fixedSpanSize is the size of a span without strings, tags, logs or
+	// references: IDs, flags, times and list headers.
+	fixedSpanSize = 5*i64Size + i32Size + fieldSize + stringSize + 3*(fieldSize+listSize) + stopSize
+	// extraTagsSize bounds the tags the exporter derives from the span
+	// kind and status.
+	extraTagsSize = 4*tagSize + 64
+)
+
+func tagsSize(attrs []attribute.KeyValue) int {
+	var n int
+	for _, kv := range attrs {
+		n += tagSize + len(kv.Key) + valueSize(kv.Value)
+	}
+	return n
+}
+
+func valueSize(v attribute.Value) int {
+	switch v.Type() {
+	case attribute.STRING:
+		return len(v.AsString())
+	case attribute.BOOL, attribute.INT64, attribute.FLOAT64:
+		return 10
+	}
+	// Slices are sent as their JSON encoding.
+	return len(v.Emit())
+}
+
+// processSize estimates the encoded size of the Jaeger process of res.
+func processSize(res *resource.Resource) int {
+	return fieldSize + stringSize + fieldSize + listSize + tagsSize(res.Attributes()) + stopSize
+}
+
+// spanSize estimates the encoded size of s.
+func spanSize(s tracesdk.ReadOnlySpan) int {
+	lib := s.InstrumentationLibrary()
+	n := fixedSpanSize + len(s.Name()) + tagsSize(s.Attributes()) + extraTagsSize +
+		2*tagSize + len(lib.Name) + len(lib.Version) + len(s.Status().Description) +
+		len(s.Links())*refSize
+	for _, e := range s.Events() {
+		n += eventSize(e)
+	}
+	return n
+}
+
+func eventSize(e tracesdk.Event) int {
+	return i64Size + fieldSize + listSize + tagSize + len(e.Name) + tagSize + tagsSize(e.Attributes) + stopSize
+}
+
+// truncate returns a copy of s dropping events, newest first, and then
+// attributes, last first, until its estimated size is at most max. The
+// dropped counts are updated accordingly.
+func truncate(s tracesdk.ReadOnlySpan, max int) tracesdk.ReadOnlySpan {
+	stub := tracetest.SpanStubFromReadOnlySpan(s)
+	size := spanSize(s)
+	for len(stub.Events) > 0 && size > max {
+		last := stub.Events[len(stub.Events)-1]
+		stub.Events = stub.Events[:len(stub.Events)-1]
+		stub.DroppedEvents++
+		size -= eventSize(last)
+	}
+	for len(stub.Attributes) > 0 && size > max {
+		last := stub.Attributes[len(stub.Attributes)-1]
+		stub.Attributes = stub.Attributes[:len(stub.Attributes)-1]
+		stub.DroppedAttributes++
+		size -= tagSize + len(last.Key) + valueSize(last.Value)
+	}
+	return stub.Snapshot()
+}
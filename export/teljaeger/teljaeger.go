@@ -11,13 +11,26 @@ import (
 // New returns an OTel Exporter implementation that exports the collected
 // spans to Jaeger.
 func New(endpointOption EndpointOption) (*Jaeger, error) {
-	return jaeger.New(endpointOption)
+	var cfg endpointConfig
+	endpointOption(&cfg)
+	if cfg.agent != nil {
+		return newAgent(cfg.agent)
+	}
+	return newCollector(cfg.collector)
 }
 
-// Jaeger exports OpenTelemetry spans to a Jaeger agent or collector.
-type Jaeger = jaeger.Exporter
-
-type EndpointOption = jaeger.EndpointOption
+// EndpointOption configures a Jaeger endpoint.
+//
+// EndpointOption, AgentEndpointOption and CollectorEndpointOption are not
+// aliases of the types of go.opentelemetry.io/otel/exporters/jaeger, as
+// they also hold the options of this package. Options of that package are
+// passed with AgentEndpointOptions and CollectorEndpointOptions.
+type EndpointOption func(*endpointConfig)
+
+type endpointConfig struct {
+	agent     *agentConfig
+	collector *collectorConfig
+}
 
 // WithAgentEndpoint configures the Jaeger exporter to send spans to a Jaeger agent
 // over compact thrift protocol. This will use the following environment variables for
@@ -29,17 +42,40 @@ type EndpointOption = jaeger.EndpointOption
 // The passed options will take precedence over any environment variables and default values
 // will be used if neither are provided.
 func WithAgentEndpoint(options ...AgentEndpointOption) EndpointOption {
-	return jaeger.WithAgentEndpoint(options...)
+	return func(cfg *endpointConfig) {
+		cfg.agent = &agentConfig{maxPacketSize: udpPacketMaxLength}
+		for _, opt := range options {
+			opt(cfg.agent)
+		}
+	}
 }
 
-type AgentEndpointOption = jaeger.AgentEndpointOption
+// AgentEndpointOption configures a Jaeger agent endpoint.
+type AgentEndpointOption func(*agentConfig)
+
+type agentConfig struct {
+	options       []jaeger.AgentEndpointOption
+	maxPacketSize int
+}
+
+func agentOption(opt jaeger.AgentEndpointOption) AgentEndpointOption {
+	return AgentEndpointOptions(opt)
+}
+
+// AgentEndpointOptions passes options of the upstream Jaeger exporter to
+// the agent endpoint.
+func AgentEndpointOptions(options ...jaeger.AgentEndpointOption) AgentEndpointOption {
+	return func(cfg *agentConfig) {
+		cfg.options = append(cfg.options, options...)
+	}
+}
 
 // WithAgentHost sets a host to be used in the agent client endpoint.
 // This option overrides any value set for the
 // OTEL_EXPORTER_JAEGER_AGENT_HOST environment variable.
 // If this option is not passed and the env var is not set, "localhost" will be used by default.
 func WithAgentHost(host string) AgentEndpointOption {
-	return jaeger.WithAgentHost(host)
+	return agentOption(jaeger.WithAgentHost(host))
 }
 
 // WithAgentPort sets a port to be used in the agent client endpoint.
@@ -47,27 +83,38 @@ func WithAgentHost(host string) AgentEndpointOption {
 // OTEL_EXPORTER_JAEGER_AGENT_PORT environment variable.
 // If this option is not passed and the env var is not set, "6831" will be used by default.
 func WithAgentPort(port string) AgentEndpointOption {
-	return jaeger.WithAgentPort(port)
+	return agentOption(jaeger.WithAgentPort(port))
 }
 
 // WithLogger sets a logger to be used by agent client.
 func WithLogger(logger *log.Logger) AgentEndpointOption {
-	return jaeger.WithLogger(logger)
+	return agentOption(jaeger.WithLogger(logger))
 }
 
 // WithDisableAttemptReconnecting sets option to disable reconnecting udp client.
 func WithDisableAttemptReconnecting() AgentEndpointOption {
-	return jaeger.WithDisableAttemptReconnecting()
+	return agentOption(jaeger.WithDisableAttemptReconnecting())
 }
 
 // WithAttemptReconnectingInterval sets the interval between attempts to re resolve agent endpoint.
 func WithAttemptReconnectingInterval(interval time.Duration) AgentEndpointOption {
-	return jaeger.WithAttemptReconnectingInterval(interval)
+	return agentOption(jaeger.WithAttemptReconnectingInterval(interval))
 }
 
 // WithMaxPacketSize sets the maximum UDP packet size for transport to the Jaeger agent.
+//
+// Batches are split so each packet fits. Spans too large to fit in a
+// packet on their own have events, and then attributes, dropped until they
+// fit, instead of being dropped whole.
 func WithMaxPacketSize(size int) AgentEndpointOption {
-	return jaeger.WithMaxPacketSize(size)
+	return func(cfg *agentConfig) {
+		cfg.options = append(cfg.options, jaeger.WithMaxPacketSize(size))
+		if size > 0 && size < udpPacketMaxLength {
+			cfg.maxPacketSize = size
+		} else {
+			cfg.maxPacketSize = udpPacketMaxLength
+		}
+	}
 }
 
 // WithCollectorEndpoint defines the full URL to the Jaeger HTTP Thrift collector. This will
@@ -81,10 +128,37 @@ func WithMaxPacketSize(size int) AgentEndpointOption {
 // If neither values are provided for the endpoint, the default value of "http://localhost:14268/api/traces" will be used.
 // If neither values are provided for the username or the password, they will not be set since there is no default.
 func WithCollectorEndpoint(options ...CollectorEndpointOption) EndpointOption {
-	return jaeger.WithCollectorEndpoint(options...)
+	return func(cfg *endpointConfig) {
+		cfg.collector = &collectorConfig{}
+		for _, opt := range options {
+			opt(cfg.collector)
+		}
+	}
+}
+
+// CollectorEndpointOption configures a Jaeger collector endpoint.
+type CollectorEndpointOption func(*collectorConfig)
+
+type collectorConfig struct {
+	options      []jaeger.CollectorEndpointOption
+	client       *http.Client
+	headers      map[string]string
+	gzip         bool
+	maxBatchSize int
+}
+
+func collectorOption(opt jaeger.CollectorEndpointOption) CollectorEndpointOption {
+	return CollectorEndpointOptions(opt)
 }
 
-type CollectorEndpointOption = jaeger.CollectorEndpointOption
+// CollectorEndpointOptions passes options of the upstream Jaeger exporter
+// to the collector endpoint. A jaeger.WithHTTPClient option is overridden
+// by WithHeaders and WithGzip; use WithHTTPClient instead.
+func CollectorEndpointOptions(options ...jaeger.CollectorEndpointOption) CollectorEndpointOption {
+	return func(cfg *collectorConfig) {
+		cfg.options = append(cfg.options, options...)
+	}
+}
 
 // WithEndpoint is the URL for the Jaeger collector that spans are sent to.
 // This option overrides any value set for the
@@ -92,7 +166,7 @@ type CollectorEndpointOption = jaeger.CollectorEndpointOption
 // If this option is not passed and the environment variable is not set,
 // "http://localhost:14268/api/traces" will be used by default.
 func WithEndpoint(endpoint string) CollectorEndpointOption {
-	return jaeger.WithEndpoint(endpoint)
+	return collectorOption(jaeger.WithEndpoint(endpoint))
 }
 
 // WithUsername sets the username to be used in the authorization header sent for all requests to the collector.
@@ -100,7 +174,7 @@ func WithEndpoint(endpoint string) CollectorEndpointOption {
 // OTEL_EXPORTER_JAEGER_USER environment variable.
 // If this option is not passed and the environment variable is not set, no username will be set.
 func WithUsername(username string) CollectorEndpointOption {
-	return jaeger.WithUsername(username)
+	return collectorOption(jaeger.WithUsername(username))
 }
 
 // WithPassword sets the password to be used in the authorization header sent for all requests to the collector.
@@ -108,10 +182,40 @@ func WithUsername(username string) CollectorEndpointOption {
 // OTEL_EXPORTER_JAEGER_PASSWORD environment variable.
 // If this option is not passed and the environment variable is not set, no password will be set.
 func WithPassword(password string) CollectorEndpointOption {
-	return jaeger.WithPassword(password)
+	return collectorOption(jaeger.WithPassword(password))
 }
 
 // WithHTTPClient sets the http client to be used to make request to the collector endpoint.
 func WithHTTPClient(client *http.Client) CollectorEndpointOption {
-	return jaeger.WithHTTPClient(client)
+	return func(cfg *collectorConfig) {
+		cfg.client = client
+	}
+}
+
+// WithHeaders sets headers sent with every request to the collector, such
+// as a bearer token for a gateway in front of it. An Authorization header
+// takes precedence over WithUsername and WithPassword.
+func WithHeaders(headers map[string]string) CollectorEndpointOption {
+	return func(cfg *collectorConfig) {
+		cfg.headers = headers
+	}
+}
+
+// WithGzip compresses requests to the collector with gzip.
+func WithGzip() CollectorEndpointOption {
+	return func(cfg *collectorConfig) {
+		cfg.gzip = true
+	}
+}
+
+// WithMaxBatchSize splits batches so the body of each request to the
+// collector is at most bytes long before compression. Spans too large to
+// be sent on their own have events, and then attributes, dropped until
+// they fit. Sizes are estimated conservatively from the span data, so
+// requests are usually somewhat smaller than the limit. If unset, or zero,
+// each batch is sent in a single request.
+func WithMaxBatchSize(bytes int) CollectorEndpointOption {
+	return func(cfg *collectorConfig) {
+		cfg.maxBatchSize = bytes
+	}
 }
@@ -0,0 +1,282 @@
+package telotlp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/henvic/tel/internal/otlpconv"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric/otlpmetrichttp"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// LogHTTPNoCompression tells the driver to send payloads without
+	// compression.
+	LogHTTPNoCompression = otlpmetrichttp.NoCompression
+	// LogHTTPGzipCompression tells the driver to send payloads after
+	// compressing them with gzip.
+	LogHTTPGzipCompression = otlpmetrichttp.GzipCompression
+)
+
+// LogHTTPRetryConfig defines configuration for retrying batches in case of
+// export failure using an exponential backoff.
+type LogHTTPRetryConfig = otlptracehttp.RetryConfig
+
+type logHTTPConfig struct {
+	endpoint    string
+	urlPath     string
+	insecure    bool
+	tlsConfig   *tls.Config
+	headers     map[string]string
+	compression Compression
+	timeout     time.Duration
+	retry       LogHTTPRetryConfig
+}
+
+// LogHTTPOption applies an option to the HTTP log exporter.
+type LogHTTPOption func(*logHTTPConfig)
+
+// WithLogHTTPEndpoint allows one to set the address of the collector
+// endpoint that the driver will use to send logs. If unset, it will instead
+// try to use the default endpoint (localhost:4318). Note that the endpoint
+// must not contain any URL path.
+func WithLogHTTPEndpoint(endpoint string) LogHTTPOption {
+	return func(cfg *logHTTPConfig) {
+		cfg.endpoint = endpoint
+	}
+}
+
+// WithLogHTTPURLPath allows one to override the default URL path used for
+// sending logs. If unset, default ("/v1/logs") will be used.
+func WithLogHTTPURLPath(urlPath string) LogHTTPOption {
+	return func(cfg *logHTTPConfig) {
+		cfg.urlPath = urlPath
+	}
+}
+
+// WithLogHTTPInsecure tells the driver to connect to the collector using the
+// HTTP scheme, instead of HTTPS.
+func WithLogHTTPInsecure() LogHTTPOption {
+	return func(cfg *logHTTPConfig) {
+		cfg.insecure = true
+	}
+}
+
+// WithLogHTTPTLSClientConfig can be used to set up a custom TLS
+// configuration for the client used to send payloads to the collector. Use
+// it if you want to use a custom certificate.
+func WithLogHTTPTLSClientConfig(tlsCfg *tls.Config) LogHTTPOption {
+	return func(cfg *logHTTPConfig) {
+		cfg.tlsConfig = tlsCfg
+	}
+}
+
+// WithLogHTTPHeaders allows one to tell the driver to send additional HTTP
+// headers with the payloads. Specifying headers like Content-Length,
+// Content-Encoding and Content-Type may result in a broken driver.
+func WithLogHTTPHeaders(headers map[string]string) LogHTTPOption {
+	return func(cfg *logHTTPConfig) {
+		cfg.headers = headers
+	}
+}
+
+// WithLogHTTPCompression tells the driver to compress the sent data.
+func WithLogHTTPCompression(compression Compression) LogHTTPOption {
+	return func(cfg *logHTTPConfig) {
+		cfg.compression = compression
+	}
+}
+
+// WithLogHTTPTimeout tells the driver the max waiting time for the backend
+// to process each batch of logs, including retries. If unset, the default
+// will be 10 seconds.
+func WithLogHTTPTimeout(duration time.Duration) LogHTTPOption {
+	return func(cfg *logHTTPConfig) {
+		cfg.timeout = duration
+	}
+}
+
+// WithLogHTTPRetry configures the retry policy for transient errors that
+// may occur when exporting logs. If unset, the default retry policy will
+// retry after 5 seconds and increase exponentially after each error for a
+// total of 1 minute.
+func WithLogHTTPRetry(rc LogHTTPRetryConfig) LogHTTPOption {
+	return func(cfg *logHTTPConfig) {
+		cfg.retry = rc
+	}
+}
+
+// LogExporter exports log records in the OTLP/HTTP protobuf wire format.
+type LogExporter struct {
+	url    string
+	cfg    logHTTPConfig
+	client *http.Client
+
+	mu      sync.RWMutex
+	stopped bool
+}
+
+var _ telsdk.LogExporter = (*LogExporter)(nil)
+
+// NewLogHTTP constructs a new LogExporter.
+func NewLogHTTP(ctx context.Context, opts ...LogHTTPOption) (*LogExporter, error) {
+	cfg := logHTTPConfig{
+		endpoint: "localhost:4318",
+		urlPath:  "/v1/logs",
+		timeout:  10 * time.Second,
+		retry: LogHTTPRetryConfig{
+			Enabled:         true,
+			InitialInterval: 5 * time.Second,
+			MaxInterval:     30 * time.Second,
+			MaxElapsedTime:  time.Minute,
+		},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	scheme := "https"
+	if cfg.insecure {
+		scheme = "http"
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = cfg.tlsConfig
+	return &LogExporter{
+		url:    scheme + "://" + cfg.endpoint + cfg.urlPath,
+		cfg:    cfg,
+		client: &http.Client{Transport: transport},
+	}, nil
+}
+
+// ExportLogs sends the records to the collector, retrying transient
+// errors.
+func (e *LogExporter) ExportLogs(ctx context.Context, records []telsdk.LogData) error {
+	e.mu.RLock()
+	stopped := e.stopped
+	e.mu.RUnlock()
+	if stopped || len(records) == 0 {
+		return nil
+	}
+	body, err := proto.Marshal(&collogspb.ExportLogsServiceRequest{
+		ResourceLogs: otlpconv.Logs(records),
+	})
+	if err != nil {
+		return err
+	}
+	if e.cfg.compression == LogHTTPGzipCompression {
+		var buf bytes.Buffer
+		gz := gzip.NewWriter(&buf)
+		if _, err := gz.Write(body); err != nil {
+			return err
+		}
+		if err := gz.Close(); err != nil {
+			return err
+		}
+		body = buf.Bytes()
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.cfg.timeout)
+	defer cancel()
+	start := time.Now()
+	interval := e.cfg.retry.InitialInterval
+	for {
+		retryAfter, err := e.send(ctx, body)
+		if err == nil {
+			return nil
+		}
+		var re retryableError
+		if !e.cfg.retry.Enabled || !errors.As(err, &re) {
+			return err
+		}
+		wait := retryAfter
+		if wait <= 0 {
+			// Jitter the interval by up to half of it.
+			wait = interval/2 + time.Duration(rand.Int63n(int64(interval/2)+1))
+		}
+		if e.cfg.retry.MaxElapsedTime > 0 && time.Since(start)+wait > e.cfg.retry.MaxElapsedTime {
+			return err
+		}
+		select {
+		case <-time.After(wait):
+		case <-ctx.Done():
+			return fmt.Errorf("%w: %v", ctx.Err(), err)
+		}
+		if interval *= 2; e.cfg.retry.MaxInterval > 0 && interval > e.cfg.retry.MaxInterval {
+			interval = e.cfg.retry.MaxInterval
+		}
+	}
+}
+
+// retryableError is an error sending a batch that is worth retrying.
+type retryableError struct {
+	err error
+}
+
+func (e retryableError) Error() string {
+	return e.err.Error()
+}
+
+func (e retryableError) Unwrap() error {
+	return e.err
+}
+
+// send posts body, returning the delay requested by the collector with
+// Retry-After, if any.
+func (e *LogExporter) send(ctx context.Context, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	for k, v := range e.cfg.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	if e.cfg.compression == LogHTTPGzipCompression {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return 0, err
+		}
+		return 0, retryableError{err}
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return 0, nil
+	case resp.StatusCode == http.StatusTooManyRequests,
+		resp.StatusCode == http.StatusBadGateway,
+		resp.StatusCode == http.StatusServiceUnavailable,
+		resp.StatusCode == http.StatusGatewayTimeout:
+		var wait time.Duration
+		if s, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil {
+			wait = time.Duration(s) * time.Second
+		}
+		return wait, retryableError{fmt.Errorf("failed to send logs to %s: %s", e.url, resp.Status)}
+	}
+	return 0, fmt.Errorf("failed to send logs to %s: %s", e.url, resp.Status)
+}
+
+// Shutdown stops the exporter, closing its idle connections.
+func (e *LogExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	e.stopped = true
+	e.mu.Unlock()
+	e.client.CloseIdleConnections()
+	return ctx.Err()
+}
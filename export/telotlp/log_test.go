@@ -0,0 +1,139 @@
+package telotlp
+
+import (
+	"compress/gzip"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	collogspb "go.opentelemetry.io/proto/otlp/collector/logs/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// logCollector is an OTLP/HTTP logs endpoint failing the first failures
+// requests with 503 Service Unavailable.
+type logCollector struct {
+	mu       sync.Mutex
+	failures int
+	requests int
+	records  []*logspb.LogRecord
+	resource []*logspb.ResourceLogs
+}
+
+func (c *logCollector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.requests++
+	if r.URL.Path != "/v1/logs" || r.Header.Get("Content-Type") != "application/x-protobuf" {
+		http.Error(w, "unexpected request", http.StatusBadRequest)
+		return
+	}
+	if c.failures > 0 {
+		c.failures--
+		w.Header().Set("Retry-After", "0")
+		http.Error(w, "try again", http.StatusServiceUnavailable)
+		return
+	}
+	var body io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		body = gz
+	}
+	b, err := io.ReadAll(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req collogspb.ExportLogsServiceRequest
+	if err := proto.Unmarshal(b, &req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	for _, rl := range req.ResourceLogs {
+		c.resource = append(c.resource, rl)
+		for _, sl := range rl.ScopeLogs {
+			c.records = append(c.records, sl.LogRecords...)
+		}
+	}
+}
+
+func TestLogHTTPBatch(t *testing.T) {
+	collector := &logCollector{failures: 2}
+	srv := httptest.NewServer(collector)
+	defer srv.Close()
+
+	exp, err := NewLogHTTP(context.Background(),
+		WithLogHTTPEndpoint(strings.TrimPrefix(srv.URL, "http://")),
+		WithLogHTTPInsecure(),
+		WithLogHTTPCompression(LogHTTPGzipCompression),
+		WithLogHTTPRetry(LogHTTPRetryConfig{
+			Enabled:         true,
+			InitialInterval: time.Millisecond,
+			MaxInterval:     10 * time.Millisecond,
+			MaxElapsedTime:  5 * time.Second,
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lp := telsdk.NewLoggerProvider(
+		telsdk.WithLogResource(telsdk.NewSchemaless(tel.AttributeString("service.name", "test"))),
+		telsdk.WithLogBatcher(exp, telsdk.WithLogBatchTimeout(time.Hour)),
+	)
+	logger := lp.Logger("telotlp")
+	traceID := tel.TraceID{1}
+	for _, body := range []string{"a", "b", "c"} {
+		logger.Emit(context.Background(), tel.LogRecord{
+			Severity: tel.SeverityWarn,
+			Body:     tel.AnyString(body),
+			Attributes: append(tel.AnyAttributes(tel.AttributeInt("n", 1)),
+				tel.AnyAttribute("raw", tel.AnyBytes([]byte{0, 1}))),
+			TraceID: traceID,
+		})
+	}
+	if err := lp.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+
+	collector.mu.Lock()
+	defer collector.mu.Unlock()
+	if collector.requests != 3 {
+		t.Errorf("got %d requests, want 3: two failures and the batch", collector.requests)
+	}
+	if len(collector.resource) != 1 {
+		t.Fatalf("got %d resource logs, want 1", len(collector.resource))
+	}
+	if attrs := collector.resource[0].Resource.GetAttributes(); len(attrs) != 1 ||
+		attrs[0].Key != "service.name" || attrs[0].Value.GetStringValue() != "test" {
+		t.Errorf("got resource attributes %v, want service.name=test", attrs)
+	}
+	var bodies []string
+	for _, r := range collector.records {
+		bodies = append(bodies, r.Body.GetStringValue())
+		if r.SeverityNumber != logspb.SeverityNumber_SEVERITY_NUMBER_WARN {
+			t.Errorf("got severity %v, want WARN", r.SeverityNumber)
+		}
+		if string(r.TraceId) != string(traceID[:]) {
+			t.Errorf("got trace ID %x, want %x", r.TraceId, traceID[:])
+		}
+		if len(r.Attributes) != 2 || r.Attributes[0].Value.GetIntValue() != 1 ||
+			string(r.Attributes[1].Value.GetBytesValue()) != "\x00\x01" {
+			t.Errorf("got attributes %v, want n=1 and raw=0x0001", r.Attributes)
+		}
+	}
+	if strings.Join(bodies, ",") != "a,b,c" {
+		t.Errorf("got bodies %v, want [a b c]", bodies)
+	}
+}
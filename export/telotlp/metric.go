@@ -0,0 +1,102 @@
+package telotlp
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/henvic/tel/internal/otlpconv"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/exporters/otlp/otlpmetric"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+)
+
+var errAlreadyStarted = errors.New("already started")
+
+// ExponentialMetricExporter exports metrics data in the OTLP wire format.
+//
+// Besides the aggregations exported by MetricExporter, it exports the
+// telsdk.ExponentialHistogram aggregation as OTLP exponential histograms.
+type ExponentialMetricExporter struct {
+	client              MetricClient
+	temporalitySelector aggregation.TemporalitySelector
+
+	mu      sync.RWMutex
+	started bool
+
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+var _ telsdk.Exporter = (*ExponentialMetricExporter)(nil)
+
+// NewExponentialMetric constructs a new ExponentialMetricExporter and
+// starts it.
+func NewExponentialMetric(ctx context.Context, client MetricClient, opts ...MetricOption) (*ExponentialMetricExporter, error) {
+	exp := NewExponentialMetricUnstarted(client, opts...)
+	if err := exp.Start(ctx); err != nil {
+		return nil, err
+	}
+	return exp, nil
+}
+
+// NewExponentialMetricUnstarted constructs a new ExponentialMetricExporter
+// and does not start it.
+func NewExponentialMetricUnstarted(client MetricClient, opts ...MetricOption) *ExponentialMetricExporter {
+	return &ExponentialMetricExporter{
+		client: client,
+		// The options are opaque, so the temporality they select is
+		// read from an exporter that is never started.
+		temporalitySelector: otlpmetric.NewUnstarted(client, opts...),
+	}
+}
+
+// Export exports a batch of metrics.
+func (e *ExponentialMetricExporter) Export(ctx context.Context, res *telsdk.Resource, reader telsdk.InstrumentationLibraryReader) error {
+	rm, err := otlpconv.ResourceMetrics(e, res, reader)
+	if rm == nil {
+		return err
+	}
+	if uerr := e.client.UploadMetrics(ctx, rm); uerr != nil {
+		return uerr
+	}
+	return err
+}
+
+// Start establishes a connection to the receiving endpoint.
+func (e *ExponentialMetricExporter) Start(ctx context.Context) error {
+	var err = errAlreadyStarted
+	e.startOnce.Do(func() {
+		e.mu.Lock()
+		e.started = true
+		e.mu.Unlock()
+		err = e.client.Start(ctx)
+	})
+	return err
+}
+
+// Shutdown flushes all exports and closes all connections to the receiving endpoint.
+func (e *ExponentialMetricExporter) Shutdown(ctx context.Context) error {
+	e.mu.RLock()
+	started := e.started
+	e.mu.RUnlock()
+	if !started {
+		return nil
+	}
+
+	var err error
+	e.stopOnce.Do(func() {
+		err = e.client.Stop(ctx)
+		e.mu.Lock()
+		e.started = false
+		e.mu.Unlock()
+	})
+	return err
+}
+
+// TemporalityFor returns the temporality set with
+// WithMetricMetricAggregationTemporalitySelector.
+func (e *ExponentialMetricExporter) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind) aggregation.Temporality {
+	return e.temporalitySelector.TemporalityFor(desc, kind)
+}
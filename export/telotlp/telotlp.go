@@ -152,7 +152,7 @@ func WithMetricHTTPRetry(rc MetricHTTPRetryConfig) MetricHTTPOption {
 
 // NewOTLPGRPCMetricClient creates a new gRPC metric client.
 func NewOTLPGRPCMetricClient(opts ...GRPCOption) MetricClient {
-	return otlpmetricgrpc.NewClient()
+	return otlpmetricgrpc.NewClient(opts...)
 }
 
 // NewOTLPGRPCMetric constructs a new Exporter and starts it.
@@ -0,0 +1,213 @@
+package telotlp
+
+import (
+	"context"
+	"crypto/tls"
+	"time"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// TraceClient manages connections to the collector, handles the
+// transformation of data into wire format, and the transmission of that
+// data to the collector.
+type TraceClient = otlptrace.Client
+
+// TraceExporter exports trace data in the OTLP wire format.
+type TraceExporter = otlptrace.Exporter
+
+// NewTrace constructs a new Exporter and starts it.
+func NewTrace(ctx context.Context, client TraceClient) (*TraceExporter, error) {
+	return otlptrace.New(ctx, client)
+}
+
+// NewTraceUnstarted constructs a new Exporter and does not start it.
+func NewTraceUnstarted(client TraceClient) *TraceExporter {
+	return otlptrace.NewUnstarted(client)
+}
+
+// NewTraceHTTPClient creates a new HTTP trace client.
+func NewTraceHTTPClient(opts ...TraceHTTPOption) TraceClient {
+	return otlptracehttp.NewClient(opts...)
+}
+
+// NewTraceHTTP constructs a new Exporter and starts it.
+func NewTraceHTTP(ctx context.Context, opts ...TraceHTTPOption) (*TraceExporter, error) {
+	return otlptracehttp.New(ctx, opts...)
+}
+
+// NewTraceHTTPUnstarted constructs a new Exporter and does not start it.
+func NewTraceHTTPUnstarted(opts ...TraceHTTPOption) *TraceExporter {
+	return otlptracehttp.NewUnstarted(opts...)
+}
+
+// TraceHTTPCompression describes the compression used for payloads sent to
+// the collector.
+type TraceHTTPCompression = otlptracehttp.Compression
+
+const (
+	// TraceHTTPNoCompression tells the driver to send payloads without
+	// compression.
+	TraceHTTPNoCompression = otlptracehttp.NoCompression
+	// TraceHTTPGzipCompression tells the driver to send payloads after
+	// compressing them with gzip.
+	TraceHTTPGzipCompression = otlptracehttp.GzipCompression
+)
+
+// TraceHTTPOption applies an option to the HTTP client.
+type TraceHTTPOption = otlptracehttp.Option
+
+// TraceHTTPRetryConfig defines configuration for retrying batches in case of export
+// failure using an exponential backoff.
+type TraceHTTPRetryConfig = otlptracehttp.RetryConfig
+
+// WithTraceHTTPEndpoint allows one to set the address of the collector endpoint that
+// the driver will use to send spans. If unset, it will instead try to use
+// the default endpoint (localhost:4318). Note that the endpoint must not
+// contain any URL path.
+func WithTraceHTTPEndpoint(endpoint string) TraceHTTPOption {
+	return otlptracehttp.WithEndpoint(endpoint)
+}
+
+// WithTraceHTTPCompression tells the driver to compress the sent data.
+func WithTraceHTTPCompression(compression TraceHTTPCompression) TraceHTTPOption {
+	return otlptracehttp.WithCompression(compression)
+}
+
+// WithTraceHTTPURLPath allows one to override the default URL path used
+// for sending traces. If unset, default ("/v1/traces") will be used.
+func WithTraceHTTPURLPath(urlPath string) TraceHTTPOption {
+	return otlptracehttp.WithURLPath(urlPath)
+}
+
+// WithTraceHTTPTLSClientConfig can be used to set up a custom TLS
+// configuration for the client used to send payloads to the
+// collector. Use it if you want to use a custom certificate.
+func WithTraceHTTPTLSClientConfig(tlsCfg *tls.Config) TraceHTTPOption {
+	return otlptracehttp.WithTLSClientConfig(tlsCfg)
+}
+
+// WithTraceHTTPInsecure tells the driver to connect to the collector using the
+// HTTP scheme, instead of HTTPS.
+func WithTraceHTTPInsecure() TraceHTTPOption {
+	return otlptracehttp.WithInsecure()
+}
+
+// WithTraceHTTPHeaders allows one to tell the driver to send additional HTTP
+// headers with the payloads. Specifying headers like Content-Length,
+// Content-Encoding and Content-Type may result in a broken driver.
+func WithTraceHTTPHeaders(headers map[string]string) TraceHTTPOption {
+	return otlptracehttp.WithHeaders(headers)
+}
+
+// WithTraceHTTPTimeout tells the driver the max waiting time for the backend to process
+// each spans batch.  If unset, the default will be 10 seconds.
+func WithTraceHTTPTimeout(duration time.Duration) TraceHTTPOption {
+	return otlptracehttp.WithTimeout(duration)
+}
+
+// WithTraceHTTPRetry configures the retry policy for transient errors that may occurs
+// when exporting traces. An exponential back-off algorithm is used to ensure
+// endpoints are not overwhelmed with retries. If unset, the default retry
+// policy will retry after 5 seconds and increase exponentially after each
+// error for a total of 1 minute.
+func WithTraceHTTPRetry(rc TraceHTTPRetryConfig) TraceHTTPOption {
+	return otlptracehttp.WithRetry(rc)
+}
+
+// NewTraceGRPCClient creates a new gRPC trace client.
+func NewTraceGRPCClient(opts ...TraceGRPCOption) TraceClient {
+	return otlptracegrpc.NewClient(opts...)
+}
+
+// NewTraceGRPC constructs a new Exporter and starts it.
+func NewTraceGRPC(ctx context.Context, opts ...TraceGRPCOption) (*TraceExporter, error) {
+	return otlptracegrpc.New(ctx, opts...)
+}
+
+// NewTraceGRPCUnstarted constructs a new Exporter and does not start it.
+func NewTraceGRPCUnstarted(opts ...TraceGRPCOption) *TraceExporter {
+	return otlptracegrpc.NewUnstarted(opts...)
+}
+
+// TraceGRPCOption applies an option to the gRPC driver.
+type TraceGRPCOption = otlptracegrpc.Option
+
+// TraceGRPCRetryConfig defines configuration for retrying export of span batches that
+// failed to be received by the target endpoint.
+//
+// This configuration does not define any network retry strategy. That is
+// entirely handled by the gRPC ClientConn.
+type TraceGRPCRetryConfig = otlptracegrpc.RetryConfig
+
+// WithTraceGRPCInsecure disables client transport security for the exporter's gRPC
+// connection just like grpc.WithInsecure()
+// (https://pkg.go.dev/google.golang.org/grpc#WithInsecure) does. Note, by
+// default, client security is required unless WithTraceGRPCInsecure is used.
+//
+// This option has no effect if WithTraceGRPCConn is used.
+func WithTraceGRPCInsecure() TraceGRPCOption {
+	return otlptracegrpc.WithInsecure()
+}
+
+// WithTraceGRPCEndpoint sets the target endpoint the exporter will connect to. If
+// unset, localhost:4317 will be used as a default.
+//
+// This option has no effect if WithTraceGRPCConn is used.
+func WithTraceGRPCEndpoint(endpoint string) TraceGRPCOption {
+	return otlptracegrpc.WithEndpoint(endpoint)
+}
+
+// WithTraceGRPCCompressor sets the compressor for the gRPC client to use when sending
+// requests. It is the responsibility of the caller to ensure that the
+// compressor set has been registered with google.golang.org/grpc/encoding.
+//
+// This option has no effect if WithTraceGRPCConn is used.
+func WithTraceGRPCCompressor(compressor string) TraceGRPCOption {
+	return otlptracegrpc.WithCompressor(compressor)
+}
+
+// WithTraceGRPCHeaders will send the provided headers with each gRPC requests.
+func WithTraceGRPCHeaders(headers map[string]string) TraceGRPCOption {
+	return otlptracegrpc.WithHeaders(headers)
+}
+
+// WithTraceGRPCTLSCredentials allows the connection to use TLS credentials when
+// talking to the server.
+//
+// This option has no effect if WithTraceGRPCConn is used.
+func WithTraceGRPCTLSCredentials(creds credentials.TransportCredentials) TraceGRPCOption {
+	return otlptracegrpc.WithTLSCredentials(creds)
+}
+
+// WithTraceGRPCDialOption sets explicit grpc.DialOptions to use when making a
+// connection.
+//
+// This option has no effect if WithTraceGRPCConn is used.
+func WithTraceGRPCDialOption(opts ...grpc.DialOption) TraceGRPCOption {
+	return otlptracegrpc.WithDialOption(opts...)
+}
+
+// WithTraceGRPCConn sets conn as the gRPC ClientConn used for all communication.
+//
+// It is the callers responsibility to close the passed conn. The client
+// Shutdown method will not close this connection.
+func WithTraceGRPCConn(conn *grpc.ClientConn) TraceGRPCOption {
+	return otlptracegrpc.WithGRPCConn(conn)
+}
+
+// WithTraceGRPCTimeout sets the max amount of time a client will attempt to export a
+// batch of spans. If unset, the default timeout will be set to 10 seconds.
+func WithTraceGRPCTimeout(duration time.Duration) TraceGRPCOption {
+	return otlptracegrpc.WithTimeout(duration)
+}
+
+// WithTraceGRPCRetry sets the retry policy for transient retryable errors that may be
+// returned by the target endpoint when exporting a batch of spans.
+func WithTraceGRPCRetry(settings TraceGRPCRetryConfig) TraceGRPCOption {
+	return otlptracegrpc.WithRetry(settings)
+}
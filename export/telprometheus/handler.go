@@ -0,0 +1,352 @@
+package telexporter
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/internal/promname"
+	"github.com/henvic/tel/telsdk"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	dto "github.com/prometheus/client_model/go"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	export "go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// targetInfoName is the metric holding the resource attributes.
+	targetInfoName    = "target_info"
+	scopeNameLabel    = "otel_scope_name"
+	scopeVersionLabel = "otel_scope_version"
+)
+
+// PrometheusHandler serves the metrics of a controller to Prometheus
+// scrapes, using the OpenMetrics text format when the scraper asks for it.
+//
+// Unlike PrometheusExporter, it attaches exemplars of the span active when
+// a measurement was taken to counters and histogram buckets, reports the
+// resource in a target_info metric rather than on every series, and labels
+// series with the instrumentation library that recorded them.
+//
+// Exemplars are only available when the controller selects its aggregators
+// with telsdk.NewExemplarSelector, and are only sent in the OpenMetrics
+// format. The controller must keep cumulative state, for example with:
+//
+//	telsdk.NewFactory(
+//		telsdk.NewExemplarSelector(telsdk.NewWithHistogramDistribution()),
+//		aggregation.CumulativeTemporalitySelector(),
+//		telsdk.WithMemory(true),
+//	)
+type PrometheusHandler struct {
+	handler http.Handler
+}
+
+// PrometheusHandlerOption configures a PrometheusHandler.
+type PrometheusHandlerOption func(*prometheusHandlerConfig)
+
+type prometheusHandlerConfig struct {
+	registry     *prometheus.Registry
+	targetInfo   bool
+	scopeLabels  bool
+	errorHandler promhttp.HandlerErrorHandling
+}
+
+// WithPrometheusRegistry registers the metrics of the handler in registry and
+// serves all the metrics of registry, such as the Go runtime collectors. By
+// default, the handler uses a registry of its own.
+func WithPrometheusRegistry(registry *prometheus.Registry) PrometheusHandlerOption {
+	return func(cfg *prometheusHandlerConfig) {
+		cfg.registry = registry
+	}
+}
+
+// WithoutPrometheusTargetInfo disables the target_info metric. The resource
+// attributes are not exported at all then.
+func WithoutPrometheusTargetInfo() PrometheusHandlerOption {
+	return func(cfg *prometheusHandlerConfig) {
+		cfg.targetInfo = false
+	}
+}
+
+// WithoutPrometheusScopeLabels disables the otel_scope_name and
+// otel_scope_version labels.
+func WithoutPrometheusScopeLabels() PrometheusHandlerOption {
+	return func(cfg *prometheusHandlerConfig) {
+		cfg.scopeLabels = false
+	}
+}
+
+// WithPrometheusContinueOnError serves the metrics that could be collected
+// when others fail, instead of responding with an error.
+func WithPrometheusContinueOnError() PrometheusHandlerOption {
+	return func(cfg *prometheusHandlerConfig) {
+		cfg.errorHandler = promhttp.ContinueOnError
+	}
+}
+
+// NewPrometheusHandler returns a handler serving the metrics of ctrl.
+func NewPrometheusHandler(ctrl *telsdk.BasicController, opts ...PrometheusHandlerOption) (*PrometheusHandler, error) {
+	cfg := prometheusHandlerConfig{
+		targetInfo:   true,
+		scopeLabels:  true,
+		errorHandler: promhttp.HTTPErrorOnError,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.registry == nil {
+		cfg.registry = prometheus.NewRegistry()
+	}
+	c := &handlerCollector{
+		ctrl:        ctrl,
+		targetInfo:  cfg.targetInfo,
+		scopeLabels: cfg.scopeLabels,
+	}
+	if err := cfg.registry.Register(c); err != nil {
+		return nil, fmt.Errorf("cannot register the collector: %w", err)
+	}
+	return &PrometheusHandler{
+		handler: promhttp.HandlerFor(cfg.registry, promhttp.HandlerOpts{
+			ErrorHandling:     cfg.errorHandler,
+			EnableOpenMetrics: true,
+		}),
+	}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (h *PrometheusHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	h.handler.ServeHTTP(w, r)
+}
+
+// handlerCollector is an unchecked prometheus.Collector: the instruments of
+// the controller aren't known in advance.
+type handlerCollector struct {
+	ctrl        *telsdk.BasicController
+	targetInfo  bool
+	scopeLabels bool
+
+	// mu serializes scrapes, as the controller state is read after
+	// collecting it.
+	mu sync.Mutex
+}
+
+var _ prometheus.Collector = (*handlerCollector)(nil)
+
+// Describe implements prometheus.Collector.
+func (c *handlerCollector) Describe(ch chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *handlerCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if err := c.ctrl.Collect(context.Background()); err != nil {
+		tel.Handle(err)
+	}
+	if c.targetInfo {
+		if err := c.exportTargetInfo(ch); err != nil {
+			tel.Handle(err)
+		}
+	}
+	err := c.ctrl.ForEach(func(lib instrumentation.Library, reader export.Reader) error {
+		return reader.ForEach(aggregation.CumulativeTemporalitySelector(), func(record export.Record) error {
+			return c.exportRecord(ch, lib, record)
+		})
+	})
+	if err != nil {
+		tel.Handle(err)
+	}
+}
+
+func (c *handlerCollector) exportTargetInfo(ch chan<- prometheus.Metric) error {
+	res := c.ctrl.Resource()
+	if res.Len() == 0 {
+		return nil
+	}
+	keys, values := labels(res.Attributes(), nil)
+	desc := prometheus.NewDesc(targetInfoName, "Target metadata", keys, nil)
+	m, err := prometheus.NewConstMetric(desc, prometheus.GaugeValue, 1, values...)
+	if err != nil {
+		return fmt.Errorf("error creating target info: %w", err)
+	}
+	ch <- m
+	return nil
+}
+
+func (c *handlerCollector) exportRecord(ch chan<- prometheus.Metric, lib instrumentation.Library, record export.Record) error {
+	descriptor := record.Descriptor()
+	kind := descriptor.NumberKind()
+
+	var scope []attribute.KeyValue
+	if c.scopeLabels {
+		scope = []attribute.KeyValue{
+			attribute.String(scopeNameLabel, lib.Name),
+			attribute.String(scopeVersionLabel, lib.Version),
+		}
+	}
+	keys, values := labels(record.Attributes().ToSlice(), scope)
+	name := promname.Sanitize(descriptor.Name())
+
+	switch agg := record.Aggregation().(type) {
+	case aggregation.Histogram:
+		if err := exportHistogram(ch, agg, kind, prometheus.NewDesc(name, descriptor.Description(), keys, nil), values); err != nil {
+			return fmt.Errorf("exporting histogram: %w", err)
+		}
+	case aggregation.Sum:
+		v, err := agg.Sum()
+		if err != nil {
+			return fmt.Errorf("error retrieving sum: %w", err)
+		}
+		if !descriptor.InstrumentKind().Monotonic() {
+			return exportConst(ch, prometheus.NewDesc(name, descriptor.Description(), keys, nil), prometheus.GaugeValue, v.CoerceToFloat64(kind), values)
+		}
+		if !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+		m, err := prometheus.NewConstMetric(prometheus.NewDesc(name, descriptor.Description(), keys, nil), prometheus.CounterValue, v.CoerceToFloat64(kind), values...)
+		if err != nil {
+			return fmt.Errorf("error creating counter: %w", err)
+		}
+		ch <- withExemplars(m, agg)
+	case aggregation.LastValue:
+		v, _, err := agg.LastValue()
+		if err != nil {
+			return fmt.Errorf("error retrieving last value: %w", err)
+		}
+		return exportConst(ch, prometheus.NewDesc(name, descriptor.Description(), keys, nil), prometheus.GaugeValue, v.CoerceToFloat64(kind), values)
+	default:
+		return fmt.Errorf("%w: %s", ErrPrometheusUnsupportedAggregator, agg.Kind())
+	}
+	return nil
+}
+
+func exportConst(ch chan<- prometheus.Metric, desc *prometheus.Desc, typ prometheus.ValueType, v float64, values []string) error {
+	m, err := prometheus.NewConstMetric(desc, typ, v, values...)
+	if err != nil {
+		return fmt.Errorf("error creating constant metric: %w", err)
+	}
+	ch <- m
+	return nil
+}
+
+func exportHistogram(ch chan<- prometheus.Metric, hist aggregation.Histogram, kind number.Kind, desc *prometheus.Desc, values []string) error {
+	buckets, err := hist.Histogram()
+	if err != nil {
+		return fmt.Errorf("error retrieving histogram: %w", err)
+	}
+	sum, err := hist.Sum()
+	if err != nil {
+		return fmt.Errorf("error retrieving sum: %w", err)
+	}
+
+	var total uint64
+	// counts maps from the bucket upper-bound to the cumulative count.
+	// The bucket with upper-bound +inf is not included.
+	counts := make(map[float64]uint64, len(buckets.Boundaries))
+	for i, boundary := range buckets.Boundaries {
+		total += buckets.Counts[i]
+		counts[boundary] = total
+	}
+	total += buckets.Counts[len(buckets.Counts)-1]
+
+	m, err := prometheus.NewConstHistogram(desc, total, sum.CoerceToFloat64(kind), counts, values...)
+	if err != nil {
+		return fmt.Errorf("error creating constant histogram: %w", err)
+	}
+	ch <- withExemplars(m, hist)
+	return nil
+}
+
+// labels returns the sanitized label names and the values of attrs followed
+// by extra. Attributes whose names collide once sanitized are joined with
+// a semicolon, in the order of their original keys.
+func labels(attrs, extra []attribute.KeyValue) (keys, values []string) {
+	index := map[string]int{}
+	for _, kv := range append(attrs, extra...) {
+		k := promname.Sanitize(string(kv.Key))
+		if i, ok := index[k]; ok {
+			values[i] += ";" + kv.Value.Emit()
+			continue
+		}
+		index[k] = len(keys)
+		keys = append(keys, k)
+		values = append(values, kv.Value.Emit())
+	}
+	return keys, values
+}
+
+// exemplarMetric adds the exemplars of an aggregation to a metric.
+type exemplarMetric struct {
+	prometheus.Metric
+	exemplars []telsdk.Exemplar
+}
+
+// withExemplars returns m with the exemplars of agg, if any.
+func withExemplars(m prometheus.Metric, agg aggregation.Aggregation) prometheus.Metric {
+	ea, ok := agg.(telsdk.ExemplarAggregation)
+	if !ok {
+		return m
+	}
+	exemplars := ea.Exemplars()
+	for _, e := range exemplars {
+		if e.IsValid() {
+			return exemplarMetric{Metric: m, exemplars: exemplars}
+		}
+	}
+	return m
+}
+
+// Write implements prometheus.Metric. Exemplars not matching the buckets of
+// a histogram are dropped, keeping the rest of the metric.
+func (m exemplarMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	switch {
+	case out.Counter != nil && len(m.exemplars) == 1:
+		out.Counter.Exemplar = exemplar(m.exemplars[0])
+	case out.Histogram != nil:
+		bs := out.Histogram.Bucket
+		if len(bs) != len(m.exemplars)-1 {
+			return nil
+		}
+		sort.Slice(bs, func(i, j int) bool { return bs[i].GetUpperBound() < bs[j].GetUpperBound() })
+		for i, b := range bs {
+			b.Exemplar = exemplar(m.exemplars[i])
+		}
+		// Add the +Inf bucket explicitly to hold its exemplar.
+		if inf := exemplar(m.exemplars[len(bs)]); inf != nil {
+			out.Histogram.Bucket = append(bs, &dto.Bucket{
+				CumulativeCount: proto.Uint64(out.Histogram.GetSampleCount()),
+				UpperBound:      proto.Float64(math.Inf(+1)),
+				Exemplar:        inf,
+			})
+		}
+	}
+	return nil
+}
+
+func exemplar(e telsdk.Exemplar) *dto.Exemplar {
+	if !e.IsValid() {
+		return nil
+	}
+	return &dto.Exemplar{
+		Label: []*dto.LabelPair{
+			{Name: proto.String("trace_id"), Value: proto.String(e.TraceID.String())},
+			{Name: proto.String("span_id"), Value: proto.String(e.SpanID.String())},
+		},
+		Value:     proto.Float64(e.Value),
+		Timestamp: timestamppb.New(e.Time),
+	}
+}
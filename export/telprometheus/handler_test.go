@@ -0,0 +1,208 @@
+package telexporter
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+// newTestHandler records a counter and a histogram within a sampled span,
+// returning the server of a PrometheusHandler and the span context.
+func newTestHandler(t *testing.T, opts ...PrometheusHandlerOption) (*httptest.Server, tel.SpanContext) {
+	t.Helper()
+	ctrl := telsdk.NewBasicController(
+		telsdk.NewFactory(
+			telsdk.NewExemplarSelector(telsdk.NewWithHistogramDistribution(
+				telsdk.HistogramWithExplicitBoundaries([]float64{1, 10}))),
+			aggregation.CumulativeTemporalitySelector(),
+			telsdk.WithMemory(true),
+		),
+		telsdk.WithBasicControllerResource(telsdk.NewSchemaless(tel.AttributeString("service.name", "test"))),
+	)
+	meter := ctrl.Meter("scope", metric.WithInstrumentationVersion("v1"))
+	counter, err := meter.SyncInt64().Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist, err := meter.SyncFloat64().Histogram("latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tp := telsdk.NewTracerProvider()
+	ctx, span := tp.Tracer("test").Start(context.Background(), "op")
+	defer span.End()
+	counter.Add(ctx, 3, tel.AttributeString("route", "/"))
+	hist.Record(ctx, 5)
+	hist.Record(context.Background(), 0.5)
+
+	h, err := NewPrometheusHandler(ctrl, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return srv, span.SpanContext()
+}
+
+func scrape(t *testing.T, url, accept string) (string, string) {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if accept != "" {
+		req.Header.Set("Accept", accept)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("got status %s: %s", resp.Status, b)
+	}
+	return resp.Header.Get("Content-Type"), string(b)
+}
+
+func labelMap(m *dto.Metric) map[string]string {
+	labels := map[string]string{}
+	for _, l := range m.GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	return labels
+}
+
+func TestPrometheusHandlerText(t *testing.T) {
+	srv, _ := newTestHandler(t)
+	contentType, body := scrape(t, srv.URL, "")
+	if !strings.HasPrefix(contentType, "text/plain") {
+		t.Errorf("got Content-Type %q, want text/plain", contentType)
+	}
+	families, err := new(expfmt.TextParser).TextToMetricFamilies(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("%v:\n%s", err, body)
+	}
+
+	info := families["target_info"]
+	if info == nil || len(info.Metric) != 1 || info.Metric[0].GetGauge().GetValue() != 1 ||
+		labelMap(info.Metric[0])["service_name"] != "test" {
+		t.Errorf("got target_info %v, want a gauge of 1 with service_name=test", info)
+	}
+
+	requests := families["requests_total"]
+	if requests == nil || requests.GetType() != dto.MetricType_COUNTER || len(requests.Metric) != 1 {
+		t.Fatalf("got requests_total %v, want a single counter", requests)
+	}
+	labels := labelMap(requests.Metric[0])
+	want := map[string]string{"route": "/", "otel_scope_name": "scope", "otel_scope_version": "v1"}
+	for k, v := range want {
+		if labels[k] != v {
+			t.Errorf("got label %s=%q, want %q", k, labels[k], v)
+		}
+	}
+	if _, ok := labels["service_name"]; ok {
+		t.Error("got the resource attributes on requests_total, want them on target_info only")
+	}
+	if got := requests.Metric[0].GetCounter().GetValue(); got != 3 {
+		t.Errorf("got requests_total %v, want 3", got)
+	}
+
+	latency := families["latency"]
+	if latency == nil || latency.GetType() != dto.MetricType_HISTOGRAM {
+		t.Fatalf("got latency %v, want a histogram", latency)
+	}
+	if h := latency.Metric[0].GetHistogram(); h.GetSampleCount() != 2 || h.GetSampleSum() != 5.5 {
+		t.Errorf("got count %d and sum %v, want 2 and 5.5", h.GetSampleCount(), h.GetSampleSum())
+	}
+	if strings.Contains(body, "trace_id") {
+		t.Error("got exemplars in the text format, which doesn't support them")
+	}
+}
+
+func TestPrometheusHandlerOpenMetrics(t *testing.T) {
+	srv, sc := newTestHandler(t)
+	contentType, body := scrape(t, srv.URL, "application/openmetrics-text; version=0.0.1,text/plain;version=0.0.4;q=0.5")
+	if !strings.HasPrefix(contentType, "application/openmetrics-text") {
+		t.Errorf("got Content-Type %q, want application/openmetrics-text", contentType)
+	}
+	if !strings.HasSuffix(body, "# EOF\n") {
+		t.Errorf("got a body not ending with # EOF:\n%s", body)
+	}
+	exemplar := `# {trace_id="` + sc.TraceID().String() + `",span_id="` + sc.SpanID().String() + `"}`
+
+	var counter, buckets []string
+	for _, line := range strings.Split(body, "\n") {
+		switch {
+		case strings.HasPrefix(line, "requests_total{"):
+			counter = append(counter, line)
+		case strings.HasPrefix(line, "latency_bucket{"):
+			buckets = append(buckets, line)
+		}
+	}
+	if len(counter) != 1 || !strings.Contains(counter[0], exemplar+" 3.0 ") {
+		t.Errorf("got counter %q, want the exemplar %s 3.0", counter, exemplar)
+	}
+	// Only the measurement taken within the span, 5 in the (1, 10]
+	// bucket, has an exemplar.
+	var withExemplar []string
+	for _, b := range buckets {
+		if strings.Contains(b, "# {") {
+			withExemplar = append(withExemplar, b)
+		}
+	}
+	if len(withExemplar) != 1 || !strings.Contains(withExemplar[0], `le="10.0"`) ||
+		!strings.Contains(withExemplar[0], exemplar+" 5.0 ") {
+		t.Errorf("got buckets with exemplars %q, want le=10 with %s 5.0", withExemplar, exemplar)
+	}
+	if !strings.Contains(body, `otel_scope_name="scope"`) || !strings.Contains(body, `target_info{service_name="test"} 1.0`) {
+		t.Errorf("got body without the scope labels or target_info:\n%s", body)
+	}
+}
+
+func TestPrometheusHandlerOptions(t *testing.T) {
+	srv, _ := newTestHandler(t, WithoutPrometheusTargetInfo(), WithoutPrometheusScopeLabels())
+	_, body := scrape(t, srv.URL, "")
+	if strings.Contains(body, "target_info") || strings.Contains(body, "otel_scope_") {
+		t.Errorf("got target_info or scope labels despite the options:\n%s", body)
+	}
+}
+
+func TestExemplarMetricMismatchedBuckets(t *testing.T) {
+	desc := prometheus.NewDesc("h", "", nil, nil)
+	h, err := prometheus.NewConstHistogram(desc, 1, 1, map[float64]uint64{1: 1, 2: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := exemplarMetric{Metric: h, exemplars: []telsdk.Exemplar{{
+		Value:   1,
+		Time:    time.Now(),
+		TraceID: tel.TraceID{1},
+		SpanID:  tel.SpanID{1},
+	}}}
+	var out dto.Metric
+	if err := m.Write(&out); err != nil {
+		t.Fatalf("Write() = %v, want the metric without exemplars", err)
+	}
+	for _, b := range out.GetHistogram().GetBucket() {
+		if b.Exemplar != nil {
+			t.Errorf("got bucket %v, want no exemplars", b)
+		}
+	}
+}
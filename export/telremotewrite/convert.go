@@ -0,0 +1,123 @@
+package telremotewrite
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/henvic/tel/internal/promname"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	export "go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+const (
+	nameLabel         = "__name__"
+	bucketLabel       = "le"
+	scopeNameLabel    = "otel_scope_name"
+	scopeVersionLabel = "otel_scope_version"
+)
+
+// ErrUnsupportedAggregator is returned for aggregations that can't be
+// represented in remote write.
+var ErrUnsupportedAggregator = errors.New("unsupported aggregator type")
+
+// convert returns the series of the records of reader.
+func (e *Exporter) convert(res *telsdk.Resource, reader telsdk.InstrumentationLibraryReader) ([]timeSeries, error) {
+	var base []attribute.KeyValue
+	if e.cfg.resourceLabels && res != nil {
+		base = res.Attributes()
+	}
+	var series []timeSeries
+	err := reader.ForEach(func(lib instrumentation.Library, r export.Reader) error {
+		return r.ForEach(e, func(record export.Record) error {
+			ts, err := e.convertRecord(base, lib, record)
+			series = append(series, ts...)
+			return err
+		})
+	})
+	return series, err
+}
+
+func (e *Exporter) convertRecord(base []attribute.KeyValue, lib instrumentation.Library, record export.Record) ([]timeSeries, error) {
+	descriptor := record.Descriptor()
+	kind := descriptor.NumberKind()
+	name := promname.Sanitize(descriptor.Name())
+	timestamp := record.EndTime().UnixMilli()
+
+	// Record attributes take precedence over the resource ones.
+	labels := map[string]string{}
+	for _, kv := range base {
+		labels[promname.Sanitize(string(kv.Key))] = kv.Value.Emit()
+	}
+	for _, kv := range record.Attributes().ToSlice() {
+		labels[promname.Sanitize(string(kv.Key))] = kv.Value.Emit()
+	}
+	if e.cfg.scopeLabels {
+		labels[scopeNameLabel] = lib.Name
+		labels[scopeVersionLabel] = lib.Version
+	}
+
+	switch agg := record.Aggregation().(type) {
+	case aggregation.Histogram:
+		buckets, err := agg.Histogram()
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving histogram: %w", err)
+		}
+		sum, err := agg.Sum()
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving sum: %w", err)
+		}
+		series := make([]timeSeries, 0, len(buckets.Counts)+2)
+		var count uint64
+		for i, n := range buckets.Counts {
+			count += n
+			le := math.Inf(+1)
+			if i < len(buckets.Boundaries) {
+				le = buckets.Boundaries[i]
+			}
+			labels[bucketLabel] = strconv.FormatFloat(le, 'g', -1, 64)
+			series = append(series, newTimeSeries(name+"_bucket", labels, float64(count), timestamp))
+		}
+		delete(labels, bucketLabel)
+		return append(series,
+			newTimeSeries(name+"_sum", labels, sum.CoerceToFloat64(kind), timestamp),
+			newTimeSeries(name+"_count", labels, float64(count), timestamp),
+		), nil
+	case aggregation.Sum:
+		v, err := agg.Sum()
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving sum: %w", err)
+		}
+		if descriptor.InstrumentKind().Monotonic() && !strings.HasSuffix(name, "_total") {
+			name += "_total"
+		}
+		return []timeSeries{newTimeSeries(name, labels, v.CoerceToFloat64(kind), timestamp)}, nil
+	case aggregation.LastValue:
+		v, t, err := agg.LastValue()
+		if err != nil {
+			return nil, fmt.Errorf("error retrieving last value: %w", err)
+		}
+		return []timeSeries{newTimeSeries(name, labels, v.CoerceToFloat64(kind), t.UnixMilli())}, nil
+	}
+	return nil, fmt.Errorf("%w: %s", ErrUnsupportedAggregator, record.Aggregation().Kind())
+}
+
+func newTimeSeries(name string, labels map[string]string, value float64, timestamp int64) timeSeries {
+	ts := timeSeries{
+		labels:    make([]label, 0, len(labels)+1),
+		value:     value,
+		timestamp: timestamp,
+	}
+	ts.labels = append(ts.labels, label{name: nameLabel, value: name})
+	for k, v := range labels {
+		ts.labels = append(ts.labels, label{name: k, value: v})
+	}
+	sort.Slice(ts.labels, func(i, j int) bool { return ts.labels[i].name < ts.labels[j].name })
+	return ts
+}
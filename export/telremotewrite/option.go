@@ -0,0 +1,166 @@
+package telremotewrite
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+type config struct {
+	client              *http.Client
+	headers             map[string]string
+	timeout             time.Duration
+	retry               RetryConfig
+	shards              int
+	queueCapacity       int
+	maxSamplesPerSend   int
+	batchSendDeadline   time.Duration
+	resourceLabels      bool
+	scopeLabels         bool
+	temporalitySelector aggregation.TemporalitySelector
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		client:              http.DefaultClient,
+		timeout:             30 * time.Second,
+		retry:               DefaultRetryConfig,
+		shards:              4,
+		queueCapacity:       2500,
+		maxSamplesPerSend:   500,
+		batchSendDeadline:   5 * time.Second,
+		resourceLabels:      true,
+		scopeLabels:         true,
+		temporalitySelector: aggregation.CumulativeTemporalitySelector(),
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.shards < 1 {
+		cfg.shards = 1
+	}
+	if cfg.queueCapacity < 1 {
+		cfg.queueCapacity = 1
+	}
+	if cfg.maxSamplesPerSend < 1 {
+		cfg.maxSamplesPerSend = 1
+	}
+	return cfg
+}
+
+// Option configures a remote-write Exporter.
+type Option func(*config)
+
+// RetryConfig defines how failed requests are retried. Requests are retried
+// on network errors and on 429 and 5xx responses, waiting between attempts
+// an exponentially growing, jittered interval, or the time asked for in a
+// Retry-After header.
+type RetryConfig struct {
+	// Enabled indicates whether failed requests are retried.
+	Enabled bool
+	// InitialInterval is the time to wait after the first failure.
+	InitialInterval time.Duration
+	// MaxInterval is the upper bound of the time between attempts.
+	MaxInterval time.Duration
+	// MaxElapsedTime is the time after which a request is given up. If
+	// zero, requests are retried until the exporter shuts down.
+	MaxElapsedTime time.Duration
+}
+
+// DefaultRetryConfig is the retry configuration used by default.
+var DefaultRetryConfig = RetryConfig{
+	Enabled:         true,
+	InitialInterval: 30 * time.Millisecond,
+	MaxInterval:     5 * time.Second,
+	MaxElapsedTime:  time.Minute,
+}
+
+// WithHTTPClient sets the client used to send requests.
+func WithHTTPClient(client *http.Client) Option {
+	return func(cfg *config) {
+		cfg.client = client
+	}
+}
+
+// WithHeaders sets headers sent with every request, such as an
+// Authorization or X-Scope-OrgID header.
+func WithHeaders(headers map[string]string) Option {
+	return func(cfg *config) {
+		cfg.headers = headers
+	}
+}
+
+// WithTimeout sets the timeout of each request. If unset, 30 seconds is
+// used.
+func WithTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		cfg.timeout = timeout
+	}
+}
+
+// WithRetry sets how failed requests are retried. If unset,
+// DefaultRetryConfig is used.
+func WithRetry(retry RetryConfig) Option {
+	return func(cfg *config) {
+		cfg.retry = retry
+	}
+}
+
+// WithShards sets the number of queues, each sending requests concurrently.
+// Samples of a series always go through the same queue, so they are sent
+// in order. If unset, 4 shards are used.
+func WithShards(n int) Option {
+	return func(cfg *config) {
+		cfg.shards = n
+	}
+}
+
+// WithQueueCapacity sets how many samples each shard holds before Export
+// blocks. If unset, 2500 is used.
+func WithQueueCapacity(samples int) Option {
+	return func(cfg *config) {
+		cfg.queueCapacity = samples
+	}
+}
+
+// WithMaxSamplesPerSend sets the largest number of samples sent in a
+// request. If unset, 500 is used.
+func WithMaxSamplesPerSend(samples int) Option {
+	return func(cfg *config) {
+		cfg.maxSamplesPerSend = samples
+	}
+}
+
+// WithBatchSendDeadline sets how long samples wait in a shard for a request
+// to fill up before being sent. If unset, 5 seconds is used.
+func WithBatchSendDeadline(deadline time.Duration) Option {
+	return func(cfg *config) {
+		cfg.batchSendDeadline = deadline
+	}
+}
+
+// WithoutResourceLabels stops adding the resource attributes to the labels
+// of every series.
+func WithoutResourceLabels() Option {
+	return func(cfg *config) {
+		cfg.resourceLabels = false
+	}
+}
+
+// WithoutScopeLabels stops adding the otel_scope_name and
+// otel_scope_version labels to every series.
+func WithoutScopeLabels() Option {
+	return func(cfg *config) {
+		cfg.scopeLabels = false
+	}
+}
+
+// WithTemporalitySelector sets the aggregation.TemporalitySelector used by
+// the exporter. If unset, cumulative temporality is used, as expected by
+// Prometheus.
+func WithTemporalitySelector(selector aggregation.TemporalitySelector) Option {
+	return func(cfg *config) {
+		cfg.temporalitySelector = selector
+	}
+}
@@ -0,0 +1,141 @@
+package telremotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"math/rand"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/henvic/tel"
+)
+
+// shard batches the samples of a subset of the series and sends them.
+type shard struct {
+	e     *Exporter
+	queue chan timeSeries
+}
+
+// shardFor returns the shard of the series identified by labels.
+func (e *Exporter) shardFor(labels []label) *shard {
+	h := fnv.New64a()
+	for _, l := range labels {
+		h.Write([]byte(l.name))
+		h.Write([]byte{0})
+		h.Write([]byte(l.value))
+		h.Write([]byte{0})
+	}
+	return e.shards[h.Sum64()%uint64(len(e.shards))]
+}
+
+// run sends the queued samples until the queue is closed.
+func (s *shard) run() {
+	defer s.e.wg.Done()
+	batch := make([]timeSeries, 0, s.e.cfg.maxSamplesPerSend)
+	timer := time.NewTimer(s.e.cfg.batchSendDeadline)
+	defer timer.Stop()
+	flush := func() {
+		if len(batch) > 0 {
+			if err := s.e.send(batch); err != nil {
+				tel.Handle(err)
+			}
+			batch = batch[:0]
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(s.e.cfg.batchSendDeadline)
+	}
+	for {
+		select {
+		case ts, ok := <-s.queue:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, ts)
+			if len(batch) >= s.e.cfg.maxSamplesPerSend {
+				flush()
+			}
+		case <-timer.C:
+			flush()
+		}
+	}
+}
+
+// send writes series to the endpoint, retrying failed requests.
+func (e *Exporter) send(series []timeSeries) error {
+	body := snappy.Encode(nil, marshalWriteRequest(series))
+	retry := e.cfg.retry
+	start := time.Now()
+	interval := retry.InitialInterval
+	for {
+		wait, err := e.post(body)
+		if err == nil {
+			return nil
+		}
+		if wait < 0 || !retry.Enabled {
+			return err
+		}
+		if wait == 0 {
+			// Full jitter keeps shards from retrying in lockstep.
+			wait = time.Duration(rand.Int63n(int64(interval) + 1))
+			if interval *= 2; retry.MaxInterval > 0 && interval > retry.MaxInterval {
+				interval = retry.MaxInterval
+			}
+		}
+		if retry.MaxElapsedTime > 0 && time.Since(start)+wait > retry.MaxElapsedTime {
+			return fmt.Errorf("remote write: giving up after %s: %w", time.Since(start).Round(time.Millisecond), err)
+		}
+		select {
+		case <-time.After(wait):
+		case <-e.ctx.Done():
+			return fmt.Errorf("remote write: %w: %v", e.ctx.Err(), err)
+		}
+	}
+}
+
+// post sends a single request. On failure, it returns how long to wait
+// before retrying: zero to use the backoff, or -1 if the request must not
+// be retried.
+func (e *Exporter) post(body []byte) (time.Duration, error) {
+	ctx, cancel := context.WithTimeout(e.ctx, e.cfg.timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("User-Agent", "tel-remote-write")
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	for k, v := range e.cfg.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.cfg.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return 0, nil
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	err = fmt.Errorf("remote write: server returned HTTP status %s: %s", resp.Status, bytes.TrimSpace(msg))
+	if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode/100 != 5 {
+		return -1, err
+	}
+	if s, perr := strconv.Atoi(resp.Header.Get("Retry-After")); perr == nil && s > 0 {
+		return time.Duration(s) * time.Second, err
+	}
+	return 0, err
+}
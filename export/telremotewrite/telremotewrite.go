@@ -0,0 +1,120 @@
+package telremotewrite
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+)
+
+// ErrShutdown is returned when exporting with an Exporter that was shut
+// down.
+var ErrShutdown = errors.New("telremotewrite: exporter is shut down")
+
+// Exporter sends metrics to an endpoint accepting the Prometheus remote-write
+// protocol, such as Prometheus with the remote-write receiver enabled,
+// Cortex, Mimir or Thanos.
+//
+// Sums are sent as counters, named with a _total suffix, when monotonic,
+// and as gauges otherwise. Last values are sent as gauges, and histograms
+// as the _bucket, _sum and _count series of a Prometheus histogram. Series
+// are labeled with the resource attributes and the instrumentation library.
+//
+// Export queues the samples, which are sent asynchronously by a number of
+// shards with retries. Errors sending them are reported to tel.Handle.
+type Exporter struct {
+	endpoint string
+	cfg      config
+	shards   []*shard
+	wg       sync.WaitGroup
+
+	// ctx is canceled to abort requests when shutting down.
+	ctx    context.Context
+	cancel context.CancelFunc
+
+	// mu guards closing the queues against sending to them. closed is set
+	// once the queues are closed.
+	mu       sync.RWMutex
+	closed   bool
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+var _ telsdk.Exporter = (*Exporter)(nil)
+
+// New returns an Exporter sending metrics to the remote-write endpoint, such
+// as http://localhost:9090/api/v1/write.
+func New(endpoint string, opts ...Option) *Exporter {
+	ctx, cancel := context.WithCancel(context.Background())
+	e := &Exporter{
+		endpoint: endpoint,
+		cfg:      newConfig(opts),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+	}
+	e.shards = make([]*shard, e.cfg.shards)
+	for i := range e.shards {
+		e.shards[i] = &shard{e: e, queue: make(chan timeSeries, e.cfg.queueCapacity)}
+		e.wg.Add(1)
+		go e.shards[i].run()
+	}
+	return e
+}
+
+// Export queues the samples of the checkpoint. It blocks while the queues
+// are full, until ctx is done.
+func (e *Exporter) Export(ctx context.Context, res *telsdk.Resource, reader telsdk.InstrumentationLibraryReader) error {
+	series, err := e.convert(res, reader)
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	if e.closed {
+		return ErrShutdown
+	}
+	for _, ts := range series {
+		select {
+		case e.shardFor(ts.labels).queue <- ts:
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-e.done:
+			return ErrShutdown
+		}
+	}
+	return err
+}
+
+// TemporalityFor returns the temporality set with WithTemporalitySelector.
+func (e *Exporter) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind) aggregation.Temporality {
+	return e.cfg.temporalitySelector.TemporalityFor(desc, kind)
+}
+
+// Shutdown sends the queued samples and stops the exporter. If ctx is done
+// first, pending requests are abandoned. Stop the controller using the
+// exporter first.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.stopOnce.Do(func() {
+		close(e.done)
+		e.mu.Lock()
+		e.closed = true
+		for _, s := range e.shards {
+			close(s.queue)
+		}
+		e.mu.Unlock()
+	})
+	stopped := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		e.cancel()
+		return nil
+	case <-ctx.Done():
+		e.cancel()
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,415 @@
+package telremotewrite
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// receiver is a remote-write endpoint keeping the series it receives.
+type receiver struct {
+	t *testing.T
+
+	mu       sync.Mutex
+	series   []timeSeries
+	headers  []http.Header
+	statuses []int // responses to the next requests, 204 after them
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	r := &receiver{t: t, statuses: statuses}
+	srv := httptest.NewServer(r)
+	t.Cleanup(srv.Close)
+	return r, srv
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.headers = append(r.headers, req.Header.Clone())
+	if len(r.statuses) > 0 {
+		status := r.statuses[0]
+		r.statuses = r.statuses[1:]
+		if status/100 != 2 {
+			http.Error(w, "try again", status)
+			return
+		}
+	}
+	b, err := io.ReadAll(req.Body)
+	if err != nil {
+		r.t.Error(err)
+		return
+	}
+	if b, err = snappy.Decode(nil, b); err != nil {
+		r.t.Errorf("cannot decode the snappy body: %v", err)
+		return
+	}
+	series, err := unmarshalWriteRequest(b)
+	if err != nil {
+		r.t.Errorf("cannot decode the WriteRequest: %v", err)
+		return
+	}
+	r.series = append(r.series, series...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// byName returns the received series with the __name__ label name.
+func (r *receiver) byName(name string) []timeSeries {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var series []timeSeries
+	for _, ts := range r.series {
+		if labelValue(ts, nameLabel) == name {
+			series = append(series, ts)
+		}
+	}
+	return series
+}
+
+func (r *receiver) requests() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.headers)
+}
+
+func labelValue(ts timeSeries, name string) string {
+	for _, l := range ts.labels {
+		if l.name == name {
+			return l.value
+		}
+	}
+	return ""
+}
+
+// unmarshalWriteRequest decodes a WriteRequest with a single sample per
+// series, as sent by the Exporter.
+func unmarshalWriteRequest(b []byte) ([]timeSeries, error) {
+	var series []timeSeries
+	err := consumeMessage(b, func(num protowire.Number, v []byte) error {
+		if num != 1 {
+			return fmt.Errorf("unexpected WriteRequest field %d", num)
+		}
+		var ts timeSeries
+		err := consumeMessage(v, func(num protowire.Number, v []byte) error {
+			switch num {
+			case 1:
+				var l label
+				err := consumeMessage(v, func(num protowire.Number, v []byte) error {
+					if num == 1 {
+						l.name = string(v)
+					} else {
+						l.value = string(v)
+					}
+					return nil
+				})
+				ts.labels = append(ts.labels, l)
+				return err
+			case 2:
+				for len(v) > 0 {
+					num, typ, n := protowire.ConsumeTag(v)
+					if n < 0 {
+						return protowire.ParseError(n)
+					}
+					v = v[n:]
+					switch {
+					case num == 1 && typ == protowire.Fixed64Type:
+						f, n := protowire.ConsumeFixed64(v)
+						if n < 0 {
+							return protowire.ParseError(n)
+						}
+						ts.value, v = math.Float64frombits(f), v[n:]
+					case num == 2 && typ == protowire.VarintType:
+						t, n := protowire.ConsumeVarint(v)
+						if n < 0 {
+							return protowire.ParseError(n)
+						}
+						ts.timestamp, v = int64(t), v[n:]
+					default:
+						return fmt.Errorf("unexpected Sample field %d", num)
+					}
+				}
+				return nil
+			}
+			return fmt.Errorf("unexpected TimeSeries field %d", num)
+		})
+		series = append(series, ts)
+		return err
+	})
+	return series, err
+}
+
+// consumeMessage calls fn with the length-delimited fields of a message.
+func consumeMessage(b []byte, fn func(protowire.Number, []byte) error) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if typ != protowire.BytesType {
+			return fmt.Errorf("unexpected wire type %d of field %d", typ, num)
+		}
+		v, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+		if err := fn(num, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newController returns a controller holding a counter, an up-down counter,
+// a gauge and a histogram.
+func newController(t *testing.T) *telsdk.BasicController {
+	t.Helper()
+	ctrl := telsdk.NewBasicController(
+		telsdk.NewFactory(
+			telsdk.NewWithHistogramDistribution(telsdk.HistogramWithExplicitBoundaries([]float64{1, 10})),
+			aggregation.CumulativeTemporalitySelector(),
+		),
+		telsdk.WithBasicControllerResource(telsdk.NewSchemaless(tel.AttributeString("service.name", "test"))),
+	)
+	meter := ctrl.Meter("scope", metric.WithInstrumentationVersion("v1"))
+	counter, err := meter.SyncInt64().Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflight, err := meter.SyncInt64().UpDownCounter("inflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist, err := meter.SyncFloat64().Histogram("latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	temperature, err := meter.AsyncFloat64().Gauge("temperature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = meter.RegisterCallback([]instrument.Asynchronous{temperature}, func(ctx context.Context) {
+		temperature.Observe(ctx, 21.5)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	counter.Add(ctx, 3, tel.AttributeString("route", "/"))
+	inflight.Add(ctx, 2)
+	inflight.Add(ctx, -5)
+	for _, v := range []float64{0.5, 5, 50} {
+		hist.Record(ctx, v)
+	}
+	if err := ctrl.Collect(ctx); err != nil {
+		t.Fatal(err)
+	}
+	return ctrl
+}
+
+func exportAndShutdown(t *testing.T, e *Exporter, ctrl *telsdk.BasicController) {
+	t.Helper()
+	if err := e.Export(context.Background(), ctrl.Resource(), ctrl); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExport(t *testing.T) {
+	r, srv := newReceiver(t)
+	e := New(srv.URL, WithHeaders(map[string]string{"X-Scope-OrgID": "tenant"}))
+	exportAndShutdown(t, e, newController(t))
+
+	if h := r.headers[0]; h.Get("Content-Encoding") != "snappy" || h.Get("Content-Type") != "application/x-protobuf" ||
+		h.Get("X-Prometheus-Remote-Write-Version") != "0.1.0" || h.Get("X-Scope-OrgID") != "tenant" {
+		t.Errorf("got headers %v", h)
+	}
+
+	tests := []struct {
+		name  string
+		le    string
+		value float64
+	}{
+		{"requests_total", "", 3},
+		{"inflight", "", -3},
+		{"temperature", "", 21.5},
+		{"latency_bucket", "1", 1},
+		{"latency_bucket", "10", 2},
+		{"latency_bucket", "+Inf", 3},
+		{"latency_sum", "", 55.5},
+		{"latency_count", "", 3},
+	}
+	for _, tt := range tests {
+		var found bool
+		for _, ts := range r.byName(tt.name) {
+			if labelValue(ts, bucketLabel) != tt.le {
+				continue
+			}
+			found = true
+			if ts.value != tt.value {
+				t.Errorf("got %s{le=%q} %v, want %v", tt.name, tt.le, ts.value, tt.value)
+			}
+			if ts.timestamp <= 0 {
+				t.Errorf("got %s{le=%q} without a timestamp", tt.name, tt.le)
+			}
+			for k, v := range map[string]string{
+				"service_name":    "test",
+				scopeNameLabel:    "scope",
+				scopeVersionLabel: "v1",
+			} {
+				if got := labelValue(ts, k); got != v {
+					t.Errorf("got %s{%s=%q}, want %q", tt.name, k, got, v)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("got no %s{le=%q} series", tt.name, tt.le)
+		}
+	}
+	if got := r.byName("requests_total"); len(got) != 1 || labelValue(got[0], "route") != "/" {
+		t.Errorf("got requests_total %v, want the route label", got)
+	}
+}
+
+func TestExportWithoutLabels(t *testing.T) {
+	r, srv := newReceiver(t)
+	e := New(srv.URL, WithoutResourceLabels(), WithoutScopeLabels())
+	exportAndShutdown(t, e, newController(t))
+
+	series := r.byName("requests_total")
+	if len(series) != 1 {
+		t.Fatalf("got %d requests_total series, want 1", len(series))
+	}
+	for _, k := range []string{"service_name", scopeNameLabel, scopeVersionLabel} {
+		if v := labelValue(series[0], k); v != "" {
+			t.Errorf("got %s=%q, want no label", k, v)
+		}
+	}
+}
+
+func TestSendRetries(t *testing.T) {
+	r, srv := newReceiver(t, http.StatusServiceUnavailable, http.StatusTooManyRequests)
+	e := New(srv.URL, WithRetry(RetryConfig{
+		Enabled:         true,
+		InitialInterval: time.Millisecond,
+		MaxInterval:     time.Millisecond,
+	}))
+	defer e.Shutdown(context.Background())
+
+	series := []timeSeries{newTimeSeries("up", nil, 1, 1)}
+	if err := e.send(series); err != nil {
+		t.Fatal(err)
+	}
+	if got := r.requests(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+	if got := r.byName("up"); len(got) != 1 || got[0].value != 1 || got[0].timestamp != 1 {
+		t.Errorf("got series %v, want up 1 at 1", got)
+	}
+}
+
+func TestSendGivesUp(t *testing.T) {
+	tests := []struct {
+		name     string
+		statuses []int
+		retry    RetryConfig
+		requests int
+	}{
+		{
+			name:     "client error",
+			statuses: []int{http.StatusBadRequest},
+			retry:    RetryConfig{Enabled: true, InitialInterval: time.Millisecond},
+			requests: 1,
+		},
+		{
+			name:     "retry disabled",
+			statuses: []int{http.StatusServiceUnavailable},
+			retry:    RetryConfig{},
+			requests: 1,
+		},
+		{
+			name:     "max elapsed time",
+			statuses: []int{503, 503, 503, 503, 503, 503, 503, 503, 503, 503},
+			retry: RetryConfig{
+				Enabled:         true,
+				InitialInterval: 10 * time.Millisecond,
+				MaxInterval:     10 * time.Millisecond,
+				MaxElapsedTime:  25 * time.Millisecond,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, srv := newReceiver(t, tt.statuses...)
+			e := New(srv.URL, WithRetry(tt.retry))
+			defer e.Shutdown(context.Background())
+
+			if err := e.send([]timeSeries{newTimeSeries("up", nil, 1, 1)}); err == nil {
+				t.Error("send() = nil, want an error")
+			}
+			if got := r.requests(); tt.requests > 0 && got != tt.requests {
+				t.Errorf("got %d requests, want %d", got, tt.requests)
+			}
+			if len(r.byName("up")) != 0 {
+				t.Error("got the series accepted")
+			}
+		})
+	}
+}
+
+func TestShutdownAbortsRetries(t *testing.T) {
+	_, srv := newReceiver(t, 503, 503, 503, 503, 503, 503, 503, 503, 503, 503)
+	e := New(srv.URL, WithRetry(RetryConfig{Enabled: true, InitialInterval: time.Hour, MaxInterval: time.Hour}))
+	errc := make(chan error, 1)
+	go func() {
+		errc <- e.send([]timeSeries{newTimeSeries("up", nil, 1, 1)})
+	}()
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("send() = %v, want %v", err, context.Canceled)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("send() still retrying after Shutdown")
+	}
+}
+
+func TestExportAfterShutdown(t *testing.T) {
+	r, srv := newReceiver(t)
+	e := New(srv.URL)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown() = %v, want nil", err)
+	}
+	ctrl := newController(t)
+	if err := e.Export(context.Background(), ctrl.Resource(), ctrl); !errors.Is(err, ErrShutdown) {
+		t.Errorf("Export() = %v, want %v", err, ErrShutdown)
+	}
+	if got := r.requests(); got != 0 {
+		t.Errorf("got %d requests, want none", got)
+	}
+}
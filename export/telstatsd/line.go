@@ -0,0 +1,85 @@
+package telstatsd
+
+import (
+	"net"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// line is a StatsD line: <name>:<value>|<type>[|@<rate>][|#<tags>].
+type line struct {
+	name  string
+	value float64
+	typ   string
+	rate  float64 // sample rate, or zero if unsampled
+	tags  []attribute.KeyValue
+}
+
+var (
+	nameReplacer = strings.NewReplacer(":", "_", "|", "_", "@", "_", "\n", "_")
+	tagReplacer  = strings.NewReplacer(":", "_", "|", "_", ",", "_", "#", "_", "\n", "_")
+	// tagValueReplacer keeps the colons of values, such as URLs, which
+	// DogStatsD splits on the first colon only.
+	tagValueReplacer = strings.NewReplacer("|", "_", ",", "_", "\n", "_")
+)
+
+func (l line) append(b []byte) []byte {
+	b = append(b, nameReplacer.Replace(l.name)...)
+	b = append(b, ':')
+	b = strconv.AppendFloat(b, l.value, 'f', -1, 64)
+	b = append(b, '|')
+	b = append(b, l.typ...)
+	if l.rate > 0 && l.rate < 1 {
+		b = append(b, "|@"...)
+		b = strconv.AppendFloat(b, l.rate, 'g', -1, 64)
+	}
+	if len(l.tags) > 0 {
+		b = append(b, "|#"...)
+		for i, kv := range l.tags {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			b = append(b, tagReplacer.Replace(string(kv.Key))...)
+			b = append(b, ':')
+			b = append(b, tagValueReplacer.Replace(kv.Value.Emit())...)
+		}
+	}
+	return b
+}
+
+// packetWriter buffers lines, sending them together in datagrams of at most
+// max bytes. Lines larger than max are sent on their own.
+type packetWriter struct {
+	conn net.Conn
+	max  int
+	buf  []byte
+	// err is the first error sending a datagram.
+	err error
+}
+
+func (w *packetWriter) write(line []byte) {
+	if len(w.buf) > 0 && len(w.buf)+1+len(line) > w.max {
+		_ = w.flush()
+	}
+	if len(w.buf) > 0 {
+		w.buf = append(w.buf, '\n')
+	}
+	w.buf = append(w.buf, line...)
+	if len(w.buf) >= w.max {
+		_ = w.flush()
+	}
+}
+
+func (w *packetWriter) flush() error {
+	if len(w.buf) == 0 {
+		return nil
+	}
+	_, err := w.conn.Write(w.buf)
+	w.buf = w.buf[:0]
+	if err != nil && w.err == nil {
+		w.err = err
+	}
+	return err
+}
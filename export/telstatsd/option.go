@@ -0,0 +1,137 @@
+package telstatsd
+
+import (
+	"time"
+
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+// HistogramType is the StatsD metric type histograms are sent as.
+type HistogramType string
+
+const (
+	// HistogramTypeHistogram sends histograms as "h", aggregated by the
+	// agent on each host.
+	HistogramTypeHistogram HistogramType = "h"
+	// HistogramTypeDistribution sends histograms as DogStatsD
+	// distributions, "d", aggregated globally by Datadog.
+	HistogramTypeDistribution HistogramType = "d"
+)
+
+const (
+	// defaultUDPPacketSize is the payload fitting an Ethernet frame,
+	// as used by the DogStatsD clients.
+	defaultUDPPacketSize = 1432
+	// defaultUnixPacketSize is the default buffer of the DogStatsD
+	// agent for Unix domain sockets.
+	defaultUnixPacketSize = 8192
+)
+
+type config struct {
+	prefix              string
+	tags                []attribute.KeyValue
+	resourceTags        bool
+	disableTags         bool
+	resetNegativeGauges bool
+	histogramType       HistogramType
+	maxPacketSize       int
+	temporalitySelector aggregation.TemporalitySelector
+	staleness           time.Duration
+}
+
+func newConfig(network string, opts []Option) config {
+	cfg := config{
+		histogramType:       HistogramTypeHistogram,
+		maxPacketSize:       defaultUDPPacketSize,
+		temporalitySelector: statsdTemporalitySelector{},
+		staleness:           telsdk.DefaultTemporalityConverterStaleness,
+	}
+	if network == "unixgram" {
+		cfg.maxPacketSize = defaultUnixPacketSize
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// Option configures a StatsD Exporter.
+type Option func(*config)
+
+// WithPrefix sets a prefix prepended to every metric name, such as "myapp.".
+func WithPrefix(prefix string) Option {
+	return func(cfg *config) {
+		cfg.prefix = prefix
+	}
+}
+
+// WithTags sets tags sent with every metric, in addition to the attributes
+// of each measurement.
+func WithTags(tags ...attribute.KeyValue) Option {
+	return func(cfg *config) {
+		cfg.tags = append(cfg.tags, tags...)
+	}
+}
+
+// WithResourceTags sends the resource attributes as tags of every metric.
+func WithResourceTags() Option {
+	return func(cfg *config) {
+		cfg.resourceTags = true
+	}
+}
+
+// WithoutTags stops sending tags, which plain StatsD servers don't support.
+// Series differing only by their attributes are then sent under the same
+// name.
+func WithoutTags() Option {
+	return func(cfg *config) {
+		cfg.disableTags = true
+	}
+}
+
+// WithNegativeGaugeReset sends negative gauges as zero followed by the
+// value, as plain StatsD servers, such as Etsy's statsd, read a gauge with
+// a sign as a change of its value. DogStatsD doesn't need it.
+func WithNegativeGaugeReset() Option {
+	return func(cfg *config) {
+		cfg.resetNegativeGauges = true
+	}
+}
+
+// WithHistogramType sets the metric type histograms are sent as. If unset,
+// HistogramTypeHistogram is used.
+func WithHistogramType(t HistogramType) Option {
+	return func(cfg *config) {
+		cfg.histogramType = t
+	}
+}
+
+// WithMaxPacketSize sets the largest datagram sent. Lines are buffered and
+// sent together up to this size. If unset, 1432 bytes is used for UDP and
+// 8192 bytes for Unix domain sockets.
+func WithMaxPacketSize(bytes int) Option {
+	return func(cfg *config) {
+		cfg.maxPacketSize = bytes
+	}
+}
+
+// WithTemporalitySelector sets the aggregation.TemporalitySelector used by
+// the exporter. StatsD counters and histograms are deltas, so the default
+// uses delta temporality for them and cumulative temporality for the sums
+// of non-monotonic instruments, which are sent as gauges.
+func WithTemporalitySelector(selector aggregation.TemporalitySelector) Option {
+	return func(cfg *config) {
+		cfg.temporalitySelector = selector
+	}
+}
+
+// WithStaleness sets how long the last value of a cumulative counter is
+// kept after it was last exported. A counter exported again after that is
+// sent whole. If unset, telsdk.DefaultTemporalityConverterStaleness is used.
+func WithStaleness(d time.Duration) Option {
+	return func(cfg *config) {
+		cfg.staleness = d
+	}
+}
@@ -0,0 +1,259 @@
+package telstatsd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	export "go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+)
+
+// ErrUnsupportedAggregator is returned for aggregations that can't be
+// represented in StatsD.
+var ErrUnsupportedAggregator = errors.New("unsupported aggregator type")
+
+// ErrShutdown is returned when exporting with an Exporter that was shut
+// down.
+var ErrShutdown = errors.New("telstatsd: exporter is shut down")
+
+// Exporter sends metrics to a StatsD or DogStatsD agent.
+//
+// Monotonic sums are sent as counters, "c", and last values and the sums of
+// non-monotonic instruments as gauges, "g". Histograms are sent as "h" or
+// "d" depending on WithHistogramType. As the checkpoint only holds bucket
+// counts, each non-empty bucket is sent as a single value, the middle of
+// the bucket, with a sample rate of one over its count; the agent scales
+// it back to the right count, but the sum and percentiles it derives are
+// approximate. A histogram holding a single measurement is sent exactly.
+//
+// Attributes are sent as DogStatsD tags. Plain StatsD servers read a
+// gauge with a sign as a change of its value, so use WithoutTags and
+// WithNegativeGaugeReset with them.
+type Exporter struct {
+	cfg config
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+	// counters holds the last value of the cumulative counters, which are
+	// sent as the difference from it.
+	counters map[seriesKey]*counterState
+}
+
+type counterState struct {
+	value float64
+	seen  time.Time
+}
+
+type seriesKey struct {
+	descriptor *sdkapi.Descriptor
+	attrs      attribute.Distinct
+}
+
+var _ telsdk.Exporter = (*Exporter)(nil)
+
+// New returns an Exporter sending metrics to the agent at address, where
+// network is "udp" or "unixgram", e.g.:
+//
+//	telstatsd.New("udp", "localhost:8125")
+//	telstatsd.New("unixgram", "/var/run/datadog/dsd.socket")
+func New(network, address string, opts ...Option) (*Exporter, error) {
+	switch network {
+	case "udp", "udp4", "udp6", "unixgram":
+	default:
+		return nil, fmt.Errorf("telstatsd: unsupported network %q", network)
+	}
+	conn, err := net.Dial(network, address)
+	if err != nil {
+		return nil, err
+	}
+	return &Exporter{
+		cfg:      newConfig(network, opts),
+		conn:     conn,
+		counters: map[seriesKey]*counterState{},
+	}, nil
+}
+
+// Export sends the checkpoint to the agent.
+func (e *Exporter) Export(ctx context.Context, res *telsdk.Resource, reader telsdk.InstrumentationLibraryReader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return ErrShutdown
+	}
+
+	var common []attribute.KeyValue
+	if !e.cfg.disableTags {
+		if e.cfg.resourceTags && res != nil {
+			common = append(common, res.Attributes()...)
+		}
+		common = append(common, e.cfg.tags...)
+	}
+	now := time.Now()
+	w := &packetWriter{conn: e.conn, max: e.cfg.maxPacketSize}
+	err := reader.ForEach(func(_ instrumentation.Library, r export.Reader) error {
+		return r.ForEach(e, func(record export.Record) error {
+			return e.writeRecord(w, common, record, now)
+		})
+	})
+	_ = w.flush()
+	for key, c := range e.counters {
+		if now.Sub(c.seen) > e.cfg.staleness {
+			delete(e.counters, key)
+		}
+	}
+	if err == nil {
+		err = w.err
+	}
+	return err
+}
+
+func (e *Exporter) writeRecord(w *packetWriter, common []attribute.KeyValue, record export.Record, now time.Time) error {
+	descriptor := record.Descriptor()
+	kind := descriptor.NumberKind()
+	l := line{name: e.cfg.prefix + descriptor.Name()}
+	if !e.cfg.disableTags {
+		l.tags = append(append([]attribute.KeyValue(nil), common...), record.Attributes().ToSlice()...)
+	}
+
+	switch agg := record.Aggregation().(type) {
+	case aggregation.Histogram:
+		buckets, err := agg.Histogram()
+		if err != nil {
+			return fmt.Errorf("error retrieving histogram: %w", err)
+		}
+		count, err := agg.Count()
+		if err != nil {
+			return fmt.Errorf("error retrieving count: %w", err)
+		}
+		l.typ = string(e.cfg.histogramType)
+		if count == 1 {
+			sum, err := agg.Sum()
+			if err != nil {
+				return fmt.Errorf("error retrieving sum: %w", err)
+			}
+			l.value = sum.CoerceToFloat64(kind)
+			w.write(l.append(nil))
+			return nil
+		}
+		for i, n := range buckets.Counts {
+			if n == 0 {
+				continue
+			}
+			l.value = bucketValue(buckets.Boundaries, i)
+			l.rate = 1 / float64(n)
+			w.write(l.append(nil))
+		}
+	case aggregation.Sum:
+		v, err := agg.Sum()
+		if err != nil {
+			return fmt.Errorf("error retrieving sum: %w", err)
+		}
+		l.value = v.CoerceToFloat64(kind)
+		l.typ = "c"
+		if !descriptor.InstrumentKind().Monotonic() {
+			e.writeGauge(w, l)
+			return nil
+		}
+		if e.TemporalityFor(descriptor, agg.Kind()) == aggregation.CumulativeTemporality {
+			l.value = e.counterDelta(seriesKey{descriptor, record.Attributes().Equivalent()}, l.value, now)
+		}
+		w.write(l.append(nil))
+	case aggregation.LastValue:
+		v, _, err := agg.LastValue()
+		if err != nil {
+			return fmt.Errorf("error retrieving last value: %w", err)
+		}
+		l.value = v.CoerceToFloat64(kind)
+		e.writeGauge(w, l)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedAggregator, agg.Kind())
+	}
+	return nil
+}
+
+// writeGauge writes l as a gauge. With WithNegativeGaugeReset, a negative
+// value is sent after setting the gauge to zero.
+func (e *Exporter) writeGauge(w *packetWriter, l line) {
+	l.typ = "g"
+	if l.value < 0 && e.cfg.resetNegativeGauges {
+		zero := l
+		zero.value = 0
+		w.write(zero.append(nil))
+	}
+	w.write(l.append(nil))
+}
+
+// counterDelta returns the increment of a cumulative counter since the last
+// export. A counter that went down was reset, so all of its value is new.
+// Counters not exported for longer than the staleness set with
+// WithStaleness are forgotten.
+func (e *Exporter) counterDelta(key seriesKey, v float64, now time.Time) float64 {
+	c, ok := e.counters[key]
+	if !ok {
+		e.counters[key] = &counterState{value: v, seen: now}
+		return v
+	}
+	last := c.value
+	c.value, c.seen = v, now
+	if v < last {
+		return v
+	}
+	return v - last
+}
+
+// bucketValue returns the value representing the measurements of bucket i:
+// the middle of the bucket, or its finite boundary for the first and last
+// buckets.
+func bucketValue(boundaries []float64, i int) float64 {
+	switch {
+	case len(boundaries) == 0:
+		return 0
+	case i == 0:
+		return boundaries[0]
+	case i >= len(boundaries):
+		return boundaries[len(boundaries)-1]
+	}
+	return (boundaries[i-1] + boundaries[i]) / 2
+}
+
+// TemporalityFor returns the temporality set with WithTemporalitySelector.
+func (e *Exporter) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind) aggregation.Temporality {
+	return e.cfg.temporalitySelector.TemporalityFor(desc, kind)
+}
+
+// Shutdown closes the connection to the agent. Stop the controller using the
+// exporter first.
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.closed {
+		return nil
+	}
+	e.closed = true
+	return e.conn.Close()
+}
+
+// statsdTemporalitySelector selects delta temporality for everything but
+// the sums of non-monotonic instruments, which are sent as gauges, and of
+// asynchronous counters, which the processor can't convert to deltas. The
+// exporter computes the deltas of the latter itself.
+type statsdTemporalitySelector struct{}
+
+func (statsdTemporalitySelector) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind) aggregation.Temporality {
+	if desc.InstrumentKind().PrecomputedSum() || kind == aggregation.SumKind && !desc.InstrumentKind().Monotonic() {
+		return aggregation.CumulativeTemporality
+	}
+	return aggregation.DeltaTemporality
+}
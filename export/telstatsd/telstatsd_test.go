@@ -0,0 +1,277 @@
+package telstatsd
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/metric/instrument"
+)
+
+// agent is a local UDP listener standing for a StatsD agent.
+type agent struct {
+	t    *testing.T
+	conn net.PacketConn
+}
+
+func newAgent(t *testing.T) *agent {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return &agent{t: t, conn: conn}
+}
+
+// newExporter returns an Exporter sending to a and a controller using it as
+// its temporality selector.
+func (a *agent) newExporter(opts ...Option) (*Exporter, *telsdk.BasicController) {
+	a.t.Helper()
+	e, err := New("udp", a.conn.LocalAddr().String(), opts...)
+	if err != nil {
+		a.t.Fatal(err)
+	}
+	a.t.Cleanup(func() { e.Shutdown(context.Background()) })
+	ctrl := telsdk.NewBasicController(
+		telsdk.NewFactory(telsdk.NewWithHistogramDistribution(telsdk.HistogramWithExplicitBoundaries([]float64{1, 10})), e),
+		telsdk.WithBasicControllerResource(telsdk.NewSchemaless(tel.AttributeString("service.name", "test"))),
+		telsdk.WithBasicControllerCollectPeriod(0),
+	)
+	return e, ctrl
+}
+
+// export collects ctrl and exports it with e, returning the packets sent.
+func (a *agent) export(e *Exporter, ctrl *telsdk.BasicController) []string {
+	a.t.Helper()
+	ctx := context.Background()
+	if err := ctrl.Collect(ctx); err != nil {
+		a.t.Fatal(err)
+	}
+	if err := e.Export(ctx, ctrl.Resource(), ctrl); err != nil {
+		a.t.Fatal(err)
+	}
+	var packets []string
+	buf := make([]byte, 65536)
+	for {
+		if err := a.conn.SetReadDeadline(time.Now().Add(50 * time.Millisecond)); err != nil {
+			a.t.Fatal(err)
+		}
+		n, _, err := a.conn.ReadFrom(buf)
+		if err != nil {
+			var nerr net.Error
+			if errors.As(err, &nerr) && nerr.Timeout() {
+				return packets
+			}
+			a.t.Fatal(err)
+		}
+		packets = append(packets, string(buf[:n]))
+	}
+}
+
+// lines returns the lines of packets.
+func lines(packets []string) []string {
+	var ls []string
+	for _, p := range packets {
+		ls = append(ls, strings.Split(p, "\n")...)
+	}
+	return ls
+}
+
+func assertLines(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got lines\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestExportLines(t *testing.T) {
+	a := newAgent(t)
+	e, ctrl := a.newExporter(WithPrefix("app."))
+	meter := ctrl.Meter("scope")
+	counter, err := meter.SyncInt64().Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	inflight, err := meter.SyncInt64().UpDownCounter("inflight")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist, err := meter.SyncFloat64().Histogram("latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := meter.SyncFloat64().Histogram("size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	counter.Add(ctx, 3)
+	inflight.Add(ctx, 2)
+	for _, v := range []float64{0.5, 5, 6} {
+		hist.Record(ctx, v)
+	}
+	single.Record(ctx, 42.5)
+
+	got := lines(a.export(e, ctrl))
+	assertLines(t, sortedLines(got),
+		"app.inflight:2|g",
+		"app.latency:1|h",
+		"app.latency:5.5|h|@0.5",
+		"app.requests:3|c",
+		"app.size:42.5|h",
+	)
+
+	// Counters and histograms are deltas, sums of up-down counters
+	// cumulative.
+	counter.Add(ctx, 1)
+	inflight.Add(ctx, -1)
+	assertLines(t, sortedLines(lines(a.export(e, ctrl))),
+		"app.inflight:1|g",
+		"app.requests:1|c",
+	)
+}
+
+func TestExportDistribution(t *testing.T) {
+	a := newAgent(t)
+	e, ctrl := a.newExporter(WithHistogramType(HistogramTypeDistribution))
+	hist, err := ctrl.Meter("scope").SyncInt64().Histogram("latency")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.Record(context.Background(), 7)
+	assertLines(t, lines(a.export(e, ctrl)), "latency:7|d")
+}
+
+func TestExportTags(t *testing.T) {
+	a := newAgent(t)
+	e, ctrl := a.newExporter(WithResourceTags(), WithTags(tel.AttributeString("env", "prod")))
+	counter, err := ctrl.Meter("scope").SyncInt64().Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 1, tel.AttributeString("url", "http://x|y"), tel.AttributeString("a,b", "c"))
+	assertLines(t, lines(a.export(e, ctrl)),
+		"requests:1|c|#service.name:test,env:prod,a_b:c,url:http://x_y")
+
+	a = newAgent(t)
+	e, ctrl = a.newExporter(WithResourceTags(), WithTags(tel.AttributeString("env", "prod")), WithoutTags())
+	counter, err = ctrl.Meter("scope").SyncInt64().Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 1, tel.AttributeString("route", "/"))
+	assertLines(t, lines(a.export(e, ctrl)), "requests:1|c")
+}
+
+func TestExportNegativeGauge(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"default", nil, []string{"temperature:-3|g"}},
+		{"without tags", []Option{WithoutTags()}, []string{"temperature:-3|g"}},
+		{"reset", []Option{WithNegativeGaugeReset()}, []string{"temperature:0|g", "temperature:-3|g"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAgent(t)
+			e, ctrl := a.newExporter(tt.opts...)
+			meter := ctrl.Meter("scope")
+			gauge, err := meter.AsyncInt64().Gauge("temperature")
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = meter.RegisterCallback([]instrument.Asynchronous{gauge}, func(ctx context.Context) {
+				gauge.Observe(ctx, -3)
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			assertLines(t, lines(a.export(e, ctrl)), tt.want...)
+		})
+	}
+}
+
+func TestExportCumulativeCounterDeltas(t *testing.T) {
+	a := newAgent(t)
+	e, ctrl := a.newExporter()
+	meter := ctrl.Meter("scope")
+	counter, err := meter.AsyncInt64().Counter("bytes")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var value int64
+	err = meter.RegisterCallback([]instrument.Asynchronous{counter}, func(ctx context.Context) {
+		counter.Observe(ctx, value)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range []struct {
+		value int64
+		want  string
+	}{
+		{5, "bytes:5|c"},
+		{8, "bytes:3|c"},
+		{8, "bytes:0|c"},
+		{2, "bytes:2|c"}, // reset
+		{6, "bytes:4|c"},
+	} {
+		value = tt.value
+		assertLines(t, lines(a.export(e, ctrl)), tt.want)
+	}
+}
+
+func TestExportSplitsPackets(t *testing.T) {
+	a := newAgent(t)
+	const max = 40
+	e, ctrl := a.newExporter(WithMaxPacketSize(max))
+	meter := ctrl.Meter("scope")
+	var want []string
+	for _, name := range []string{"aaaaaaaaaa", "bbbbbbbbbb", "cccccccccc", "dddddddddd", strings.Repeat("e", 50)} {
+		counter, err := meter.SyncInt64().Counter(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter.Add(context.Background(), 1)
+		want = append(want, name+":1|c")
+	}
+
+	packets := a.export(e, ctrl)
+	if len(packets) < 3 {
+		t.Errorf("got %d packets, want the lines split in at least 3", len(packets))
+	}
+	for _, p := range packets {
+		if len(p) > max && strings.Contains(p, "\n") {
+			t.Errorf("got a packet of %d bytes holding several lines, want at most %d", len(p), max)
+		}
+	}
+	assertLines(t, sortedLines(lines(packets)), want...)
+}
+
+func TestExportAfterShutdown(t *testing.T) {
+	a := newAgent(t)
+	e, ctrl := a.newExporter()
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("second Shutdown() = %v, want nil", err)
+	}
+	if err := e.Export(context.Background(), ctrl.Resource(), ctrl); !errors.Is(err, ErrShutdown) {
+		t.Errorf("Export() = %v, want %v", err, ErrShutdown)
+	}
+}
+
+func sortedLines(ls []string) []string {
+	ls = append([]string(nil), ls...)
+	sort.Strings(ls)
+	return ls
+}
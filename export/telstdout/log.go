@@ -0,0 +1,134 @@
+package telexporter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type stdoutLogConfig struct {
+	writer      io.Writer
+	prettyPrint bool
+	timestamps  bool
+}
+
+// StdoutLogOption sets the value of an option for a Config.
+type StdoutLogOption func(*stdoutLogConfig)
+
+// WithStdoutLogWriter sets the export stream destination.
+func WithStdoutLogWriter(w io.Writer) StdoutLogOption {
+	return func(cfg *stdoutLogConfig) {
+		cfg.writer = w
+	}
+}
+
+// WithStdoutLogPrettyPrint sets the export stream format to use JSON.
+func WithStdoutLogPrettyPrint() StdoutLogOption {
+	return func(cfg *stdoutLogConfig) {
+		cfg.prettyPrint = true
+	}
+}
+
+// WithoutStdoutLogTimestamps sets the export stream to not include timestamps.
+func WithoutStdoutLogTimestamps() StdoutLogOption {
+	return func(cfg *stdoutLogConfig) {
+		cfg.timestamps = false
+	}
+}
+
+// StdoutLogExporter is a telsdk.LogExporter that writes log records to
+// stdout, as JSON objects.
+type StdoutLogExporter struct {
+	config stdoutLogConfig
+
+	encoderMu sync.Mutex
+	encoder   *json.Encoder
+
+	stoppedMu sync.RWMutex
+	stopped   bool
+}
+
+var _ telsdk.LogExporter = (*StdoutLogExporter)(nil)
+
+// NewStdoutLog creates an Exporter with the passed options.
+func NewStdoutLog(options ...StdoutLogOption) (*StdoutLogExporter, error) {
+	cfg := stdoutLogConfig{
+		writer:     os.Stdout,
+		timestamps: true,
+	}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	enc := json.NewEncoder(cfg.writer)
+	if cfg.prettyPrint {
+		enc.SetIndent("", "\t")
+	}
+	return &StdoutLogExporter{config: cfg, encoder: enc}, nil
+}
+
+type stdoutLogRecord struct {
+	Timestamp              time.Time
+	ObservedTimestamp      time.Time
+	Severity               tel.Severity
+	SeverityText           string
+	Body                   tel.AnyValue
+	Attributes             []tel.AnyKeyValue
+	TraceID                tel.TraceID
+	SpanID                 tel.SpanID
+	TraceFlags             trace.TraceFlags
+	Resource               *telsdk.Resource
+	InstrumentationLibrary telsdk.InstrumentationLibrary
+}
+
+// ExportLogs writes the records to the writer, one JSON object each. The
+// body and the values of the attributes are written as by the MarshalJSON
+// method of tel.AnyValue.
+func (e *StdoutLogExporter) ExportLogs(ctx context.Context, records []telsdk.LogData) error {
+	e.stoppedMu.RLock()
+	stopped := e.stopped
+	e.stoppedMu.RUnlock()
+	if stopped {
+		return nil
+	}
+
+	e.encoderMu.Lock()
+	defer e.encoderMu.Unlock()
+	for _, r := range records {
+		line := stdoutLogRecord{
+			Timestamp:              r.Timestamp,
+			ObservedTimestamp:      r.ObservedTimestamp,
+			Severity:               r.Severity,
+			SeverityText:           r.SeverityText,
+			Body:                   r.Body,
+			Attributes:             r.Attributes,
+			TraceID:                r.TraceID,
+			SpanID:                 r.SpanID,
+			TraceFlags:             r.TraceFlags,
+			Resource:               r.Resource,
+			InstrumentationLibrary: r.InstrumentationLibrary,
+		}
+		if !e.config.timestamps {
+			line.Timestamp = time.Time{}
+			line.ObservedTimestamp = time.Time{}
+		}
+		if err := e.encoder.Encode(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown stops the exporter, which then drops the records.
+func (e *StdoutLogExporter) Shutdown(ctx context.Context) error {
+	e.stoppedMu.Lock()
+	e.stopped = true
+	e.stoppedMu.Unlock()
+	return ctx.Err()
+}
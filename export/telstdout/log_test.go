@@ -0,0 +1,52 @@
+package telexporter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+)
+
+func TestStdoutLogAnyValues(t *testing.T) {
+	var buf bytes.Buffer
+	exp, err := NewStdoutLog(WithStdoutLogWriter(&buf), WithoutStdoutLogTimestamps())
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = exp.ExportLogs(context.Background(), []telsdk.LogData{{
+		LogRecord: tel.LogRecord{
+			Body: tel.AnyMap(
+				tel.AnyAttribute("msg", tel.AnyString("hi")),
+				tel.AnyAttribute("raw", tel.AnyBytes([]byte("hi"))),
+			),
+			Attributes: []tel.AnyKeyValue{
+				tel.AnyAttribute("list", tel.AnyArray(tel.AnyOf(tel.Int64Value(1)), tel.AnyString("a"))),
+			},
+		},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var got struct {
+		Body       json.RawMessage
+		Attributes json.RawMessage
+	}
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("%v: %s", err, buf.Bytes())
+	}
+	wantBody := `{"Type":"MAP","Value":[` +
+		`{"Key":"msg","Value":{"Type":"STRING","Value":"hi"}},` +
+		`{"Key":"raw","Value":{"Type":"BYTES","Value":"aGk="}}]}`
+	if string(got.Body) != wantBody {
+		t.Errorf("got body %s, want %s", got.Body, wantBody)
+	}
+	wantAttrs := `[{"Key":"list","Value":{"Type":"ARRAY","Value":[` +
+		`{"Type":"INT64","Value":1},{"Type":"STRING","Value":"a"}]}}]`
+	if string(got.Attributes) != wantAttrs {
+		t.Errorf("got attributes %s, want %s", got.Attributes, wantAttrs)
+	}
+}
@@ -0,0 +1,228 @@
+package telexporter
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+)
+
+type stdoutExponentialMetricConfig struct {
+	writer      io.Writer
+	prettyPrint bool
+	timestamps  bool
+	encoder     attribute.Encoder
+}
+
+// StdoutExponentialMetricOption sets the value of an option for a Config.
+type StdoutExponentialMetricOption func(*stdoutExponentialMetricConfig)
+
+// WithStdoutExponentialMetricWriter sets the export stream destination.
+func WithStdoutExponentialMetricWriter(w io.Writer) StdoutExponentialMetricOption {
+	return func(cfg *stdoutExponentialMetricConfig) {
+		cfg.writer = w
+	}
+}
+
+// WithStdoutExponentialMetricPrettyPrint sets the export stream format to use JSON.
+func WithStdoutExponentialMetricPrettyPrint() StdoutExponentialMetricOption {
+	return func(cfg *stdoutExponentialMetricConfig) {
+		cfg.prettyPrint = true
+	}
+}
+
+// WithoutStdoutExponentialMetricTimestamps sets the export stream to not include timestamps.
+func WithoutStdoutExponentialMetricTimestamps() StdoutExponentialMetricOption {
+	return func(cfg *stdoutExponentialMetricConfig) {
+		cfg.timestamps = false
+	}
+}
+
+// WithStdoutExponentialMetricAttributeEncoder sets the attribute encoder used in export.
+func WithStdoutExponentialMetricAttributeEncoder(enc attribute.Encoder) StdoutExponentialMetricOption {
+	return func(cfg *stdoutExponentialMetricConfig) {
+		cfg.encoder = enc
+	}
+}
+
+// StdoutExponentialMetricExporter is an OpenTelemetry metric exporter that
+// transmits telemetry to the local STDOUT.
+//
+// It writes the same lines as StdoutMetricExporter, adding the count of
+// histograms and the buckets of telsdk.ExponentialHistogram aggregations.
+type StdoutExponentialMetricExporter struct {
+	config stdoutExponentialMetricConfig
+}
+
+var _ telsdk.Exporter = (*StdoutExponentialMetricExporter)(nil)
+
+// NewStdoutExponentialMetric creates an Exporter with the passed options.
+func NewStdoutExponentialMetric(options ...StdoutExponentialMetricOption) (*StdoutExponentialMetricExporter, error) {
+	cfg := stdoutExponentialMetricConfig{
+		writer:     os.Stdout,
+		timestamps: true,
+		encoder:    attribute.DefaultEncoder(),
+	}
+	for _, opt := range options {
+		opt(&cfg)
+	}
+	return &StdoutExponentialMetricExporter{config: cfg}, nil
+}
+
+type stdoutMetricLine struct {
+	Name      string      `json:"Name"`
+	Sum       interface{} `json:"Sum,omitempty"`
+	Count     interface{} `json:"Count,omitempty"`
+	LastValue interface{} `json:"Last,omitempty"`
+
+	Scale     *int32               `json:"Scale,omitempty"`
+	ZeroCount uint64               `json:"ZeroCount,omitempty"`
+	Positive  *stdoutMetricBuckets `json:"Positive,omitempty"`
+	Negative  *stdoutMetricBuckets `json:"Negative,omitempty"`
+
+	// Note: this is a pointer because omitempty doesn't work when time.IsZero()
+	Timestamp *time.Time `json:"Timestamp,omitempty"`
+}
+
+type stdoutMetricBuckets struct {
+	Offset int32    `json:"Offset"`
+	Counts []uint64 `json:"Counts"`
+}
+
+func newStdoutMetricBuckets(b telsdk.ExponentialBuckets) *stdoutMetricBuckets {
+	if b.Len() == 0 {
+		return nil
+	}
+	counts := make([]uint64, b.Len())
+	for i := range counts {
+		counts[i] = b.At(uint32(i))
+	}
+	return &stdoutMetricBuckets{Offset: b.Offset(), Counts: counts}
+}
+
+// TemporalityFor uses the temporality the records are computed with.
+func (e *StdoutExponentialMetricExporter) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind) aggregation.Temporality {
+	return aggregation.StatelessTemporalitySelector().TemporalityFor(desc, kind)
+}
+
+// Export writes the checkpoint as a single line.
+func (e *StdoutExponentialMetricExporter) Export(_ context.Context, res *telsdk.Resource, reader telsdk.InstrumentationLibraryReader) error {
+	var batch []stdoutMetricLine
+	aggError := reader.ForEach(func(lib instrumentation.Library, mr export.Reader) error {
+		var instAttrs []attribute.KeyValue
+		if name := lib.Name; name != "" {
+			instAttrs = append(instAttrs, attribute.String("instrumentation.name", name))
+			if version := lib.Version; version != "" {
+				instAttrs = append(instAttrs, attribute.String("instrumentation.version", version))
+			}
+			if schema := lib.SchemaURL; schema != "" {
+				instAttrs = append(instAttrs, attribute.String("instrumentation.schema_url", schema))
+			}
+		}
+		instSet := attribute.NewSet(instAttrs...)
+		encodedInstAttrs := instSet.Encoded(e.config.encoder)
+
+		return mr.ForEach(e, func(record export.Record) error {
+			desc := record.Descriptor()
+			agg := record.Aggregation()
+			kind := desc.NumberKind()
+			encodedResource := res.Encoded(e.config.encoder)
+
+			var expose stdoutMetricLine
+
+			if h, ok := agg.(telsdk.ExponentialHistogram); ok {
+				count, err := h.Count()
+				if err != nil {
+					return err
+				}
+				expose.Count = count
+				scale := h.Scale()
+				expose.Scale = &scale
+				expose.ZeroCount = h.ZeroCount()
+				expose.Positive = newStdoutMetricBuckets(h.Positive())
+				expose.Negative = newStdoutMetricBuckets(h.Negative())
+			} else if h, ok := agg.(aggregation.Histogram); ok {
+				count, err := h.Count()
+				if err != nil {
+					return err
+				}
+				expose.Count = count
+			}
+			if sum, ok := agg.(aggregation.Sum); ok {
+				value, err := sum.Sum()
+				if err != nil {
+					return err
+				}
+				expose.Sum = value.AsInterface(kind)
+			} else if lv, ok := agg.(aggregation.LastValue); ok {
+				value, timestamp, err := lv.LastValue()
+				if err != nil {
+					return err
+				}
+				expose.LastValue = value.AsInterface(kind)
+
+				if e.config.timestamps {
+					expose.Timestamp = &timestamp
+				}
+			}
+
+			var encodedAttrs string
+			iter := record.Attributes().Iter()
+			if iter.Len() > 0 {
+				encodedAttrs = record.Attributes().Encoded(e.config.encoder)
+			}
+
+			var sb strings.Builder
+
+			sb.WriteString(desc.Name())
+
+			if len(encodedAttrs) > 0 || len(encodedResource) > 0 || len(encodedInstAttrs) > 0 {
+				sb.WriteRune('{')
+				sb.WriteString(encodedResource)
+				if len(encodedInstAttrs) > 0 && len(encodedResource) > 0 {
+					sb.WriteRune(',')
+				}
+				sb.WriteString(encodedInstAttrs)
+				if len(encodedAttrs) > 0 && (len(encodedInstAttrs) > 0 || len(encodedResource) > 0) {
+					sb.WriteRune(',')
+				}
+				sb.WriteString(encodedAttrs)
+				sb.WriteRune('}')
+			}
+
+			expose.Name = sb.String()
+
+			batch = append(batch, expose)
+			return nil
+		})
+	})
+	if len(batch) == 0 {
+		return aggError
+	}
+
+	data, err := e.marshal(batch)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(e.config.writer, string(data))
+
+	return aggError
+}
+
+// marshal v with appropriate indentation.
+func (e *StdoutExponentialMetricExporter) marshal(v interface{}) ([]byte, error) {
+	if e.config.prettyPrint {
+		return json.MarshalIndent(v, "", "\t")
+	}
+	return json.Marshal(v)
+}
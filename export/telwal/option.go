@@ -0,0 +1,123 @@
+package telwal
+
+import "time"
+
+// SyncPolicy controls when writes to the log are flushed to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncPeriodic flushes the log at the interval set with
+	// WithSyncInterval. A crash may lose the batches written since the
+	// last flush. This is the default.
+	SyncPeriodic SyncPolicy = iota
+	// SyncAlways flushes the log after every batch is written and every
+	// time the replay cursor is saved.
+	SyncAlways
+	// SyncNever leaves flushing to the operating system.
+	SyncNever
+)
+
+type config struct {
+	maxSize        int64
+	segmentSize    int64
+	maxAge         time.Duration
+	syncPolicy     SyncPolicy
+	syncInterval   time.Duration
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	exportTimeout  time.Duration
+}
+
+func newConfig(opts []Option) config {
+	cfg := config{
+		maxSize:        256 << 20,
+		syncPolicy:     SyncPeriodic,
+		syncInterval:   time.Second,
+		initialBackoff: time.Second,
+		maxBackoff:     time.Minute,
+		exportTimeout:  30 * time.Second,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.segmentSize <= 0 || cfg.segmentSize > cfg.maxSize {
+		cfg.segmentSize = cfg.maxSize / 16
+	}
+	if cfg.syncInterval <= 0 {
+		cfg.syncInterval = time.Second
+	}
+	if cfg.initialBackoff <= 0 {
+		cfg.initialBackoff = time.Second
+	}
+	if cfg.maxBackoff < cfg.initialBackoff {
+		cfg.maxBackoff = cfg.initialBackoff
+	}
+	return cfg
+}
+
+// Option configures the write-ahead log of an exporter.
+type Option func(*config)
+
+// WithMaxSize sets the maximum size of the log on disk, in bytes. When a
+// new batch does not fit, the oldest segments are evicted, even if they
+// were not exported yet. If unset, 256 MiB is used.
+func WithMaxSize(bytes int64) Option {
+	return func(cfg *config) {
+		if bytes > 0 {
+			cfg.maxSize = bytes
+		}
+	}
+}
+
+// WithSegmentSize sets the size at which the log rolls over to a new
+// segment file. Eviction happens a segment at a time. If unset, a sixteenth
+// of the maximum size is used.
+func WithSegmentSize(bytes int64) Option {
+	return func(cfg *config) {
+		cfg.segmentSize = bytes
+	}
+}
+
+// WithMaxAge sets how old a batch can get before it is dropped instead of
+// exported. If unset, or zero, batches are kept until evicted by size.
+func WithMaxAge(age time.Duration) Option {
+	return func(cfg *config) {
+		cfg.maxAge = age
+	}
+}
+
+// WithSyncPolicy sets when writes to the log are flushed to stable storage.
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(cfg *config) {
+		cfg.syncPolicy = policy
+	}
+}
+
+// WithSyncInterval sets the flush interval of the SyncPeriodic policy and
+// selects it. If unset, one second is used.
+func WithSyncInterval(interval time.Duration) Option {
+	return func(cfg *config) {
+		cfg.syncPolicy = SyncPeriodic
+		cfg.syncInterval = interval
+	}
+}
+
+// WithBackoff sets the delay before retrying a failed export. The delay
+// doubles after each consecutive failure, up to max. If unset, the delay
+// starts at one second and is capped at one minute.
+func WithBackoff(initial, max time.Duration) Option {
+	return func(cfg *config) {
+		cfg.initialBackoff = initial
+		cfg.maxBackoff = max
+	}
+}
+
+// WithExportTimeout sets the maximum duration of a call to the wrapped
+// exporter. If unset, 30 seconds is used.
+func WithExportTimeout(timeout time.Duration) Option {
+	return func(cfg *config) {
+		if timeout > 0 {
+			cfg.exportTimeout = timeout
+		}
+	}
+}
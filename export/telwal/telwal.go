@@ -0,0 +1,166 @@
+package telwal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/henvic/tel/internal/metricdata"
+	"github.com/henvic/tel/internal/otlpconv"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+// SpanExporter writes batches of spans to a write-ahead log on disk, and
+// replays them to the wrapped exporter in the background, retrying with
+// backoff while it fails.
+//
+// Batches are kept in dir across restarts, so spans exported while the
+// destination is unavailable are delivered once it recovers, even if the
+// process crashed in the meantime. Delivery is at-least-once.
+type SpanExporter struct {
+	exporter telsdk.SpanExporter
+	wal      *wal
+}
+
+// NewSpanExporter returns a SpanExporter persisting batches to dir before
+// exporting them with exporter. Batches left in dir by a previous process
+// are exported first.
+//
+// dir must not be shared with another exporter.
+func NewSpanExporter(exporter telsdk.SpanExporter, dir string, opts ...Option) (*SpanExporter, error) {
+	e := &SpanExporter{exporter: exporter}
+	w, err := openWAL(dir, newConfig(opts), e.replay)
+	if err != nil {
+		return nil, err
+	}
+	e.wal = w
+	return e, nil
+}
+
+// ExportSpans writes spans to the log. It returns once the batch is
+// written, before it is exported.
+func (e *SpanExporter) ExportSpans(ctx context.Context, spans []telsdk.ReadOnlySpan) error {
+	if len(spans) == 0 {
+		return nil
+	}
+	b, err := proto.Marshal(&tracepb.TracesData{ResourceSpans: otlpconv.Spans(spans)})
+	if err != nil {
+		return err
+	}
+	return e.wal.append(b)
+}
+
+func (e *SpanExporter) replay(ctx context.Context, payload []byte) error {
+	var td tracepb.TracesData
+	if err := proto.Unmarshal(payload, &td); err != nil {
+		return fmt.Errorf("%w: %v", errCorrupt, err)
+	}
+	return e.exporter.ExportSpans(ctx, otlpconv.SpanStubs(td.ResourceSpans).Snapshots())
+}
+
+// Shutdown exports the batches left in the log until it is empty or ctx is
+// done, and then shuts down the wrapped exporter. Batches that could not
+// be exported remain in the log for the next process. Calls after the
+// first return ErrClosed.
+func (e *SpanExporter) Shutdown(ctx context.Context) error {
+	err := e.wal.close(ctx)
+	if errors.Is(err, ErrClosed) {
+		return err
+	}
+	if serr := e.exporter.Shutdown(ctx); err == nil {
+		err = serr
+	}
+	return err
+}
+
+// MetricExporter writes checkpoints to a write-ahead log on disk, and
+// replays them to the wrapped exporter in the background, retrying with
+// backoff while it fails.
+//
+// Checkpoints are kept in dir across restarts, and delivered at-least-once.
+// Each checkpoint keeps the temporality selected by the wrapped exporter
+// when it was written.
+type MetricExporter struct {
+	exporter telsdk.Exporter
+	wal      *wal
+}
+
+// NewMetricExporter returns a MetricExporter persisting checkpoints to dir
+// before exporting them with exporter. Checkpoints left in dir by a
+// previous process are exported first.
+//
+// dir must not be shared with another exporter.
+func NewMetricExporter(exporter telsdk.Exporter, dir string, opts ...Option) (*MetricExporter, error) {
+	e := &MetricExporter{exporter: exporter}
+	w, err := openWAL(dir, newConfig(opts), e.replay)
+	if err != nil {
+		return nil, err
+	}
+	e.wal = w
+	return e, nil
+}
+
+// Export writes the checkpoint to the log. It returns once the checkpoint
+// is written, before it is exported.
+func (e *MetricExporter) Export(ctx context.Context, res *telsdk.Resource, reader telsdk.InstrumentationLibraryReader) error {
+	rm, err := otlpconv.ResourceMetrics(e.exporter, res, reader)
+	if rm == nil {
+		return err
+	}
+	b, merr := proto.Marshal(&metricpb.MetricsData{ResourceMetrics: []*metricpb.ResourceMetrics{rm}})
+	if merr != nil {
+		return merr
+	}
+	if werr := e.wal.append(b); werr != nil {
+		return werr
+	}
+	return err
+}
+
+// TemporalityFor returns the temporality selected by the wrapped exporter.
+func (e *MetricExporter) TemporalityFor(desc *sdkapi.Descriptor, kind aggregation.Kind) aggregation.Temporality {
+	return e.exporter.TemporalityFor(desc, kind)
+}
+
+func (e *MetricExporter) replay(ctx context.Context, payload []byte) error {
+	var md metricpb.MetricsData
+	if err := proto.Unmarshal(payload, &md); err != nil {
+		return fmt.Errorf("%w: %v", errCorrupt, err)
+	}
+	for _, rm := range md.ResourceMetrics {
+		res, libs, err := otlpconv.Metrics(rm)
+		if err != nil {
+			return fmt.Errorf("%w: %v", errCorrupt, err)
+		}
+		if err := e.exporter.Export(ctx, res, metricdata.NewInstrumentationLibraryReader(libs...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown exports the checkpoints left in the log until it is empty or ctx
+// is done, and then shuts down the wrapped exporter if it supports it.
+// Checkpoints that could not be exported remain in the log for the next
+// process.
+//
+// Calls after the first return ErrClosed.
+//
+// Stop the controller using the exporter before calling Shutdown.
+func (e *MetricExporter) Shutdown(ctx context.Context) error {
+	err := e.wal.close(ctx)
+	if errors.Is(err, ErrClosed) {
+		return err
+	}
+	if s, ok := e.exporter.(interface{ Shutdown(context.Context) error }); ok {
+		if serr := s.Shutdown(ctx); err == nil {
+			err = serr
+		}
+	}
+	return err
+}
@@ -0,0 +1,318 @@
+package telwal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// flakyExporter fails the first failures calls to ExportSpans.
+type flakyExporter struct {
+	mu        sync.Mutex
+	failures  int
+	names     []string
+	shutdowns int
+}
+
+func (e *flakyExporter) ExportSpans(ctx context.Context, spans []telsdk.ReadOnlySpan) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failures > 0 {
+		e.failures--
+		return errors.New("destination unavailable")
+	}
+	for _, s := range spans {
+		e.names = append(e.names, s.Name())
+	}
+	return nil
+}
+
+func (e *flakyExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdowns++
+	return nil
+}
+
+func (e *flakyExporter) exported() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.names...)
+}
+
+func (e *flakyExporter) waitFor(t *testing.T, n int) []string {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		names := e.exported()
+		if len(names) >= n {
+			return names
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("exported %d spans, want %d", len(names), n)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func spans(names ...string) []telsdk.ReadOnlySpan {
+	stubs := make(tracetest.SpanStubs, len(names))
+	for i, name := range names {
+		stubs[i] = tracetest.SpanStub{
+			Name: name,
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID: trace.TraceID{1},
+				SpanID:  trace.SpanID{byte(i + 1)},
+			}),
+		}
+	}
+	return stubs.Snapshots()
+}
+
+var testOptions = []Option{
+	WithBackoff(time.Millisecond, 10*time.Millisecond),
+	WithSyncPolicy(SyncAlways),
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func TestSpanExporterRetries(t *testing.T) {
+	exp := &flakyExporter{failures: 3}
+	e, err := NewSpanExporter(exp, t.TempDir(), testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExportSpans(context.Background(), spans("a")); err != nil {
+		t.Fatal(err)
+	}
+	if got := exp.waitFor(t, 1); !equal(got, []string{"a"}) {
+		t.Errorf("exported %v, want [a]", got)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+}
+
+func TestSpanExporterReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	down := &flakyExporter{failures: 1 << 30}
+	e, err := NewSpanExporter(down, dir, testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := e.ExportSpans(context.Background(), spans(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := e.Shutdown(ctx); err == nil {
+		t.Error("Shutdown() = nil, want an error for the unexported batches")
+	}
+
+	up := &flakyExporter{}
+	e, err = NewSpanExporter(up, dir, testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := up.waitFor(t, 2); !equal(got, []string{"a", "b"}) {
+		t.Errorf("replayed %v, want [a b]", got)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+}
+
+func TestSpanExporterCursorCommit(t *testing.T) {
+	dir := t.TempDir()
+	first := &flakyExporter{}
+	e, err := NewSpanExporter(first, dir, testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExportSpans(context.Background(), spans("a", "b")); err != nil {
+		t.Fatal(err)
+	}
+	first.waitFor(t, 2)
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatalf("Shutdown() = %v", err)
+	}
+
+	second := &flakyExporter{}
+	e, err = NewSpanExporter(second, dir, testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExportSpans(context.Background(), spans("c")); err != nil {
+		t.Fatal(err)
+	}
+	if got := second.waitFor(t, 1); !equal(got, []string{"c"}) {
+		t.Errorf("exported %v after restart, want [c]", got)
+	}
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	if got := second.exported(); !equal(got, []string{"c"}) {
+		t.Errorf("exported %v after restart, want [c]", got)
+	}
+}
+
+func TestSpanExporterDoubleShutdown(t *testing.T) {
+	exp := &flakyExporter{}
+	e, err := NewSpanExporter(exp, t.TempDir(), testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExportSpans(context.Background(), spans("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	errs := make(chan error, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			errs <- e.Shutdown(context.Background())
+		}()
+	}
+	var closed int
+	for i := 0; i < 2; i++ {
+		if err := <-errs; errors.Is(err, ErrClosed) {
+			closed++
+		} else if err != nil {
+			t.Errorf("Shutdown() = %v", err)
+		}
+	}
+	if closed != 1 {
+		t.Errorf("%d calls to Shutdown returned ErrClosed, want 1", closed)
+	}
+	exp.mu.Lock()
+	if exp.shutdowns != 1 {
+		t.Errorf("the wrapped exporter was shut down %d times, want 1", exp.shutdowns)
+	}
+	exp.mu.Unlock()
+	if err := e.ExportSpans(context.Background(), spans("b")); !errors.Is(err, ErrClosed) {
+		t.Errorf("ExportSpans() after Shutdown = %v, want ErrClosed", err)
+	}
+}
+
+// metricExporter fails the first failures calls to Export.
+type metricExporter struct {
+	aggregation.TemporalitySelector
+
+	mu        sync.Mutex
+	failures  int
+	points    []string
+	shutdowns int
+}
+
+func (e *metricExporter) Export(ctx context.Context, res *telsdk.Resource, reader telsdk.InstrumentationLibraryReader) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.failures > 0 {
+		e.failures--
+		return errors.New("destination unavailable")
+	}
+	service, _ := res.Set().Value("service.name")
+	return reader.ForEach(func(lib instrumentation.Library, r export.Reader) error {
+		return r.ForEach(e, func(rec export.Record) error {
+			s, ok := rec.Aggregation().(aggregation.Sum)
+			if !ok {
+				return fmt.Errorf("got %s aggregation, want a sum", rec.Aggregation().Kind())
+			}
+			n, err := s.Sum()
+			if err != nil {
+				return err
+			}
+			route, _ := rec.Attributes().Value("route")
+			e.points = append(e.points, fmt.Sprintf("%s %s %s route=%s %s", service.Emit(), lib.Name,
+				rec.Descriptor().Name(), route.Emit(), n.Emit(rec.Descriptor().NumberKind())))
+			return nil
+		})
+	})
+}
+
+func (e *metricExporter) Shutdown(ctx context.Context) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.shutdowns++
+	return nil
+}
+
+func (e *metricExporter) exported() []string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return append([]string(nil), e.points...)
+}
+
+func TestMetricExporterReplayAfterRestart(t *testing.T) {
+	dir := t.TempDir()
+	down := &metricExporter{TemporalitySelector: aggregation.CumulativeTemporalitySelector(), failures: 1 << 30}
+	e, err := NewMetricExporter(down, dir, testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctrl := telsdk.NewBasicController(
+		telsdk.NewFactory(telsdk.NewWithInexpensiveDistribution(), e),
+		telsdk.WithBasicControllerResource(telsdk.NewSchemaless(tel.AttributeString("service.name", "svc"))),
+		telsdk.WithBasicControllerCollectPeriod(0),
+	)
+	counter, err := ctrl.Meter("scope").SyncInt64().Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	for _, n := range []int64{3, 4} {
+		counter.Add(ctx, n, tel.AttributeString("route", "/"))
+		if err := ctrl.Collect(ctx); err != nil {
+			t.Fatal(err)
+		}
+		if err := e.Export(ctx, ctrl.Resource(), ctrl); err != nil {
+			t.Fatal(err)
+		}
+	}
+	sctx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	if err := e.Shutdown(sctx); err == nil {
+		t.Error("Shutdown() = nil, want an error for the unexported checkpoints")
+	}
+
+	up := &metricExporter{TemporalitySelector: aggregation.CumulativeTemporalitySelector()}
+	e, err = NewMetricExporter(up, dir, testOptions...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.Shutdown(ctx); err != nil {
+		t.Errorf("Shutdown() = %v", err)
+	}
+	want := []string{"svc scope requests route=/ 3", "svc scope requests route=/ 7"}
+	if got := up.exported(); !equal(got, want) {
+		t.Errorf("replayed %v, want %v", got, want)
+	}
+	if err := e.Shutdown(ctx); !errors.Is(err, ErrClosed) {
+		t.Errorf("second Shutdown() = %v, want ErrClosed", err)
+	}
+	if up.shutdowns != 1 {
+		t.Errorf("the wrapped exporter was shut down %d times, want 1", up.shutdowns)
+	}
+}
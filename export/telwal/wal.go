@@ -0,0 +1,630 @@
+package telwal
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/henvic/tel"
+)
+
+const (
+	segmentExt = ".wal"
+	cursorName = "cursor"
+
+	// headerSize is the size of the record header: payload length (4
+	// bytes), CRC-32C of the timestamp and payload (4 bytes), and the
+	// write time in Unix nanoseconds (8 bytes).
+	headerSize = 16
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+var (
+	// ErrBatchTooLarge is returned when a single encoded batch is larger
+	// than the maximum size of the log.
+	ErrBatchTooLarge = errors.New("telwal: batch exceeds the maximum log size")
+
+	// ErrClosed is returned when exporting to a log that was shut down.
+	ErrClosed = errors.New("telwal: log is closed")
+
+	errCorrupt = errors.New("telwal: corrupt record")
+)
+
+// segment is a log file. Records are appended only to the last segment.
+type segment struct {
+	seq     uint64
+	size    int64
+	modTime time.Time
+}
+
+// position identifies the next record to be read.
+type position struct {
+	seq    uint64
+	offset int64
+}
+
+type entry struct {
+	payload []byte
+	time    time.Time
+	next    position
+}
+
+type exportFunc func(ctx context.Context, payload []byte) error
+
+// wal is a bounded, segmented write-ahead log. Batches are appended by the
+// exporter and replayed in order by a background goroutine. A batch is only
+// removed from the log once it was exported, so delivery is at-least-once:
+// a crash between a successful export and the cursor being saved replays
+// the batch on the next start.
+type wal struct {
+	dir    string
+	cfg    config
+	export exportFunc
+
+	mu       sync.Mutex
+	segments []segment // oldest first; the last one is the active segment.
+	size     int64
+	active   *os.File
+	reader   *os.File
+	readSeq  uint64
+	cursor   position
+	dirty    bool
+	closing  bool // set once close is called.
+	closed   bool
+
+	// ctx is canceled to abort an export by the replay when close gives up
+	// waiting for it.
+	ctx    context.Context
+	cancel context.CancelFunc
+	notify chan struct{}
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+func openWAL(dir string, cfg config, export exportFunc) (*wal, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	w := &wal{
+		dir:    dir,
+		cfg:    cfg,
+		export: export,
+		ctx:    ctx,
+		cancel: cancel,
+		notify: make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+	if err := w.recover(); err != nil {
+		cancel()
+		return nil, err
+	}
+	go w.run()
+	if cfg.syncPolicy == SyncPeriodic {
+		go w.syncLoop()
+	}
+	return w, nil
+}
+
+// recover loads the segments and cursor left by a previous process,
+// discarding a record torn by a crash at the end of the active segment.
+func (w *wal) recover() error {
+	des, err := os.ReadDir(w.dir)
+	if err != nil {
+		return err
+	}
+	for _, de := range des {
+		name := de.Name()
+		if de.IsDir() || filepath.Ext(name) != segmentExt {
+			continue
+		}
+		seq, err := strconv.ParseUint(strings.TrimSuffix(name, segmentExt), 10, 64)
+		if err != nil {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			return err
+		}
+		w.segments = append(w.segments, segment{seq: seq, size: info.Size(), modTime: info.ModTime()})
+	}
+	sort.Slice(w.segments, func(i, j int) bool {
+		return w.segments[i].seq < w.segments[j].seq
+	})
+
+	w.cursor = w.loadCursor()
+	for len(w.segments) > 0 && w.segments[0].seq < w.cursor.seq {
+		if err := w.removeSegment(w.segments[0].seq); err != nil {
+			return err
+		}
+		w.segments = w.segments[1:]
+	}
+
+	if len(w.segments) == 0 {
+		return w.createSegment(w.cursor.seq + 1)
+	}
+	if w.cursor.seq < w.segments[0].seq {
+		w.cursor = position{seq: w.segments[0].seq}
+	}
+
+	last := &w.segments[len(w.segments)-1]
+	valid, err := w.validLength(last.seq)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.segmentPath(last.seq), os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if valid < last.size {
+		tel.Handle(fmt.Errorf("telwal: discarding %d bytes of a torn write in %s", last.size-valid, w.segmentPath(last.seq)))
+		if err := f.Truncate(valid); err != nil {
+			f.Close()
+			return err
+		}
+		last.size = valid
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return err
+	}
+	w.active = f
+	for _, s := range w.segments {
+		w.size += s.size
+	}
+	if w.cursor.seq == last.seq && w.cursor.offset > last.size {
+		w.cursor.offset = last.size
+	}
+	return nil
+}
+
+// validLength returns the length of the longest prefix of a segment made of
+// intact records.
+func (w *wal) validLength(seq uint64) (int64, error) {
+	f, err := os.Open(w.segmentPath(seq))
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	var off int64
+	for {
+		_, n, err := readRecord(f, off, w.cfg.maxSize)
+		if err != nil {
+			return off, nil
+		}
+		off += n
+	}
+}
+
+func (w *wal) segmentPath(seq uint64) string {
+	return filepath.Join(w.dir, fmt.Sprintf("%020d%s", seq, segmentExt))
+}
+
+func (w *wal) createSegment(seq uint64) error {
+	f, err := os.OpenFile(w.segmentPath(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	w.active = f
+	w.segments = append(w.segments, segment{seq: seq, modTime: time.Now()})
+	if w.cursor.seq < seq && (len(w.segments) == 1) {
+		w.cursor = position{seq: seq}
+	}
+	return nil
+}
+
+func (w *wal) removeSegment(seq uint64) error {
+	if w.reader != nil && w.readSeq == seq {
+		w.reader.Close()
+		w.reader = nil
+	}
+	if err := os.Remove(w.segmentPath(seq)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// dropOldest removes the oldest segment, which must not be the active one.
+func (w *wal) dropOldest() error {
+	s := w.segments[0]
+	if err := w.removeSegment(s.seq); err != nil {
+		return err
+	}
+	w.segments = w.segments[1:]
+	w.size -= s.size
+	if w.cursor.seq <= s.seq {
+		w.cursor = position{seq: w.segments[0].seq}
+	}
+	return nil
+}
+
+func (w *wal) rotate() error {
+	if err := w.active.Sync(); err != nil {
+		return err
+	}
+	if err := w.active.Close(); err != nil {
+		return err
+	}
+	w.dirty = false
+	return w.createSegment(w.segments[len(w.segments)-1].seq + 1)
+}
+
+// evict makes room for n more bytes, removing the oldest segments even if
+// they were not exported yet, as well as segments older than the maximum age.
+func (w *wal) evict(n int64, now time.Time) error {
+	for len(w.segments) > 1 && w.cfg.maxAge > 0 && now.Sub(w.segments[0].modTime) > w.cfg.maxAge {
+		unread := w.unread(w.segments[0])
+		if err := w.dropOldest(); err != nil {
+			return err
+		}
+		if unread > 0 {
+			tel.Handle(fmt.Errorf("telwal: dropped %d bytes of unexported batches older than %v", unread, w.cfg.maxAge))
+		}
+	}
+	for w.size+n > w.cfg.maxSize {
+		if len(w.segments) == 1 {
+			if err := w.rotate(); err != nil {
+				return err
+			}
+		}
+		unread := w.unread(w.segments[0])
+		if err := w.dropOldest(); err != nil {
+			return err
+		}
+		if unread > 0 {
+			tel.Handle(fmt.Errorf("telwal: log is full: dropped %d bytes of unexported batches", unread))
+		}
+	}
+	return nil
+}
+
+// unread returns how many bytes of s were not exported yet.
+func (w *wal) unread(s segment) int64 {
+	switch {
+	case s.seq < w.cursor.seq:
+		return 0
+	case s.seq == w.cursor.seq:
+		return s.size - w.cursor.offset
+	}
+	return s.size
+}
+
+func (w *wal) append(payload []byte) error {
+	n := int64(headerSize + len(payload))
+	if n > w.cfg.maxSize {
+		return ErrBatchTooLarge
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+
+	now := time.Now()
+	if err := w.evict(n, now); err != nil {
+		return err
+	}
+	if last := w.segments[len(w.segments)-1]; last.size > 0 && last.size+n > w.cfg.segmentSize {
+		if err := w.rotate(); err != nil {
+			return err
+		}
+	}
+
+	buf := make([]byte, n)
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(now.UnixNano()))
+	copy(buf[headerSize:], payload)
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(buf[8:], crcTable))
+
+	last := &w.segments[len(w.segments)-1]
+	if _, err := w.active.Write(buf); err != nil {
+		// Do not leave a partial record behind for the reader.
+		_ = w.active.Truncate(last.size)
+		_, _ = w.active.Seek(last.size, io.SeekStart)
+		return err
+	}
+	last.size += n
+	last.modTime = now
+	w.size += n
+
+	if w.cfg.syncPolicy == SyncAlways {
+		if err := w.active.Sync(); err != nil {
+			return err
+		}
+	} else {
+		w.dirty = true
+	}
+
+	select {
+	case w.notify <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// next returns the oldest batch not yet exported.
+func (w *wal) next(now time.Time) (entry, bool, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return entry{}, false, ErrClosed
+	}
+
+	for {
+		i := sort.Search(len(w.segments), func(i int) bool {
+			return w.segments[i].seq >= w.cursor.seq
+		})
+		if i == len(w.segments) {
+			return entry{}, false, nil
+		}
+		s := w.segments[i]
+		if w.cursor.seq != s.seq {
+			w.cursor = position{seq: s.seq}
+		}
+
+		if w.cursor.offset >= s.size {
+			if i == len(w.segments)-1 {
+				return entry{}, false, nil
+			}
+			// The segment was fully exported.
+			for len(w.segments) > 0 && w.segments[0].seq <= s.seq {
+				if err := w.dropOldest(); err != nil {
+					return entry{}, false, err
+				}
+			}
+			if err := w.saveCursor(); err != nil {
+				return entry{}, false, err
+			}
+			continue
+		}
+
+		f, err := w.segmentReader(s.seq)
+		if err != nil {
+			return entry{}, false, err
+		}
+		e, n, err := readRecord(f, w.cursor.offset, w.cfg.maxSize)
+		if err != nil {
+			tel.Handle(fmt.Errorf("telwal: skipping %d bytes of %s: %w", s.size-w.cursor.offset, w.segmentPath(s.seq), err))
+			w.cursor.offset = s.size
+			continue
+		}
+		e.next = position{seq: s.seq, offset: w.cursor.offset + n}
+		if w.cfg.maxAge > 0 && now.Sub(e.time) > w.cfg.maxAge {
+			tel.Handle(fmt.Errorf("telwal: dropped a batch older than %v", w.cfg.maxAge))
+			w.cursor = e.next
+			continue
+		}
+		return e, true, nil
+	}
+}
+
+func (w *wal) segmentReader(seq uint64) (*os.File, error) {
+	if w.reader != nil && w.readSeq == seq {
+		return w.reader, nil
+	}
+	if w.reader != nil {
+		w.reader.Close()
+	}
+	f, err := os.Open(w.segmentPath(seq))
+	if err != nil {
+		return nil, err
+	}
+	w.reader, w.readSeq = f, seq
+	return f, nil
+}
+
+func readRecord(r io.ReaderAt, off, limit int64) (entry, int64, error) {
+	var header [headerSize]byte
+	if _, err := r.ReadAt(header[:], off); err != nil {
+		return entry{}, 0, err
+	}
+	length := binary.LittleEndian.Uint32(header[0:4])
+	if headerSize+int64(length) > limit {
+		return entry{}, 0, errCorrupt
+	}
+	buf := make([]byte, 8+int(length))
+	copy(buf, header[8:])
+	if _, err := r.ReadAt(buf[8:], off+headerSize); err != nil {
+		return entry{}, 0, err
+	}
+	if crc32.Checksum(buf, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return entry{}, 0, errCorrupt
+	}
+	return entry{
+		payload: buf[8:],
+		time:    time.Unix(0, int64(binary.LittleEndian.Uint64(header[8:16]))),
+	}, headerSize + int64(length), nil
+}
+
+// commit marks every batch before pos as exported.
+func (w *wal) commit(pos position) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return ErrClosed
+	}
+	w.cursor = pos
+	return w.saveCursor()
+}
+
+func (w *wal) loadCursor() position {
+	b, err := os.ReadFile(filepath.Join(w.dir, cursorName))
+	if err != nil || len(b) != 16 {
+		return position{}
+	}
+	return position{
+		seq:    binary.LittleEndian.Uint64(b[0:8]),
+		offset: int64(binary.LittleEndian.Uint64(b[8:16])),
+	}
+}
+
+// saveCursor atomically replaces the cursor file.
+func (w *wal) saveCursor() error {
+	var b [16]byte
+	binary.LittleEndian.PutUint64(b[0:8], w.cursor.seq)
+	binary.LittleEndian.PutUint64(b[8:16], uint64(w.cursor.offset))
+
+	tmp := filepath.Join(w.dir, cursorName+".tmp")
+	f, err := os.OpenFile(tmp, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(b[:]); err != nil {
+		f.Close()
+		return err
+	}
+	if w.cfg.syncPolicy == SyncAlways {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(w.dir, cursorName))
+}
+
+func (w *wal) syncLoop() {
+	ticker := time.NewTicker(w.cfg.syncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stop:
+			return
+		case <-ticker.C:
+			w.mu.Lock()
+			if w.dirty && !w.closed {
+				if err := w.active.Sync(); err != nil {
+					tel.Handle(err)
+				}
+				w.dirty = false
+			}
+			w.mu.Unlock()
+		}
+	}
+}
+
+// run replays the log to the exporter until the log is closed, backing off
+// while the exporter fails.
+func (w *wal) run() {
+	defer close(w.done)
+	var backoff time.Duration
+	for {
+		if backoff > 0 {
+			select {
+			case <-w.stop:
+				return
+			case <-time.After(backoff):
+			}
+		}
+
+		exported, err := w.exportNext(w.ctx)
+		switch {
+		case err != nil:
+			tel.Handle(err)
+			backoff = w.nextBackoff(backoff)
+		case exported:
+			backoff = 0
+		default:
+			backoff = 0
+			select {
+			case <-w.stop:
+				return
+			case <-w.notify:
+			}
+		}
+	}
+}
+
+func (w *wal) nextBackoff(d time.Duration) time.Duration {
+	if d == 0 {
+		return w.cfg.initialBackoff
+	}
+	if d *= 2; d > w.cfg.maxBackoff {
+		d = w.cfg.maxBackoff
+	}
+	return d
+}
+
+// exportNext exports the oldest batch not yet exported, if any.
+func (w *wal) exportNext(ctx context.Context) (bool, error) {
+	e, ok, err := w.next(time.Now())
+	if err != nil || !ok {
+		return false, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, w.cfg.exportTimeout)
+	defer cancel()
+	if err := w.export(ctx, e.payload); err != nil {
+		if !errors.Is(err, errCorrupt) {
+			return false, err
+		}
+		tel.Handle(err)
+	}
+	return true, w.commit(e.next)
+}
+
+// close stops the replay, then exports what is left in the log until it is
+// empty or ctx is done. Batches that could not be exported are kept on disk
+// for the next process. If ctx is done before the replay stops, its export
+// in progress is canceled.
+func (w *wal) close(ctx context.Context) error {
+	w.mu.Lock()
+	if w.closing {
+		w.mu.Unlock()
+		return ErrClosed
+	}
+	w.closing = true
+	w.mu.Unlock()
+
+	close(w.stop)
+	select {
+	case <-w.done:
+	case <-ctx.Done():
+		w.cancel()
+		<-w.done
+	}
+	defer w.cancel()
+
+	var err error
+	for ctx.Err() == nil {
+		var exported bool
+		if exported, err = w.exportNext(ctx); err != nil || !exported {
+			break
+		}
+	}
+	if err == nil {
+		err = ctx.Err()
+	}
+	if err != nil {
+		err = fmt.Errorf("telwal: unexported batches left in %s: %w", w.dir, err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.reader != nil {
+		w.reader.Close()
+	}
+	if cerr := w.active.Sync(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if cerr := w.active.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	return err
+}
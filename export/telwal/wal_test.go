@@ -0,0 +1,246 @@
+package telwal
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/henvic/tel"
+)
+
+// payloads is an exportFunc keeping the payloads it exports while up.
+type payloads struct {
+	mu   sync.Mutex
+	up   bool
+	list []string
+}
+
+func (p *payloads) export(ctx context.Context, payload []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if !p.up {
+		return errors.New("destination unavailable")
+	}
+	p.list = append(p.list, string(payload))
+	return nil
+}
+
+func (p *payloads) setUp() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.up = true
+}
+
+func (p *payloads) exported() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.list...)
+}
+
+var (
+	handlerOnce sync.Once
+	handlerMu   sync.Mutex
+	handlerFunc func(error)
+)
+
+// handled records the errors reported to tel.Handle. The global handler is
+// set once: the handler returned by tel.GetErrorHandler before it delegates
+// to the one set.
+func handled(t *testing.T) func() []string {
+	handlerOnce.Do(func() {
+		tel.SetErrorHandler(tel.ErrorHandlerFunc(func(err error) {
+			handlerMu.Lock()
+			defer handlerMu.Unlock()
+			if handlerFunc != nil {
+				handlerFunc(err)
+			}
+		}))
+	})
+	var (
+		mu   sync.Mutex
+		msgs []string
+	)
+	handlerMu.Lock()
+	handlerFunc = func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		msgs = append(msgs, err.Error())
+	}
+	handlerMu.Unlock()
+	t.Cleanup(func() {
+		handlerMu.Lock()
+		handlerFunc = nil
+		handlerMu.Unlock()
+	})
+	return func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string(nil), msgs...)
+	}
+}
+
+func contains(msgs []string, substr string) bool {
+	for _, m := range msgs {
+		if strings.Contains(m, substr) {
+			return true
+		}
+	}
+	return false
+}
+
+// record returns a payload of n bytes, and the size of its record.
+func record(name string, n int) (payload []byte, size int64) {
+	return []byte(name + strings.Repeat(".", n-len(name))), headerSize + int64(n)
+}
+
+func TestWALEvictsBySize(t *testing.T) {
+	msgs := handled(t)
+	_, size := record("", 100)
+	p := &payloads{}
+	// Two records per segment, and room for four.
+	w, err := openWAL(t.TempDir(), newConfig([]Option{
+		WithMaxSize(4 * size),
+		WithSegmentSize(2 * size),
+		WithBackoff(time.Hour, time.Hour),
+	}), p.export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"0", "1", "2", "3", "4", "5", "6"} {
+		payload, _ := record(name, 100)
+		if err := w.append(payload); err != nil {
+			t.Fatal(err)
+		}
+		w.mu.Lock()
+		if w.size > w.cfg.maxSize {
+			t.Errorf("log has %d bytes, want at most %d", w.size, w.cfg.maxSize)
+		}
+		w.mu.Unlock()
+	}
+	if !contains(msgs(), "log is full") {
+		t.Errorf("got errors %v, want the dropped batches reported", msgs())
+	}
+
+	p.setUp()
+	if err := w.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range p.exported() {
+		got = append(got, s[:1])
+	}
+	// The oldest segments were evicted a segment at a time.
+	if want := []string{"4", "5", "6"}; !equal(got, want) {
+		t.Errorf("exported %v, want %v", got, want)
+	}
+}
+
+func TestWALEvictsByAge(t *testing.T) {
+	msgs := handled(t)
+	payload, size := record("a", 10)
+	p := &payloads{}
+	// A record per segment.
+	w, err := openWAL(t.TempDir(), newConfig([]Option{
+		WithSegmentSize(size),
+		WithMaxAge(time.Minute),
+		WithBackoff(time.Hour, time.Hour),
+	}), p.export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		if err := w.append(payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w.mu.Lock()
+	err = w.evict(size, time.Now().Add(time.Hour))
+	segments := len(w.segments)
+	w.mu.Unlock()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The active segment is never evicted by age.
+	if segments != 1 {
+		t.Errorf("got %d segments, want only the active one", segments)
+	}
+	if !contains(msgs(), "older than 1m0s") {
+		t.Errorf("got errors %v, want the dropped batches reported", msgs())
+	}
+
+	p.setUp()
+	if err := w.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := p.exported(); len(got) != 1 {
+		t.Errorf("exported %d batches, want the one of the active segment", len(got))
+	}
+}
+
+func TestWALRecoversTornRecord(t *testing.T) {
+	msgs := handled(t)
+	dir := t.TempDir()
+	cfg := newConfig([]Option{WithBackoff(time.Hour, time.Hour)})
+	down := &payloads{}
+	w, err := openWAL(dir, cfg, down.export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"a", "b"} {
+		if err := w.append([]byte(name)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := w.close(ctx); err == nil {
+		t.Fatal("close() = nil, want an error for the unexported batches")
+	}
+
+	// Simulate a crash in the middle of writing a third record.
+	path := w.segmentPath(w.segments[len(w.segments)-1].seq)
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := f.Write([]byte{3, 0, 0, 0, 1, 2}); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	up := &payloads{up: true}
+	w, err = openWAL(dir, cfg, up.export)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(msgs(), "discarding 6 bytes of a torn write") {
+		t.Errorf("got errors %v, want the torn write reported", msgs())
+	}
+	after, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.Size() != info.Size() {
+		t.Errorf("segment is %d bytes after recovery, want it truncated to %d", after.Size(), info.Size())
+	}
+	if err := w.append([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.close(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := up.exported(), []string{"a", "b", "c"}; !equal(got, want) {
+		t.Errorf("exported %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,70 @@
+package telexporter
+
+import (
+	"net"
+	"strconv"
+
+	"go.opentelemetry.io/otel/attribute"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// peerServiceKeys rank the attributes naming the remote service, as in the
+// OpenTelemetry specification for Zipkin.
+var peerServiceKeys = []attribute.Key{
+	semconv.PeerServiceKey,
+	semconv.NetPeerNameKey,
+	"peer.hostname",
+	semconv.HTTPHostKey,
+	semconv.DBNameKey,
+}
+
+// peerIPKeys rank the attributes holding the remote address.
+var peerIPKeys = []attribute.Key{
+	semconv.NetPeerIPKey,
+	"peer.address",
+}
+
+// PeerRemoteEndpoint derives the remote endpoint of all but internal spans
+// from their peer attributes. Unlike the default, which picks a single
+// attribute for client and producer spans, it combines the service name,
+// the IP address and the port when present. This also gives server and
+// consumer spans the endpoint of their caller.
+func PeerRemoteEndpoint(span tracesdk.ReadOnlySpan) *ZipkinEndpoint {
+	if span.SpanKind() == trace.SpanKindInternal || span.SpanKind() == trace.SpanKindUnspecified {
+		return nil
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range span.Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+
+	var ep ZipkinEndpoint
+	for _, k := range peerServiceKeys {
+		if v, ok := attrs[k]; ok && v.Type() == attribute.STRING && v.AsString() != "" {
+			ep.ServiceName = v.AsString()
+			break
+		}
+	}
+	for _, k := range peerIPKeys {
+		ip := net.ParseIP(attrs[k].Emit())
+		if ip == nil {
+			continue
+		}
+		if ip4 := ip.To4(); ip4 != nil {
+			ep.IPv4 = ip4
+		} else {
+			ep.IPv6 = ip
+		}
+		break
+	}
+	if v, ok := attrs[semconv.NetPeerPortKey]; ok {
+		port, _ := strconv.ParseUint(v.Emit(), 10, 16)
+		ep.Port = uint16(port)
+	}
+	if ep.ServiceName == "" && ep.IPv4 == nil && ep.IPv6 == nil && ep.Port == 0 {
+		return nil
+	}
+	return &ep
+}
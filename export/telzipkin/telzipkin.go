@@ -1,37 +1,324 @@
 package telexporter
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
+	"sync"
 
 	zkmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
 	"go.opentelemetry.io/otel/exporters/zipkin"
 	tracesdk "go.opentelemetry.io/otel/sdk/trace"
 )
 
-// ZkipKinSpanModels converts OpenTelemetry spans into Zipkin model spans.
+const (
+	defaultCollectorURL = "http://localhost:9411/api/v2/spans"
+	// envEndpoint is the environment variable read for the collector URL
+	// when New is called without one, as done by the upstream exporter.
+	envEndpoint = "OTEL_EXPORTER_ZIPKIN_ENDPOINT"
+)
+
+// ZipkinSpanModels converts OpenTelemetry spans into Zipkin model spans.
 // This is used for exporting to Zipkin compatible tracing services.
-func ZkipKinSpanModels(batch []tracesdk.ReadOnlySpan) []zkmodel.SpanModel {
+func ZipkinSpanModels(batch []tracesdk.ReadOnlySpan) []zkmodel.SpanModel {
 	return zipkin.SpanModels(batch)
 }
 
-// ZipkinExporter exports spans to the zipkin collector.
-type ZipkinExporter = zipkin.Exporter
+// ZkipKinSpanModels converts OpenTelemetry spans into Zipkin model spans.
+//
+// Deprecated: use ZipkinSpanModels instead.
+func ZkipKinSpanModels(batch []tracesdk.ReadOnlySpan) []zkmodel.SpanModel {
+	return ZipkinSpanModels(batch)
+}
+
+// ZipkinEndpoint is the network context of a node in the service graph.
+type ZipkinEndpoint = zkmodel.Endpoint
+
+// ZipkinEncoding is the encoding used to send spans to the collector.
+type ZipkinEncoding int
+
+const (
+	// ZipkinEncodingJSON sends spans as a JSON list of Zipkin v2 spans.
+	ZipkinEncodingJSON ZipkinEncoding = iota
+	// ZipkinEncodingProto sends spans as a Zipkin v2 protobuf ListOfSpans.
+	ZipkinEncodingProto
+)
+
+// RemoteEndpointFunc returns the remote endpoint of a span, or nil if it
+// has none.
+type RemoteEndpointFunc func(span tracesdk.ReadOnlySpan) *ZipkinEndpoint
+
+type config struct {
+	client         *http.Client
+	logger         *log.Logger
+	localEndpoint  *ZipkinEndpoint
+	remoteEndpoint RemoteEndpointFunc
+	encoding       ZipkinEncoding
+	headers        map[string]string
+	maxBatchSize   int
+}
 
 // ZipkinOption defines a function that configures the exporter.
-type ZipkinOption = zipkin.Option
+type ZipkinOption func(*config)
+
+// WithZipkinLogger configures the exporter to use the passed logger.
+func WithZipkinLogger(logger *log.Logger) ZipkinOption {
+	return func(cfg *config) {
+		cfg.logger = logger
+	}
+}
 
 // WithZkipKinLogger configures the exporter to use the passed logger.
+//
+// Deprecated: use WithZipkinLogger instead.
 func WithZkipKinLogger(logger *log.Logger) ZipkinOption {
-	return zipkin.WithLogger(logger)
+	return WithZipkinLogger(logger)
 }
 
 // WithClient configures the exporter to use the passed HTTP client.
 func WithClient(client *http.Client) ZipkinOption {
-	return zipkin.WithClient(client)
+	return func(cfg *config) {
+		cfg.client = client
+	}
+}
+
+// WithLocalEndpoint overrides the local endpoint of every span. Only the
+// non-zero fields of endpoint are used, so setting just the Port keeps the
+// service name taken from the resource.
+func WithLocalEndpoint(endpoint ZipkinEndpoint) ZipkinOption {
+	return func(cfg *config) {
+		cfg.localEndpoint = &endpoint
+	}
+}
+
+// WithRemoteEndpoint sets the function deriving the remote endpoint of each
+// span. If unset, the remote endpoint of client and producer spans is the
+// highest ranked peer attribute, as in the OpenTelemetry specification for
+// Zipkin. See PeerRemoteEndpoint for an alternative.
+func WithRemoteEndpoint(fn RemoteEndpointFunc) ZipkinOption {
+	return func(cfg *config) {
+		cfg.remoteEndpoint = fn
+	}
+}
+
+// WithEncoding sets the encoding used to send spans. If unset,
+// ZipkinEncodingJSON is used.
+func WithEncoding(encoding ZipkinEncoding) ZipkinOption {
+	return func(cfg *config) {
+		cfg.encoding = encoding
+	}
+}
+
+// WithHeaders sends the headers with every request, e.g. for
+// authentication. The Content-Type header is set by the exporter.
+func WithHeaders(headers map[string]string) ZipkinOption {
+	return func(cfg *config) {
+		cfg.headers = headers
+	}
+}
+
+// WithMaxBatchSize splits the batches received by the exporter into
+// requests of at most n spans. If unset, or zero, each batch is sent as a
+// single request.
+func WithMaxBatchSize(n int) ZipkinOption {
+	return func(cfg *config) {
+		cfg.maxBatchSize = n
+	}
 }
 
-// New creates a new Zipkin exporter.
+// ZipkinExporter exports spans to the zipkin collector.
+//
+// ZipkinExporter used to be an alias of the upstream zipkin.Exporter, which
+// only sends JSON in a single request per batch. It is now implemented by
+// this package to support the protobuf encoding, headers, endpoint
+// overrides and batch splitting; code holding a *zipkin.Exporter should
+// use a tracesdk.SpanExporter instead. Likewise, ZipkinOption is no longer
+// an alias of zipkin.Option: WithZkipKinLogger and WithClient replace the
+// upstream WithLogger and WithClient.
+type ZipkinExporter struct {
+	url string
+	cfg config
+
+	stoppedMu sync.RWMutex
+	stopped   bool
+}
+
+// New creates a new Zipkin exporter. If collectorURL is empty, the
+// OTEL_EXPORTER_ZIPKIN_ENDPOINT environment variable is used, and then
+// http://localhost:9411/api/v2/spans.
 func New(collectorURL string, opts ...ZipkinOption) (*ZipkinExporter, error) {
-	return zipkin.New(collectorURL, opts...)
+	if collectorURL == "" {
+		collectorURL = os.Getenv(envEndpoint)
+	}
+	if collectorURL == "" {
+		collectorURL = defaultCollectorURL
+	}
+	u, err := url.Parse(collectorURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid collector URL %q: %v", collectorURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid collector URL %q: no scheme or host", collectorURL)
+	}
+
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.client == nil {
+		cfg.client = http.DefaultClient
+	}
+	switch cfg.encoding {
+	case ZipkinEncodingJSON, ZipkinEncodingProto:
+	default:
+		return nil, fmt.Errorf("unknown Zipkin encoding %d", cfg.encoding)
+	}
+	return &ZipkinExporter{url: collectorURL, cfg: cfg}, nil
+}
+
+// ExportSpans exports spans to a Zipkin receiver.
+func (e *ZipkinExporter) ExportSpans(ctx context.Context, spans []tracesdk.ReadOnlySpan) error {
+	e.stoppedMu.RLock()
+	stopped := e.stopped
+	e.stoppedMu.RUnlock()
+	if stopped {
+		e.logf("exporter stopped, not exporting span batch")
+		return nil
+	}
+	if len(spans) == 0 {
+		e.logf("no spans to export")
+		return nil
+	}
+
+	models := e.spanModels(spans)
+	size := e.cfg.maxBatchSize
+	if size <= 0 {
+		size = len(models)
+	}
+	for len(models) > 0 {
+		n := size
+		if n > len(models) {
+			n = len(models)
+		}
+		if err := e.send(ctx, models[:n]); err != nil {
+			return err
+		}
+		models = models[n:]
+	}
+	return nil
+}
+
+func (e *ZipkinExporter) spanModels(spans []tracesdk.ReadOnlySpan) []*zkmodel.SpanModel {
+	models := ZipkinSpanModels(spans)
+	ptrs := make([]*zkmodel.SpanModel, len(models))
+	for i := range models {
+		m := &models[i]
+		if e.cfg.localEndpoint != nil {
+			m.LocalEndpoint = overrideEndpoint(m.LocalEndpoint, e.cfg.localEndpoint)
+		}
+		if e.cfg.remoteEndpoint != nil {
+			m.RemoteEndpoint = e.cfg.remoteEndpoint(spans[i])
+		}
+		ptrs[i] = m
+	}
+	return ptrs
+}
+
+// overrideEndpoint returns a copy of ep with the non-zero fields of
+// override set.
+func overrideEndpoint(ep, override *ZipkinEndpoint) *ZipkinEndpoint {
+	var o ZipkinEndpoint
+	if ep != nil {
+		o = *ep
+	}
+	if override.ServiceName != "" {
+		o.ServiceName = override.ServiceName
+	}
+	if override.IPv4 != nil {
+		o.IPv4 = override.IPv4
+	}
+	if override.IPv6 != nil {
+		o.IPv6 = override.IPv6
+	}
+	if override.Port != 0 {
+		o.Port = override.Port
+	}
+	return &o
+}
+
+func (e *ZipkinExporter) encode(models []*zkmodel.SpanModel) (body []byte, contentType string, err error) {
+	if e.cfg.encoding == ZipkinEncodingProto {
+		var s zipkin_proto3.SpanSerializer
+		body, err = s.Serialize(models)
+		return body, s.ContentType(), err
+	}
+	body, err = json.Marshal(models)
+	return body, "application/json", err
+}
+
+func (e *ZipkinExporter) send(ctx context.Context, models []*zkmodel.SpanModel) error {
+	body, contentType, err := e.encode(models)
+	if err != nil {
+		return e.errf("failed to serialize zipkin models: %v", err)
+	}
+	e.logf("about to send a POST request to %s with %d span(s)", e.url, len(models))
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.url, bytes.NewReader(body))
+	if err != nil {
+		return e.errf("failed to create request to %s: %v", e.url, err)
+	}
+	for k, v := range e.cfg.headers {
+		req.Header.Set(k, v)
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := e.cfg.client.Do(req)
+	if err != nil {
+		return e.errf("request to %s failed: %v", e.url, err)
+	}
+	defer resp.Body.Close()
+
+	// Read the body so the connection can be reused.
+	if _, err := io.Copy(io.Discard, resp.Body); err != nil {
+		return e.errf("failed to read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusAccepted {
+		return e.errf("failed to send spans to zipkin server with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// Shutdown stops the exporter flushing any pending exports.
+func (e *ZipkinExporter) Shutdown(ctx context.Context) error {
+	e.stoppedMu.Lock()
+	e.stopped = true
+	e.stoppedMu.Unlock()
+	return ctx.Err()
+}
+
+func (e *ZipkinExporter) logf(format string, args ...interface{}) {
+	if e.cfg.logger != nil {
+		e.cfg.logger.Printf(format, args...)
+	}
+}
+
+func (e *ZipkinExporter) errf(format string, args ...interface{}) error {
+	e.logf(format, args...)
+	return fmt.Errorf(format, args...)
+}
+
+// MarshalLog is the marshaling function used by the logging system to represent this exporter.
+func (e *ZipkinExporter) MarshalLog() interface{} {
+	return struct {
+		Type string
+		URL  string
+	}{
+		Type: "zipkin",
+		URL:  e.url,
+	}
 }
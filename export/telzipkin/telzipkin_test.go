@@ -0,0 +1,250 @@
+package telexporter
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	zkmodel "github.com/openzipkin/zipkin-go/model"
+	"github.com/openzipkin/zipkin-go/proto/zipkin_proto3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/resource"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// collector is a Zipkin collector keeping the requests it receives.
+type collector struct {
+	t      *testing.T
+	status int
+
+	mu       sync.Mutex
+	requests []collectorRequest
+}
+
+type collectorRequest struct {
+	header http.Header
+	spans  []*zkmodel.SpanModel
+}
+
+func newCollector(t *testing.T, status int) (*collector, *httptest.Server) {
+	c := &collector{t: t, status: status}
+	srv := httptest.NewServer(c)
+	t.Cleanup(srv.Close)
+	return c, srv
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		c.t.Error(err)
+		return
+	}
+	var spans []*zkmodel.SpanModel
+	switch ct := r.Header.Get("Content-Type"); ct {
+	case "application/json":
+		err = json.Unmarshal(body, &spans)
+	case "application/x-protobuf":
+		spans, err = zipkin_proto3.ParseSpans(body, false)
+	default:
+		c.t.Errorf("got Content-Type %q", ct)
+	}
+	if err != nil {
+		c.t.Errorf("cannot decode the spans: %v", err)
+	}
+	c.mu.Lock()
+	c.requests = append(c.requests, collectorRequest{header: r.Header.Clone(), spans: spans})
+	c.mu.Unlock()
+	w.WriteHeader(c.status)
+}
+
+func (c *collector) received() []collectorRequest {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]collectorRequest(nil), c.requests...)
+}
+
+func testSpans(names ...string) []tracesdk.ReadOnlySpan {
+	res := resource.NewSchemaless(semconv.ServiceNameKey.String("svc"))
+	start := time.Date(2022, 5, 1, 0, 0, 0, 0, time.UTC)
+	stubs := make(tracetest.SpanStubs, len(names))
+	for i, name := range names {
+		stubs[i] = tracetest.SpanStub{
+			Name: name,
+			SpanContext: trace.NewSpanContext(trace.SpanContextConfig{
+				TraceID:    trace.TraceID{1},
+				SpanID:     trace.SpanID{byte(i + 1)},
+				TraceFlags: trace.FlagsSampled,
+			}),
+			SpanKind:  trace.SpanKindClient,
+			StartTime: start,
+			EndTime:   start.Add(time.Second),
+			Attributes: []attribute.KeyValue{
+				semconv.PeerServiceKey.String("db"),
+				semconv.NetPeerIPKey.String("10.0.0.1"),
+				semconv.NetPeerPortKey.Int(5432),
+			},
+			Resource: res,
+		}
+	}
+	return stubs.Snapshots()
+}
+
+func TestZipkinExporterEncodings(t *testing.T) {
+	for name, encoding := range map[string]ZipkinEncoding{"json": ZipkinEncodingJSON, "proto": ZipkinEncodingProto} {
+		encoding := encoding
+		t.Run(name, func(t *testing.T) {
+			c, srv := newCollector(t, http.StatusAccepted)
+			e, err := New(srv.URL,
+				WithEncoding(encoding),
+				WithHeaders(map[string]string{"Authorization": "Bearer x", "Content-Type": "text/plain"}),
+				WithLocalEndpoint(ZipkinEndpoint{Port: 8080}),
+				WithRemoteEndpoint(PeerRemoteEndpoint),
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := e.ExportSpans(context.Background(), testSpans("query")); err != nil {
+				t.Fatal(err)
+			}
+
+			reqs := c.received()
+			if len(reqs) != 1 || len(reqs[0].spans) != 1 {
+				t.Fatalf("got %d requests, want a single one with a span", len(reqs))
+			}
+			if got := reqs[0].header.Get("Authorization"); got != "Bearer x" {
+				t.Errorf("got Authorization %q, want the custom header", got)
+			}
+			span := reqs[0].spans[0]
+			if span.Name != "query" || span.Kind != zkmodel.Client || span.Duration != time.Second {
+				t.Errorf("got span %q of kind %s lasting %s", span.Name, span.Kind, span.Duration)
+			}
+			if span.TraceID.Low != 0 || span.TraceID.High != 1<<56 || span.ID != zkmodel.ID(1<<56) {
+				t.Errorf("got trace ID %s and span ID %s", span.TraceID, span.ID)
+			}
+			if ep := span.LocalEndpoint; ep == nil || ep.ServiceName != "svc" || ep.Port != 8080 {
+				t.Errorf("got local endpoint %+v, want svc:8080", ep)
+			}
+			if ep := span.RemoteEndpoint; ep == nil || ep.ServiceName != "db" ||
+				!ep.IPv4.Equal(net.IPv4(10, 0, 0, 1)) || ep.Port != 5432 {
+				t.Errorf("got remote endpoint %+v, want db at 10.0.0.1:5432", ep)
+			}
+		})
+	}
+}
+
+func TestPeerRemoteEndpoint(t *testing.T) {
+	tests := []struct {
+		name  string
+		kind  trace.SpanKind
+		attrs []attribute.KeyValue
+		want  *ZipkinEndpoint
+	}{
+		{
+			name:  "internal",
+			kind:  trace.SpanKindInternal,
+			attrs: []attribute.KeyValue{semconv.PeerServiceKey.String("db")},
+		},
+		{
+			name: "server",
+			kind: trace.SpanKindServer,
+			attrs: []attribute.KeyValue{
+				semconv.NetPeerNameKey.String("caller"),
+				semconv.NetPeerIPKey.String("::1"),
+			},
+			want: &ZipkinEndpoint{ServiceName: "caller", IPv6: net.ParseIP("::1")},
+		},
+		{
+			name: "ranked",
+			kind: trace.SpanKindProducer,
+			attrs: []attribute.KeyValue{
+				semconv.HTTPHostKey.String("host"),
+				semconv.PeerServiceKey.String("queue"),
+			},
+			want: &ZipkinEndpoint{ServiceName: "queue"},
+		},
+		{
+			name:  "no peer",
+			kind:  trace.SpanKindClient,
+			attrs: []attribute.KeyValue{attribute.String("a", "b")},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			span := tracetest.SpanStub{SpanKind: tt.kind, Attributes: tt.attrs}.Snapshot()
+			got := PeerRemoteEndpoint(span)
+			if tt.want == nil {
+				if got != nil {
+					t.Errorf("got %+v, want nil", got)
+				}
+				return
+			}
+			if got == nil || got.ServiceName != tt.want.ServiceName || !got.IPv4.Equal(tt.want.IPv4) ||
+				!got.IPv6.Equal(tt.want.IPv6) || got.Port != tt.want.Port {
+				t.Errorf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestZipkinExporterBatches(t *testing.T) {
+	c, srv := newCollector(t, http.StatusAccepted)
+	e, err := New(srv.URL, WithMaxBatchSize(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExportSpans(context.Background(), testSpans("a", "b", "c", "d", "e")); err != nil {
+		t.Fatal(err)
+	}
+	var got [][]string
+	for _, r := range c.received() {
+		var names []string
+		for _, s := range r.spans {
+			names = append(names, s.Name)
+		}
+		got = append(got, names)
+	}
+	if len(got) != 3 || len(got[0]) != 2 || len(got[1]) != 2 || len(got[2]) != 1 ||
+		got[0][0] != "a" || got[1][0] != "c" || got[2][0] != "e" {
+		t.Errorf("got batches %v, want [[a b] [c d] [e]]", got)
+	}
+}
+
+func TestZipkinExporterErrors(t *testing.T) {
+	c, srv := newCollector(t, http.StatusInternalServerError)
+	e, err := New(srv.URL, WithMaxBatchSize(1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExportSpans(context.Background(), testSpans("a", "b")); err == nil {
+		t.Error("ExportSpans() = nil, want an error for the status")
+	}
+	if got := len(c.received()); got != 1 {
+		t.Errorf("got %d requests, want the export to stop at the first failure", got)
+	}
+
+	if err := e.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := e.ExportSpans(context.Background(), testSpans("c")); err != nil {
+		t.Errorf("ExportSpans() = %v after Shutdown, want nil", err)
+	}
+	if got := len(c.received()); got != 1 {
+		t.Errorf("got %d requests, want none after Shutdown", got-1)
+	}
+
+	if _, err := New("localhost:9411"); err == nil {
+		t.Error("New() = nil error, want an error for a URL without a scheme")
+	}
+	if _, err := New(srv.URL, WithEncoding(ZipkinEncoding(7))); err == nil {
+		t.Error("New() = nil error, want an error for an unknown encoding")
+	}
+}
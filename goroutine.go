@@ -0,0 +1,253 @@
+package tel
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DetachContext returns a context carrying the values of ctx, such as its
+// span and baggage, but neither its deadline nor its cancellation. Use it
+// for follow-up work that must outlive the operation of ctx.
+func DetachContext(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}       { return nil }
+func (detachedContext) Err() error                  { return nil }
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.parent.Value(key)
+}
+
+type goConfig struct {
+	link      bool
+	detach    bool
+	spanStart []SpanStartOption
+}
+
+// GoOption applies an option to Go and GoFunc.
+type GoOption func(*goConfig)
+
+// GoWithLink starts the span of the function as the root of a new trace
+// linked to the span of the context, instead of as its child.
+func GoWithLink() GoOption {
+	return func(cfg *goConfig) {
+		cfg.link = true
+	}
+}
+
+// GoWithDetach runs the function with the context detached from the
+// cancellation of the context passed, as by DetachContext.
+func GoWithDetach() GoOption {
+	return func(cfg *goConfig) {
+		cfg.detach = true
+	}
+}
+
+// GoWithSpanOptions sets options for starting the span of the function.
+func GoWithSpanOptions(opts ...SpanStartOption) GoOption {
+	return func(cfg *goConfig) {
+		cfg.spanStart = append(cfg.spanStart, opts...)
+	}
+}
+
+// Go runs fn in a new goroutine within a span named name, started with a
+// tracer named after the package of the caller. The error fn returns is
+// recorded with RecordException, and a panic is recorded before
+// propagating, as by the EndFunc of Start.
+func Go(ctx context.Context, name string, fn func(context.Context) error, opts ...GoOption) {
+	f := goFunc(callerTracer(), ctx, name, fn, opts)
+	go func() {
+		_ = f()
+	}()
+}
+
+// GoFunc returns a function running fn within a span like Go, for running
+// with a goroutine launcher such as errgroup.Group:
+//
+//	g.Go(tel.GoFunc(ctx, "fetch", fetch))
+//
+// The span is started when the returned function is called.
+func GoFunc(ctx context.Context, name string, fn func(context.Context) error, opts ...GoOption) func() error {
+	return goFunc(callerTracer(), ctx, name, fn, opts)
+}
+
+func goFunc(tracer Tracer, ctx context.Context, name string, fn func(context.Context) error, opts []GoOption) func() error {
+	var cfg goConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.detach {
+		ctx = DetachContext(ctx)
+	}
+	spanStart := cfg.spanStart
+	if cfg.link {
+		if link := LinkFromContext(ctx); link.SpanContext.IsValid() {
+			spanStart = append(spanStart[:len(spanStart):len(spanStart)], WithNewRoot(), WithLinks(link))
+		}
+	}
+	return func() (err error) {
+		ctx, span := tracer.Start(ctx, name, spanStart...)
+		end := endSpan(span)
+		defer end(&err)
+		return fn(ctx)
+	}
+}
+
+// ErrPoolClosed is returned when submitting a task to a closed Pool.
+var ErrPoolClosed = errors.New("tel: pool closed")
+
+type poolConfig struct {
+	workers   int
+	queueSize int
+	spanStart []SpanStartOption
+}
+
+// PoolOption applies an option to a Pool.
+type PoolOption func(*poolConfig)
+
+// PoolWithWorkers sets the number of goroutines running the tasks. The
+// default is 1.
+func PoolWithWorkers(n int) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.workers = n
+	}
+}
+
+// PoolWithQueueSize sets the number of tasks that can wait for a worker
+// before Submit blocks. The default is 0.
+func PoolWithQueueSize(n int) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.queueSize = n
+	}
+}
+
+// PoolWithSpanOptions sets options for starting the spans of the tasks.
+func PoolWithSpanOptions(opts ...SpanStartOption) PoolOption {
+	return func(cfg *poolConfig) {
+		cfg.spanStart = append(cfg.spanStart, opts...)
+	}
+}
+
+// Pool runs tasks on a fixed number of goroutines. Each task runs within a
+// span of its own, the root of a new trace linked to the span of the
+// context it was submitted with, as tasks usually outlive the operation
+// submitting them. A task panicking has the panic recorded on its span and
+// returned as its error.
+type Pool struct {
+	tracer    Tracer
+	spanStart []SpanStartOption
+	tasks     chan poolTask
+	wg        sync.WaitGroup
+
+	// closing is closed by Close to release the calls to Submit waiting
+	// for a worker, tracked by submits, before tasks is closed.
+	closing chan struct{}
+	submits sync.WaitGroup
+
+	mu     sync.Mutex
+	closed bool
+
+	errOnce sync.Once
+	err     error
+}
+
+type poolTask struct {
+	ctx  context.Context
+	name string
+	fn   func(context.Context) error
+}
+
+// NewPool returns a Pool whose spans are started with a tracer named after
+// the package of the caller.
+func NewPool(opts ...PoolOption) *Pool {
+	cfg := poolConfig{workers: 1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.workers < 1 {
+		cfg.workers = 1
+	}
+	p := &Pool{
+		tracer:    callerTracer(),
+		spanStart: cfg.spanStart,
+		tasks:     make(chan poolTask, cfg.queueSize),
+		closing:   make(chan struct{}),
+	}
+	p.wg.Add(cfg.workers)
+	for i := 0; i < cfg.workers; i++ {
+		go p.work()
+	}
+	return p
+}
+
+// Submit queues fn to run within a span named name, blocking until a worker
+// or a place in the queue is available. The task runs with ctx, whose
+// values it keeps; use DetachContext for tasks that must not be canceled
+// with it. A call blocked when the pool is closed returns ErrPoolClosed.
+func (p *Pool) Submit(ctx context.Context, name string, fn func(context.Context) error) error {
+	p.mu.Lock()
+	if p.closed {
+		p.mu.Unlock()
+		return ErrPoolClosed
+	}
+	p.submits.Add(1)
+	p.mu.Unlock()
+	defer p.submits.Done()
+
+	select {
+	case p.tasks <- poolTask{ctx: ctx, name: name, fn: fn}:
+		return nil
+	case <-p.closing:
+		return ErrPoolClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops accepting tasks and waits for the submitted ones to finish,
+// returning the first error a task returned.
+func (p *Pool) Close() error {
+	p.mu.Lock()
+	closed := p.closed
+	p.closed = true
+	p.mu.Unlock()
+	if !closed {
+		close(p.closing)
+		p.submits.Wait()
+		close(p.tasks)
+	}
+	p.wg.Wait()
+	return p.err
+}
+
+func (p *Pool) work() {
+	defer p.wg.Done()
+	for t := range p.tasks {
+		if err := p.run(t); err != nil {
+			p.errOnce.Do(func() { p.err = err })
+		}
+	}
+}
+
+// run runs t, returning a panic of the task, once recorded on its span, as
+// its error, so it doesn't take down the worker and the program.
+func (p *Pool) run(t poolTask) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("panic: %v", v)
+		}
+	}()
+	return goFunc(p.tracer, t.ctx, t.name, t.fn, []GoOption{
+		GoWithSpanOptions(p.spanStart...),
+		GoWithLink(),
+	})()
+}
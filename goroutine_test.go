@@ -0,0 +1,138 @@
+package tel
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// newTestPool returns a Pool recording its spans, and a tracer of the same
+// provider.
+func newTestPool(opts ...PoolOption) (*Pool, *tracetest.SpanRecorder, Tracer) {
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	p := NewPool(opts...)
+	p.tracer = tp.Tracer("pool")
+	return p, sr, tp.Tracer("test")
+}
+
+func TestPoolPanic(t *testing.T) {
+	p, sr, _ := newTestPool()
+	err := p.Submit(context.Background(), "task", func(context.Context) error {
+		panic("boom")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Submit(context.Background(), "next", func(context.Context) error { return nil }); err != nil {
+		t.Fatalf("Submit() = %v after a panic, want the worker to keep running", err)
+	}
+	if err := p.Close(); err == nil || err.Error() != "panic: boom" {
+		t.Errorf("Close() = %v, want the panic as an error", err)
+	}
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	span := spans[0]
+	if span.Name() != "task" || span.Status().Code != codes.Error || span.Status().Description != "boom" {
+		t.Errorf("got span %q with status %v, want the panic recorded", span.Name(), span.Status())
+	}
+	var escaped bool
+	for _, ev := range span.Events() {
+		for _, kv := range ev.Attributes {
+			if kv.Key == "exception.escaped" && kv.Value.AsBool() {
+				escaped = true
+			}
+		}
+	}
+	if !escaped {
+		t.Errorf("got events %v, want an escaped exception", span.Events())
+	}
+}
+
+func TestPoolLinksSpan(t *testing.T) {
+	p, sr, tracer := newTestPool(PoolWithSpanOptions(WithAttributes(AttributeString("a", "b"))))
+	ctx, parent := tracer.Start(context.Background(), "submit")
+	err := p.Submit(ctx, "task", func(ctx context.Context) error {
+		if !SpanFromContext(ctx).SpanContext().IsValid() {
+			t.Error("got no span in the context of the task")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Close(); err != nil {
+		t.Fatal(err)
+	}
+	parent.End()
+
+	var task sdktrace.ReadOnlySpan
+	for _, s := range sr.Ended() {
+		if s.Name() == "task" {
+			task = s
+		}
+	}
+	if task == nil {
+		t.Fatal("got no span for the task")
+	}
+	psc := parent.SpanContext()
+	if task.Parent().IsValid() || task.SpanContext().TraceID() == psc.TraceID() {
+		t.Errorf("got parent %v, want the root of a new trace", task.Parent())
+	}
+	if links := task.Links(); len(links) != 1 || !links[0].SpanContext.Equal(psc) {
+		t.Errorf("got links %v, want a link to the span submitting the task", links)
+	}
+	if attrs := task.Attributes(); len(attrs) != 1 || attrs[0] != AttributeString("a", "b") {
+		t.Errorf("got attributes %v, want the ones of PoolWithSpanOptions", attrs)
+	}
+}
+
+func TestPoolClosed(t *testing.T) {
+	p, _, _ := newTestPool()
+	release := make(chan struct{})
+	err := p.Submit(context.Background(), "busy", func(context.Context) error {
+		<-release
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The worker is busy and there is no queue: Submit blocks until the
+	// pool is closed.
+	submitted := make(chan error)
+	go func() {
+		submitted <- p.Submit(context.Background(), "blocked", func(context.Context) error { return nil })
+	}()
+	closed := make(chan error)
+	go func() {
+		closed <- p.Close()
+	}()
+	select {
+	case err := <-submitted:
+		if !errors.Is(err, ErrPoolClosed) {
+			t.Errorf("Submit() = %v, want %v", err, ErrPoolClosed)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Submit is still blocked after Close")
+	}
+
+	close(release)
+	if err := <-closed; err != nil {
+		t.Errorf("Close() = %v", err)
+	}
+	if err := p.Submit(context.Background(), "late", func(context.Context) error { return nil }); !errors.Is(err, ErrPoolClosed) {
+		t.Errorf("Submit() = %v after Close, want %v", err, ErrPoolClosed)
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("second Close() = %v", err)
+	}
+}
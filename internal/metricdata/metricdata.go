@@ -0,0 +1,234 @@
+package metricdata
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+)
+
+// Sum is an aggregation.Sum holding a precomputed value.
+type Sum struct {
+	value number.Number
+}
+
+// NewSum returns a Sum aggregation for value.
+func NewSum(value number.Number) Sum {
+	return Sum{value: value}
+}
+
+// Kind returns aggregation.SumKind.
+func (s Sum) Kind() aggregation.Kind {
+	return aggregation.SumKind
+}
+
+// Sum returns the aggregated sum.
+func (s Sum) Sum() (number.Number, error) {
+	return s.value, nil
+}
+
+// LastValue is an aggregation.LastValue holding a precomputed value.
+type LastValue struct {
+	value     number.Number
+	timestamp time.Time
+}
+
+// NewLastValue returns a LastValue aggregation for value observed at timestamp.
+func NewLastValue(value number.Number, timestamp time.Time) LastValue {
+	return LastValue{value: value, timestamp: timestamp}
+}
+
+// Kind returns aggregation.LastValueKind.
+func (lv LastValue) Kind() aggregation.Kind {
+	return aggregation.LastValueKind
+}
+
+// LastValue returns the last value and the time it was observed.
+func (lv LastValue) LastValue() (number.Number, time.Time, error) {
+	return lv.value, lv.timestamp, nil
+}
+
+// Histogram is an aggregation.Histogram holding precomputed buckets.
+type Histogram struct {
+	count   uint64
+	sum     number.Number
+	buckets aggregation.Buckets
+}
+
+// NewHistogram returns a Histogram aggregation.
+func NewHistogram(count uint64, sum number.Number, buckets aggregation.Buckets) Histogram {
+	return Histogram{count: count, sum: sum, buckets: buckets}
+}
+
+// Kind returns aggregation.HistogramKind.
+func (h Histogram) Kind() aggregation.Kind {
+	return aggregation.HistogramKind
+}
+
+// Count returns the number of values aggregated.
+func (h Histogram) Count() (uint64, error) {
+	return h.count, nil
+}
+
+// Sum returns the sum of values aggregated.
+func (h Histogram) Sum() (number.Number, error) {
+	return h.sum, nil
+}
+
+// Histogram returns the bucket boundaries and counts.
+func (h Histogram) Histogram() (aggregation.Buckets, error) {
+	return h.buckets, nil
+}
+
+// ExponentialHistogram is a telsdk.ExponentialHistogram holding precomputed
+// buckets.
+type ExponentialHistogram struct {
+	count     uint64
+	sum       number.Number
+	scale     int32
+	zeroCount uint64
+	positive  Buckets
+	negative  Buckets
+}
+
+// NewExponentialHistogram returns an ExponentialHistogram aggregation.
+func NewExponentialHistogram(count uint64, sum number.Number, scale int32, zeroCount uint64, positive, negative Buckets) ExponentialHistogram {
+	return ExponentialHistogram{
+		count:     count,
+		sum:       sum,
+		scale:     scale,
+		zeroCount: zeroCount,
+		positive:  positive,
+		negative:  negative,
+	}
+}
+
+// Kind returns telsdk.ExponentialHistogramKind.
+func (h ExponentialHistogram) Kind() aggregation.Kind {
+	return telsdk.ExponentialHistogramKind
+}
+
+// Count returns the number of values aggregated.
+func (h ExponentialHistogram) Count() (uint64, error) {
+	return h.count, nil
+}
+
+// Sum returns the sum of values aggregated.
+func (h ExponentialHistogram) Sum() (number.Number, error) {
+	return h.sum, nil
+}
+
+// Scale returns the scale of the buckets.
+func (h ExponentialHistogram) Scale() int32 {
+	return h.scale
+}
+
+// ZeroCount returns the number of zeros aggregated.
+func (h ExponentialHistogram) ZeroCount() uint64 {
+	return h.zeroCount
+}
+
+// Positive returns the buckets of the positive values.
+func (h ExponentialHistogram) Positive() telsdk.ExponentialBuckets {
+	return h.positive
+}
+
+// Negative returns the buckets of the negative values.
+func (h ExponentialHistogram) Negative() telsdk.ExponentialBuckets {
+	return h.negative
+}
+
+// Buckets is a telsdk.ExponentialBuckets holding precomputed counts.
+type Buckets struct {
+	offset int32
+	counts []uint64
+}
+
+// NewBuckets returns the Buckets starting at index offset.
+func NewBuckets(offset int32, counts []uint64) Buckets {
+	return Buckets{offset: offset, counts: counts}
+}
+
+// Offset returns the index of the first bucket.
+func (b Buckets) Offset() int32 {
+	return b.offset
+}
+
+// Len returns the number of buckets.
+func (b Buckets) Len() uint32 {
+	return uint32(len(b.counts))
+}
+
+// At returns the count of the bucket at position pos.
+func (b Buckets) At(pos uint32) uint64 {
+	return b.counts[pos]
+}
+
+// Library holds the records produced by a single instrumentation library.
+type Library struct {
+	Library instrumentation.Library
+	Records []export.Record
+}
+
+// NewInstrumentationLibraryReader returns an InstrumentationLibraryReader
+// iterating over libs in order.
+func NewInstrumentationLibraryReader(libs ...Library) export.InstrumentationLibraryReader {
+	return libraryReader(libs)
+}
+
+type libraryReader []Library
+
+func (l libraryReader) ForEach(readerFunc func(instrumentation.Library, export.Reader) error) error {
+	for _, lib := range l {
+		if err := readerFunc(lib.Library, NewReader(lib.Records...)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewReader returns an export.Reader over records.
+//
+// The records are returned as-is, regardless of the TemporalitySelector
+// passed to ForEach.
+func NewReader(records ...export.Record) export.Reader {
+	return &reader{records: records}
+}
+
+type reader struct {
+	sync.RWMutex
+	records []export.Record
+}
+
+func (r *reader) ForEach(_ aggregation.TemporalitySelector, recordFunc func(export.Record) error) error {
+	for _, rec := range r.records {
+		if err := recordFunc(rec); err != nil && !errors.Is(err, aggregation.ErrNoData) {
+			return err
+		}
+	}
+	return nil
+}
+
+// Collect copies every record out of reader, so they can be iterated more
+// than once. The aggregations are not copied and remain valid only until
+// the next collection.
+func Collect(reader export.InstrumentationLibraryReader, temporalitySelector aggregation.TemporalitySelector) ([]Library, error) {
+	var libs []Library
+	err := reader.ForEach(func(lib instrumentation.Library, r export.Reader) error {
+		l := Library{Library: lib}
+		if err := r.ForEach(temporalitySelector, func(rec export.Record) error {
+			l.Records = append(l.Records, rec)
+			return nil
+		}); err != nil {
+			return err
+		}
+		libs = append(libs, l)
+		return nil
+	})
+	return libs, err
+}
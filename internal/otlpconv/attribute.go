@@ -0,0 +1,217 @@
+package otlpconv
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	resourcepb "go.opentelemetry.io/proto/otlp/resource/v1"
+	"google.golang.org/protobuf/encoding/protojson"
+)
+
+// KeyValues transforms a slice of attribute KeyValues into OTLP key-values.
+func KeyValues(attrs []attribute.KeyValue) []*commonpb.KeyValue {
+	if len(attrs) == 0 {
+		return nil
+	}
+
+	out := make([]*commonpb.KeyValue, 0, len(attrs))
+	for _, kv := range attrs {
+		out = append(out, KeyValue(kv))
+	}
+	return out
+}
+
+// Iterator transforms an attribute iterator into OTLP key-values.
+func Iterator(iter attribute.Iterator) []*commonpb.KeyValue {
+	l := iter.Len()
+	if l == 0 {
+		return nil
+	}
+
+	out := make([]*commonpb.KeyValue, 0, l)
+	for iter.Next() {
+		out = append(out, KeyValue(iter.Attribute()))
+	}
+	return out
+}
+
+// KeyValue transforms an attribute KeyValue into an OTLP key-value.
+func KeyValue(kv attribute.KeyValue) *commonpb.KeyValue {
+	return &commonpb.KeyValue{Key: string(kv.Key), Value: Value(kv.Value)}
+}
+
+// Value transforms an attribute Value into an OTLP AnyValue.
+func Value(v attribute.Value) *commonpb.AnyValue {
+	av := new(commonpb.AnyValue)
+	switch v.Type() {
+	case attribute.BOOL:
+		av.Value = &commonpb.AnyValue_BoolValue{BoolValue: v.AsBool()}
+	case attribute.BOOLSLICE:
+		vals := v.AsBoolSlice()
+		values := make([]*commonpb.AnyValue, len(vals))
+		for i, v := range vals {
+			values[i] = &commonpb.AnyValue{Value: &commonpb.AnyValue_BoolValue{BoolValue: v}}
+		}
+		av.Value = &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}
+	case attribute.INT64:
+		av.Value = &commonpb.AnyValue_IntValue{IntValue: v.AsInt64()}
+	case attribute.INT64SLICE:
+		vals := v.AsInt64Slice()
+		values := make([]*commonpb.AnyValue, len(vals))
+		for i, v := range vals {
+			values[i] = &commonpb.AnyValue{Value: &commonpb.AnyValue_IntValue{IntValue: v}}
+		}
+		av.Value = &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}
+	case attribute.FLOAT64:
+		av.Value = &commonpb.AnyValue_DoubleValue{DoubleValue: v.AsFloat64()}
+	case attribute.FLOAT64SLICE:
+		vals := v.AsFloat64Slice()
+		values := make([]*commonpb.AnyValue, len(vals))
+		for i, v := range vals {
+			values[i] = &commonpb.AnyValue{Value: &commonpb.AnyValue_DoubleValue{DoubleValue: v}}
+		}
+		av.Value = &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}
+	case attribute.STRING:
+		av.Value = &commonpb.AnyValue_StringValue{StringValue: v.AsString()}
+	case attribute.STRINGSLICE:
+		vals := v.AsStringSlice()
+		values := make([]*commonpb.AnyValue, len(vals))
+		for i, v := range vals {
+			values[i] = &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: v}}
+		}
+		av.Value = &commonpb.AnyValue_ArrayValue{ArrayValue: &commonpb.ArrayValue{Values: values}}
+	default:
+		av.Value = &commonpb.AnyValue_StringValue{StringValue: "INVALID"}
+	}
+	return av
+}
+
+// Attributes transforms OTLP key-values into attribute KeyValues.
+func Attributes(kvs []*commonpb.KeyValue) []attribute.KeyValue {
+	if len(kvs) == 0 {
+		return nil
+	}
+
+	out := make([]attribute.KeyValue, 0, len(kvs))
+	for _, kv := range kvs {
+		out = append(out, attribute.KeyValue{
+			Key:   attribute.Key(kv.GetKey()),
+			Value: AttributeValue(kv.GetValue()),
+		})
+	}
+	return out
+}
+
+// AttributeValue transforms an OTLP AnyValue into an attribute Value.
+//
+// Arrays holding a single scalar type are converted to the matching slice
+// type. Values that cannot be represented, such as maps, bytes or
+// heterogeneous arrays, are converted to their OTLP/JSON string encoding.
+func AttributeValue(av *commonpb.AnyValue) attribute.Value {
+	switch v := av.GetValue().(type) {
+	case *commonpb.AnyValue_BoolValue:
+		return attribute.BoolValue(v.BoolValue)
+	case *commonpb.AnyValue_IntValue:
+		return attribute.Int64Value(v.IntValue)
+	case *commonpb.AnyValue_DoubleValue:
+		return attribute.Float64Value(v.DoubleValue)
+	case *commonpb.AnyValue_StringValue:
+		return attribute.StringValue(v.StringValue)
+	case *commonpb.AnyValue_ArrayValue:
+		if value, ok := arrayValue(v.ArrayValue.GetValues()); ok {
+			return value
+		}
+	case nil:
+		return attribute.Value{}
+	}
+	return attribute.StringValue(protojson.Format(av))
+}
+
+func arrayValue(values []*commonpb.AnyValue) (attribute.Value, bool) {
+	if len(values) == 0 {
+		return attribute.StringSliceValue(nil), true
+	}
+	switch values[0].GetValue().(type) {
+	case *commonpb.AnyValue_BoolValue:
+		out := make([]bool, len(values))
+		for i, v := range values {
+			b, ok := v.GetValue().(*commonpb.AnyValue_BoolValue)
+			if !ok {
+				return attribute.Value{}, false
+			}
+			out[i] = b.BoolValue
+		}
+		return attribute.BoolSliceValue(out), true
+	case *commonpb.AnyValue_IntValue:
+		out := make([]int64, len(values))
+		for i, v := range values {
+			n, ok := v.GetValue().(*commonpb.AnyValue_IntValue)
+			if !ok {
+				return attribute.Value{}, false
+			}
+			out[i] = n.IntValue
+		}
+		return attribute.Int64SliceValue(out), true
+	case *commonpb.AnyValue_DoubleValue:
+		out := make([]float64, len(values))
+		for i, v := range values {
+			f, ok := v.GetValue().(*commonpb.AnyValue_DoubleValue)
+			if !ok {
+				return attribute.Value{}, false
+			}
+			out[i] = f.DoubleValue
+		}
+		return attribute.Float64SliceValue(out), true
+	case *commonpb.AnyValue_StringValue:
+		out := make([]string, len(values))
+		for i, v := range values {
+			s, ok := v.GetValue().(*commonpb.AnyValue_StringValue)
+			if !ok {
+				return attribute.Value{}, false
+			}
+			out[i] = s.StringValue
+		}
+		return attribute.StringSliceValue(out), true
+	}
+	return attribute.Value{}, false
+}
+
+// Resource transforms a Resource into an OTLP Resource.
+func Resource(r *resource.Resource) *resourcepb.Resource {
+	if r == nil {
+		return nil
+	}
+	return &resourcepb.Resource{Attributes: Iterator(r.Iter())}
+}
+
+// ResourceFromProto transforms an OTLP Resource into a Resource.
+func ResourceFromProto(r *resourcepb.Resource, schemaURL string) *resource.Resource {
+	attrs := Attributes(r.GetAttributes())
+	if len(attrs) == 0 && schemaURL == "" {
+		return resource.Empty()
+	}
+	return resource.NewWithAttributes(schemaURL, attrs...)
+}
+
+// InstrumentationScope transforms an instrumentation Library into an OTLP
+// InstrumentationScope.
+func InstrumentationScope(il instrumentation.Library) *commonpb.InstrumentationScope {
+	if il == (instrumentation.Library{}) {
+		return nil
+	}
+	return &commonpb.InstrumentationScope{
+		Name:    il.Name,
+		Version: il.Version,
+	}
+}
+
+// InstrumentationLibrary transforms an OTLP InstrumentationScope into an
+// instrumentation Library.
+func InstrumentationLibrary(scope *commonpb.InstrumentationScope, schemaURL string) instrumentation.Library {
+	return instrumentation.Library{
+		Name:      scope.GetName(),
+		Version:   scope.GetVersion(),
+		SchemaURL: schemaURL,
+	}
+}
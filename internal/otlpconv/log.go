@@ -0,0 +1,150 @@
+package otlpconv
+
+import (
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	logspb "go.opentelemetry.io/proto/otlp/logs/v1"
+)
+
+// Logs transforms log records into OTLP ResourceLogs, grouped by resource
+// and instrumentation library.
+func Logs(records []telsdk.LogData) []*logspb.ResourceLogs {
+	if len(records) == 0 {
+		return nil
+	}
+
+	type key struct {
+		r  attribute.Distinct
+		il instrumentation.Library
+	}
+	rlm := make(map[attribute.Distinct]*logspb.ResourceLogs)
+	slm := make(map[key]*logspb.ScopeLogs)
+
+	// Keep the order records were received in, so output is deterministic.
+	var rls []*logspb.ResourceLogs
+	for _, r := range records {
+		rKey := r.Resource.Equivalent()
+		rl, ok := rlm[rKey]
+		if !ok {
+			rl = &logspb.ResourceLogs{
+				Resource:  Resource(r.Resource),
+				SchemaUrl: r.Resource.SchemaURL(),
+			}
+			rlm[rKey] = rl
+			rls = append(rls, rl)
+		}
+
+		k := key{r: rKey, il: r.InstrumentationLibrary}
+		sl, ok := slm[k]
+		if !ok {
+			sl = &logspb.ScopeLogs{
+				Scope:     InstrumentationScope(r.InstrumentationLibrary),
+				SchemaUrl: r.InstrumentationLibrary.SchemaURL,
+			}
+			slm[k] = sl
+			rl.ScopeLogs = append(rl.ScopeLogs, sl)
+		}
+		sl.LogRecords = append(sl.LogRecords, LogRecord(r))
+	}
+	return rls
+}
+
+// LogRecord transforms a log record into an OTLP log record.
+func LogRecord(r telsdk.LogData) *logspb.LogRecord {
+	lr := &logspb.LogRecord{
+		TimeUnixNano:         toNanos(r.Timestamp),
+		ObservedTimeUnixNano: toNanos(r.ObservedTimestamp),
+		SeverityNumber:       logspb.SeverityNumber(r.Severity),
+		SeverityText:         r.SeverityText,
+		Attributes:           AnyKeyValues(r.Attributes),
+		Flags:                uint32(r.TraceFlags),
+	}
+	if r.Body.Kind() != tel.AnyKindEmpty {
+		lr.Body = AnyValue(r.Body)
+	}
+	if r.TraceID.IsValid() {
+		lr.TraceId = r.TraceID[:]
+	}
+	if r.SpanID.IsValid() {
+		lr.SpanId = r.SpanID[:]
+	}
+	return lr
+}
+
+// AnyKeyValues transforms the attributes of a log record into OTLP
+// key-values.
+func AnyKeyValues(kvs []tel.AnyKeyValue) []*commonpb.KeyValue {
+	if len(kvs) == 0 {
+		return nil
+	}
+	out := make([]*commonpb.KeyValue, len(kvs))
+	for i, kv := range kvs {
+		out[i] = &commonpb.KeyValue{Key: string(kv.Key), Value: AnyValue(kv.Value)}
+	}
+	return out
+}
+
+// AnyValue transforms a value of a log record into an OTLP AnyValue.
+func AnyValue(v tel.AnyValue) *commonpb.AnyValue {
+	switch v.Kind() {
+	case tel.AnyKindValue:
+		return Value(v.AsValue())
+	case tel.AnyKindBytes:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: v.AsBytes()}}
+	case tel.AnyKindMap:
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{
+			KvlistValue: &commonpb.KeyValueList{Values: AnyKeyValues(v.AsMap())},
+		}}
+	case tel.AnyKindArray:
+		vals := v.AsArray()
+		values := make([]*commonpb.AnyValue, len(vals))
+		for i, e := range vals {
+			values[i] = AnyValue(e)
+		}
+		return &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{
+			ArrayValue: &commonpb.ArrayValue{Values: values},
+		}}
+	}
+	return new(commonpb.AnyValue)
+}
+
+// LogAttributes transforms OTLP key-values into the attributes of a log
+// record.
+func LogAttributes(kvs []*commonpb.KeyValue) []tel.AnyKeyValue {
+	if len(kvs) == 0 {
+		return nil
+	}
+	out := make([]tel.AnyKeyValue, len(kvs))
+	for i, kv := range kvs {
+		out[i] = tel.AnyKeyValue{Key: tel.Key(kv.GetKey()), Value: LogValue(kv.GetValue())}
+	}
+	return out
+}
+
+// LogValue transforms an OTLP AnyValue into a value of a log record. Arrays
+// holding a single scalar type are converted to the matching slice Value,
+// as by AttributeValue, and other arrays to arrays of AnyValues.
+func LogValue(av *commonpb.AnyValue) tel.AnyValue {
+	switch v := av.GetValue().(type) {
+	case *commonpb.AnyValue_BytesValue:
+		return tel.AnyBytes(v.BytesValue)
+	case *commonpb.AnyValue_KvlistValue:
+		return tel.AnyMap(LogAttributes(v.KvlistValue.GetValues())...)
+	case *commonpb.AnyValue_ArrayValue:
+		values := v.ArrayValue.GetValues()
+		if value, ok := arrayValue(values); ok {
+			return tel.AnyOf(value)
+		}
+		vals := make([]tel.AnyValue, len(values))
+		for i, e := range values {
+			vals[i] = LogValue(e)
+		}
+		return tel.AnyArray(vals...)
+	case nil:
+		return tel.AnyValue{}
+	}
+	return tel.AnyOf(AttributeValue(av))
+}
@@ -0,0 +1,107 @@
+package otlpconv
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestAnyValueRoundTrip(t *testing.T) {
+	tests := []tel.AnyValue{
+		{},
+		tel.AnyString("x"),
+		tel.AnyOf(tel.Int64Value(-1)),
+		tel.AnyOf(tel.Float64Value(1.5)),
+		tel.AnyOf(tel.BoolValue(true)),
+		tel.AnyOf(tel.StringSliceValue([]string{"a", "b"})),
+		tel.AnyBytes([]byte{0, 1, 2}),
+		tel.AnyArray(tel.AnyString("a"), tel.AnyOf(tel.Int64Value(1)), tel.AnyBytes([]byte("b"))),
+		tel.AnyMap(
+			tel.AnyAttribute("a", tel.AnyString("x")),
+			tel.AnyAttribute("m", tel.AnyMap(tel.AnyAttribute("n", tel.AnyArray(tel.AnyArray())))),
+		),
+	}
+	for _, v := range tests {
+		av := AnyValue(v)
+		// Go through the wire encoding, as a collector would.
+		b, err := proto.Marshal(av)
+		if err != nil {
+			t.Fatal(err)
+		}
+		decoded := new(commonpb.AnyValue)
+		if err := proto.Unmarshal(b, decoded); err != nil {
+			t.Fatal(err)
+		}
+		if got := LogValue(decoded); got.Emit() != v.Emit() || got.Kind() != v.Kind() {
+			t.Errorf("LogValue(AnyValue(%s)) = %s of kind %v, want kind %v", v.Emit(), got.Emit(), got.Kind(), v.Kind())
+		}
+	}
+}
+
+func TestAnyValueKinds(t *testing.T) {
+	tests := []struct {
+		v    tel.AnyValue
+		want *commonpb.AnyValue
+	}{
+		{tel.AnyValue{}, &commonpb.AnyValue{}},
+		{tel.AnyBytes([]byte("hi")), &commonpb.AnyValue{Value: &commonpb.AnyValue_BytesValue{BytesValue: []byte("hi")}}},
+		{tel.AnyMap(tel.AnyAttribute("k", tel.AnyString("v"))), &commonpb.AnyValue{Value: &commonpb.AnyValue_KvlistValue{
+			KvlistValue: &commonpb.KeyValueList{Values: []*commonpb.KeyValue{
+				{Key: "k", Value: &commonpb.AnyValue{Value: &commonpb.AnyValue_StringValue{StringValue: "v"}}},
+			}},
+		}}},
+		{tel.AnyArray(tel.AnyOf(tel.BoolValue(true))), &commonpb.AnyValue{Value: &commonpb.AnyValue_ArrayValue{
+			ArrayValue: &commonpb.ArrayValue{Values: []*commonpb.AnyValue{
+				{Value: &commonpb.AnyValue_BoolValue{BoolValue: true}},
+			}},
+		}}},
+	}
+	for _, tt := range tests {
+		if got := AnyValue(tt.v); !proto.Equal(got, tt.want) {
+			t.Errorf("AnyValue(%s) = %v, want %v", tt.v.Emit(), got, tt.want)
+		}
+	}
+}
+
+func TestLogValueHomogeneousArray(t *testing.T) {
+	av := AnyValue(tel.AnyArray(tel.AnyOf(tel.Int64Value(1)), tel.AnyOf(tel.Int64Value(2))))
+	got := LogValue(av)
+	if got.Kind() != tel.AnyKindValue || !reflect.DeepEqual(got.AsValue().AsInt64Slice(), []int64{1, 2}) {
+		t.Errorf("LogValue() = %s of kind %v, want an INT64SLICE Value", got.Emit(), got.Kind())
+	}
+}
+
+func TestLogs(t *testing.T) {
+	now := time.Unix(1, 0)
+	records := []telsdk.LogData{{
+		LogRecord: tel.LogRecord{
+			Timestamp:  now,
+			Severity:   tel.SeverityInfo,
+			Body:       tel.AnyMap(tel.AnyAttribute("msg", tel.AnyString("hi"))),
+			Attributes: []tel.AnyKeyValue{tel.AnyAttribute("raw", tel.AnyBytes([]byte{1}))},
+		},
+		Resource: telsdk.Empty(),
+	}, {
+		LogRecord: tel.LogRecord{Timestamp: now},
+		Resource:  telsdk.Empty(),
+	}}
+	rls := Logs(records)
+	if len(rls) != 1 || len(rls[0].ScopeLogs) != 1 || len(rls[0].ScopeLogs[0].LogRecords) != 2 {
+		t.Fatalf("Logs() = %v, want a single scope with both records", rls)
+	}
+	lrs := rls[0].ScopeLogs[0].LogRecords
+	if got := LogValue(lrs[0].Body); got.Emit() != `{"msg":"hi"}` {
+		t.Errorf("got body %s, want {\"msg\":\"hi\"}", got.Emit())
+	}
+	if got := LogAttributes(lrs[0].Attributes); len(got) != 1 || string(got[0].Value.AsBytes()) != "\x01" {
+		t.Errorf("got attributes %v, want raw=0x01", got)
+	}
+	if lrs[1].Body != nil {
+		t.Errorf("got body %v for an empty body, want none", lrs[1].Body)
+	}
+}
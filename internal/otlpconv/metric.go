@@ -0,0 +1,411 @@
+package otlpconv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/henvic/tel/internal/metricdata"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/unit"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/sdk/resource"
+	commonpb "go.opentelemetry.io/proto/otlp/common/v1"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+var (
+	// ErrUnimplementedAgg is returned when a transformation of an unimplemented
+	// aggregation is attempted.
+	ErrUnimplementedAgg = errors.New("unimplemented aggregation")
+
+	// ErrIncompatibleAgg is returned when aggregation.Kind implies an
+	// interface conversion that has failed.
+	ErrIncompatibleAgg = errors.New("incompatible aggregation type")
+
+	// ErrUnknownValueType is returned when a transformation of an unknown value
+	// is attempted.
+	ErrUnknownValueType = errors.New("invalid value type")
+
+	// ErrConflictingMetric is returned when records of the same name, such
+	// as instruments renamed by views, can't be merged into a single
+	// metric, as they differ in their kind or temporality.
+	ErrConflictingMetric = errors.New("conflicting metrics of the same name")
+)
+
+// ResourceMetrics transforms the checkpoint of a metric export pipeline
+// into OTLP ResourceMetrics. It returns nil when there is nothing to export.
+func ResourceMetrics(temporalitySelector aggregation.TemporalitySelector, res *resource.Resource, reader export.InstrumentationLibraryReader) (*metricpb.ResourceMetrics, error) {
+	var sms []*metricpb.ScopeMetrics
+	// conflict is the error merging the first record that couldn't be
+	// merged, which is dropped rather than failing the whole export.
+	var conflict error
+	err := reader.ForEach(func(lib instrumentation.Library, mr export.Reader) error {
+		var ms []*metricpb.Metric
+		grouped := map[string]*metricpb.Metric{}
+		if err := mr.ForEach(temporalitySelector, func(r export.Record) error {
+			m, err := Record(temporalitySelector, r)
+			if err != nil || m == nil {
+				return err
+			}
+			if g, ok := grouped[m.Name]; ok {
+				if err := merge(g, m); err != nil && conflict == nil {
+					conflict = err
+				}
+				return nil
+			}
+			grouped[m.Name] = m
+			ms = append(ms, m)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if len(ms) == 0 {
+			return nil
+		}
+		sms = append(sms, &metricpb.ScopeMetrics{
+			Scope:     InstrumentationScope(lib),
+			Metrics:   ms,
+			SchemaUrl: lib.SchemaURL,
+		})
+		return nil
+	})
+	if err == nil {
+		err = conflict
+	}
+	if len(sms) == 0 {
+		return nil, err
+	}
+	return &metricpb.ResourceMetrics{
+		Resource:     Resource(res),
+		ScopeMetrics: sms,
+		SchemaUrl:    res.SchemaURL(),
+	}, err
+}
+
+// merge appends the data points of m into g, which must share its name. It
+// returns ErrConflictingMetric, leaving g unchanged, if m has a different
+// kind or temporality.
+func merge(g, m *metricpb.Metric) error {
+	conflict := fmt.Errorf("%w: %q is both a %T and a %T", ErrConflictingMetric, m.Name, g.Data, m.Data)
+	switch data := m.Data.(type) {
+	case *metricpb.Metric_Gauge:
+		if g.GetGauge() == nil {
+			return conflict
+		}
+		g.GetGauge().DataPoints = append(g.GetGauge().DataPoints, data.Gauge.DataPoints...)
+	case *metricpb.Metric_Sum:
+		gs := g.GetSum()
+		if gs == nil {
+			return conflict
+		}
+		if gs.AggregationTemporality != data.Sum.AggregationTemporality || gs.IsMonotonic != data.Sum.IsMonotonic {
+			return fmt.Errorf("%w: %q has sums of different temporality or monotonicity", ErrConflictingMetric, m.Name)
+		}
+		gs.DataPoints = append(gs.DataPoints, data.Sum.DataPoints...)
+	case *metricpb.Metric_Histogram:
+		gh := g.GetHistogram()
+		if gh == nil {
+			return conflict
+		}
+		if gh.AggregationTemporality != data.Histogram.AggregationTemporality {
+			return fmt.Errorf("%w: %q has histograms of different temporality", ErrConflictingMetric, m.Name)
+		}
+		gh.DataPoints = append(gh.DataPoints, data.Histogram.DataPoints...)
+	case *metricpb.Metric_ExponentialHistogram:
+		gh := g.GetExponentialHistogram()
+		if gh == nil {
+			return conflict
+		}
+		if gh.AggregationTemporality != data.ExponentialHistogram.AggregationTemporality {
+			return fmt.Errorf("%w: %q has histograms of different temporality", ErrConflictingMetric, m.Name)
+		}
+		gh.DataPoints = append(gh.DataPoints, data.ExponentialHistogram.DataPoints...)
+	default:
+		return fmt.Errorf("%w: %T", ErrUnimplementedAgg, m.Data)
+	}
+	return nil
+}
+
+// Record transforms a single export Record into an OTLP Metric.
+func Record(temporalitySelector aggregation.TemporalitySelector, r export.Record) (*metricpb.Metric, error) {
+	desc := r.Descriptor()
+	m := &metricpb.Metric{
+		Name:        desc.Name(),
+		Description: desc.Description(),
+		Unit:        string(desc.Unit()),
+	}
+	attrs := Iterator(r.Attributes().Iter())
+
+	agg := r.Aggregation()
+	switch agg.Kind() {
+	case aggregation.HistogramKind:
+		h, ok := agg.(aggregation.Histogram)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrIncompatibleAgg, agg)
+		}
+		buckets, err := h.Histogram()
+		if err != nil {
+			return nil, err
+		}
+		count, err := h.Count()
+		if err != nil {
+			return nil, err
+		}
+		sum, err := h.Sum()
+		if err != nil {
+			return nil, err
+		}
+		sumFloat64 := sum.CoerceToFloat64(desc.NumberKind())
+		m.Data = &metricpb.Metric_Histogram{
+			Histogram: &metricpb.Histogram{
+				AggregationTemporality: temporality(temporalitySelector.TemporalityFor(desc, aggregation.HistogramKind)),
+				DataPoints: []*metricpb.HistogramDataPoint{{
+					Attributes:        attrs,
+					StartTimeUnixNano: toNanos(r.StartTime()),
+					TimeUnixNano:      toNanos(r.EndTime()),
+					Count:             count,
+					Sum:               &sumFloat64,
+					BucketCounts:      buckets.Counts,
+					ExplicitBounds:    buckets.Boundaries,
+				}},
+			},
+		}
+
+	case telsdk.ExponentialHistogramKind:
+		h, ok := agg.(telsdk.ExponentialHistogram)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrIncompatibleAgg, agg)
+		}
+		count, err := h.Count()
+		if err != nil {
+			return nil, err
+		}
+		sum, err := h.Sum()
+		if err != nil {
+			return nil, err
+		}
+		m.Data = &metricpb.Metric_ExponentialHistogram{
+			ExponentialHistogram: &metricpb.ExponentialHistogram{
+				AggregationTemporality: temporality(temporalitySelector.TemporalityFor(desc, telsdk.ExponentialHistogramKind)),
+				DataPoints: []*metricpb.ExponentialHistogramDataPoint{{
+					Attributes:        attrs,
+					StartTimeUnixNano: toNanos(r.StartTime()),
+					TimeUnixNano:      toNanos(r.EndTime()),
+					Count:             count,
+					Sum:               sum.CoerceToFloat64(desc.NumberKind()),
+					Scale:             h.Scale(),
+					ZeroCount:         h.ZeroCount(),
+					Positive:          exponentialBuckets(h.Positive()),
+					Negative:          exponentialBuckets(h.Negative()),
+				}},
+			},
+		}
+
+	case aggregation.SumKind:
+		s, ok := agg.(aggregation.Sum)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrIncompatibleAgg, agg)
+		}
+		sum, err := s.Sum()
+		if err != nil {
+			return nil, err
+		}
+		dp, err := numberDataPoint(desc.NumberKind(), sum)
+		if err != nil {
+			return nil, err
+		}
+		dp.Attributes = attrs
+		dp.StartTimeUnixNano = toNanos(r.StartTime())
+		dp.TimeUnixNano = toNanos(r.EndTime())
+		m.Data = &metricpb.Metric_Sum{
+			Sum: &metricpb.Sum{
+				AggregationTemporality: temporality(temporalitySelector.TemporalityFor(desc, aggregation.SumKind)),
+				IsMonotonic:            desc.InstrumentKind().Monotonic(),
+				DataPoints:             []*metricpb.NumberDataPoint{dp},
+			},
+		}
+
+	case aggregation.LastValueKind:
+		lv, ok := agg.(aggregation.LastValue)
+		if !ok {
+			return nil, fmt.Errorf("%w: %T", ErrIncompatibleAgg, agg)
+		}
+		value, tm, err := lv.LastValue()
+		if err != nil {
+			return nil, err
+		}
+		dp, err := numberDataPoint(desc.NumberKind(), value)
+		if err != nil {
+			return nil, err
+		}
+		dp.Attributes = attrs
+		dp.TimeUnixNano = toNanos(tm)
+		m.Data = &metricpb.Metric_Gauge{
+			Gauge: &metricpb.Gauge{
+				DataPoints: []*metricpb.NumberDataPoint{dp},
+			},
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnimplementedAgg, agg)
+	}
+	return m, nil
+}
+
+func exponentialBuckets(b telsdk.ExponentialBuckets) *metricpb.ExponentialHistogramDataPoint_Buckets {
+	counts := make([]uint64, b.Len())
+	for i := range counts {
+		counts[i] = b.At(uint32(i))
+	}
+	return &metricpb.ExponentialHistogramDataPoint_Buckets{
+		Offset:       b.Offset(),
+		BucketCounts: counts,
+	}
+}
+
+func numberDataPoint(kind number.Kind, n number.Number) (*metricpb.NumberDataPoint, error) {
+	switch kind {
+	case number.Int64Kind:
+		return &metricpb.NumberDataPoint{
+			Value: &metricpb.NumberDataPoint_AsInt{AsInt: n.AsInt64()},
+		}, nil
+	case number.Float64Kind:
+		return &metricpb.NumberDataPoint{
+			Value: &metricpb.NumberDataPoint_AsDouble{AsDouble: n.AsFloat64()},
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %v", ErrUnknownValueType, kind)
+}
+
+func temporality(t aggregation.Temporality) metricpb.AggregationTemporality {
+	switch t {
+	case aggregation.DeltaTemporality:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	case aggregation.CumulativeTemporality:
+		return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	}
+	return metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_UNSPECIFIED
+}
+
+// Metrics transforms OTLP ResourceMetrics back into a Resource and the
+// records of each instrumentation library.
+//
+// Instrument kinds are inferred from the OTLP data type, so that
+// exporters using a stateless temporality selector label the records with
+// the temporality they were encoded with: cumulative sums become observer
+// instruments and delta sums synchronous counters. Gauges become
+// GaugeObservers, and histograms and exponential histograms Float64
+// Histograms.
+func Metrics(rm *metricpb.ResourceMetrics) (*resource.Resource, []metricdata.Library, error) {
+	res := ResourceFromProto(rm.GetResource(), rm.GetSchemaUrl())
+	var libs []metricdata.Library
+	for _, sm := range rm.GetScopeMetrics() {
+		lib := metricdata.Library{
+			Library: InstrumentationLibrary(sm.GetScope(), sm.GetSchemaUrl()),
+		}
+		for _, m := range sm.GetMetrics() {
+			records, err := metricRecords(m)
+			if err != nil {
+				return res, libs, err
+			}
+			lib.Records = append(lib.Records, records...)
+		}
+		libs = append(libs, lib)
+	}
+	return res, libs, nil
+}
+
+func metricRecords(m *metricpb.Metric) ([]export.Record, error) {
+	var records []export.Record
+	switch data := m.Data.(type) {
+	case *metricpb.Metric_Gauge:
+		for _, dp := range data.Gauge.GetDataPoints() {
+			desc := descriptor(m, sdkapi.GaugeObserverInstrumentKind, numberKind(dp))
+			n := numberValue(dp)
+			records = append(records, newRecord(&desc, dp.GetAttributes(),
+				metricdata.NewLastValue(n, fromNanos(dp.GetTimeUnixNano())),
+				dp.GetStartTimeUnixNano(), dp.GetTimeUnixNano()))
+		}
+
+	case *metricpb.Metric_Sum:
+		ikind := sumInstrumentKind(data.Sum)
+		for _, dp := range data.Sum.GetDataPoints() {
+			desc := descriptor(m, ikind, numberKind(dp))
+			records = append(records, newRecord(&desc, dp.GetAttributes(),
+				metricdata.NewSum(numberValue(dp)),
+				dp.GetStartTimeUnixNano(), dp.GetTimeUnixNano()))
+		}
+
+	case *metricpb.Metric_Histogram:
+		desc := descriptor(m, sdkapi.HistogramInstrumentKind, number.Float64Kind)
+		for _, dp := range data.Histogram.GetDataPoints() {
+			buckets := aggregation.Buckets{
+				Boundaries: dp.GetExplicitBounds(),
+				Counts:     dp.GetBucketCounts(),
+			}
+			records = append(records, newRecord(&desc, dp.GetAttributes(),
+				metricdata.NewHistogram(dp.GetCount(), number.NewFloat64Number(dp.GetSum()), buckets),
+				dp.GetStartTimeUnixNano(), dp.GetTimeUnixNano()))
+		}
+
+	case *metricpb.Metric_ExponentialHistogram:
+		desc := descriptor(m, sdkapi.HistogramInstrumentKind, number.Float64Kind)
+		for _, dp := range data.ExponentialHistogram.GetDataPoints() {
+			agg := metricdata.NewExponentialHistogram(dp.GetCount(), number.NewFloat64Number(dp.GetSum()), dp.GetScale(), dp.GetZeroCount(),
+				metricdata.NewBuckets(dp.GetPositive().GetOffset(), dp.GetPositive().GetBucketCounts()),
+				metricdata.NewBuckets(dp.GetNegative().GetOffset(), dp.GetNegative().GetBucketCounts()))
+			records = append(records, newRecord(&desc, dp.GetAttributes(), agg,
+				dp.GetStartTimeUnixNano(), dp.GetTimeUnixNano()))
+		}
+
+	default:
+		return nil, fmt.Errorf("%w: %T", ErrUnimplementedAgg, m.Data)
+	}
+	return records, nil
+}
+
+func newRecord(desc *sdkapi.Descriptor, kvs []*commonpb.KeyValue, agg aggregation.Aggregation, start, end uint64) export.Record {
+	attrs := attribute.NewSet(Attributes(kvs)...)
+	return export.NewRecord(desc, &attrs, agg, fromNanos(start), fromNanos(end))
+}
+
+func descriptor(m *metricpb.Metric, ikind sdkapi.InstrumentKind, nkind number.Kind) sdkapi.Descriptor {
+	return sdkapi.NewDescriptor(m.GetName(), ikind, nkind, m.GetDescription(), unit.Unit(m.GetUnit()))
+}
+
+func sumInstrumentKind(s *metricpb.Sum) sdkapi.InstrumentKind {
+	cumulative := s.GetAggregationTemporality() == metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+	switch {
+	case s.GetIsMonotonic() && cumulative:
+		return sdkapi.CounterObserverInstrumentKind
+	case s.GetIsMonotonic():
+		return sdkapi.CounterInstrumentKind
+	case cumulative:
+		return sdkapi.UpDownCounterObserverInstrumentKind
+	default:
+		return sdkapi.UpDownCounterInstrumentKind
+	}
+}
+
+func numberKind(dp *metricpb.NumberDataPoint) number.Kind {
+	if _, ok := dp.GetValue().(*metricpb.NumberDataPoint_AsInt); ok {
+		return number.Int64Kind
+	}
+	return number.Float64Kind
+}
+
+func numberValue(dp *metricpb.NumberDataPoint) number.Number {
+	switch v := dp.GetValue().(type) {
+	case *metricpb.NumberDataPoint_AsInt:
+		return number.NewInt64Number(v.AsInt)
+	case *metricpb.NumberDataPoint_AsDouble:
+		return number.NewFloat64Number(v.AsDouble)
+	}
+	return number.NewFloat64Number(math.NaN())
+}
@@ -0,0 +1,132 @@
+package otlpconv
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	metricpb "go.opentelemetry.io/proto/otlp/metrics/v1"
+)
+
+func sum(temporality metricpb.AggregationTemporality, monotonic bool, points int) *metricpb.Metric {
+	return &metricpb.Metric{Name: "x", Data: &metricpb.Metric_Sum{Sum: &metricpb.Sum{
+		AggregationTemporality: temporality,
+		IsMonotonic:            monotonic,
+		DataPoints:             make([]*metricpb.NumberDataPoint, points),
+	}}}
+}
+
+func gauge(points int) *metricpb.Metric {
+	return &metricpb.Metric{Name: "x", Data: &metricpb.Metric_Gauge{Gauge: &metricpb.Gauge{
+		DataPoints: make([]*metricpb.NumberDataPoint, points),
+	}}}
+}
+
+func histogram(temporality metricpb.AggregationTemporality, points int) *metricpb.Metric {
+	return &metricpb.Metric{Name: "x", Data: &metricpb.Metric_Histogram{Histogram: &metricpb.Histogram{
+		AggregationTemporality: temporality,
+		DataPoints:             make([]*metricpb.HistogramDataPoint, points),
+	}}}
+}
+
+func TestMerge(t *testing.T) {
+	const (
+		cumulative = metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_CUMULATIVE
+		delta      = metricpb.AggregationTemporality_AGGREGATION_TEMPORALITY_DELTA
+	)
+	tests := []struct {
+		name     string
+		g, m     *metricpb.Metric
+		conflict bool
+		points   int
+	}{
+		{"sums", sum(cumulative, true, 1), sum(cumulative, true, 2), false, 3},
+		{"gauges", gauge(1), gauge(1), false, 2},
+		{"histograms", histogram(delta, 2), histogram(delta, 1), false, 3},
+		{"sum and gauge", sum(cumulative, true, 1), gauge(1), true, 1},
+		{"gauge and sum", gauge(1), sum(cumulative, false, 1), true, 1},
+		{"gauge and histogram", gauge(1), histogram(delta, 1), true, 1},
+		{"monotonicity", sum(cumulative, true, 1), sum(cumulative, false, 1), true, 1},
+		{"temporality", sum(cumulative, true, 1), sum(delta, true, 1), true, 1},
+		{"histogram temporality", histogram(cumulative, 1), histogram(delta, 1), true, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := merge(tt.g, tt.m)
+			if tt.conflict != errors.Is(err, ErrConflictingMetric) {
+				t.Errorf("merge() = %v, want a conflict: %v", err, tt.conflict)
+			}
+			var points int
+			switch data := tt.g.Data.(type) {
+			case *metricpb.Metric_Sum:
+				points = len(data.Sum.DataPoints)
+			case *metricpb.Metric_Gauge:
+				points = len(data.Gauge.DataPoints)
+			case *metricpb.Metric_Histogram:
+				points = len(data.Histogram.DataPoints)
+			}
+			if points != tt.points {
+				t.Errorf("got %d data points, want %d", points, tt.points)
+			}
+		})
+	}
+}
+
+func TestResourceMetricsRenameConflict(t *testing.T) {
+	views := []telsdk.View{
+		telsdk.NewView(telsdk.ViewMatchInstrumentName("requests"), telsdk.ViewWithName("x")),
+		telsdk.NewView(telsdk.ViewMatchInstrumentName("temperature"), telsdk.ViewWithName("x")),
+		telsdk.NewView(telsdk.ViewMatchInstrumentName("errors"), telsdk.ViewWithName("x")),
+	}
+	ctrl := telsdk.NewBasicController(telsdk.NewViewFactory(
+		telsdk.NewWithInexpensiveDistribution(), aggregation.CumulativeTemporalitySelector(), views))
+	meter := ctrl.Meter("m")
+	requests, err := meter.SyncInt64().Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := meter.SyncInt64().Counter("errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	temperature, err := meter.AsyncInt64().Gauge("temperature")
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = meter.RegisterCallback([]instrument.Asynchronous{temperature}, func(ctx context.Context) {
+		temperature.Observe(ctx, 20)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	requests.Add(context.Background(), 1)
+	errs.Add(context.Background(), 1)
+	if err := ctrl.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	rm, err := ResourceMetrics(aggregation.CumulativeTemporalitySelector(), ctrl.Resource(), ctrl)
+	if !errors.Is(err, ErrConflictingMetric) {
+		t.Errorf("ResourceMetrics() = %v, want %v", err, ErrConflictingMetric)
+	}
+	if rm == nil || len(rm.ScopeMetrics) != 1 || len(rm.ScopeMetrics[0].Metrics) != 1 {
+		t.Fatalf("got %v, want a single metric, dropping the conflicting one", rm)
+	}
+	// The records are in no particular order: the two counters are merged
+	// unless the gauge comes first.
+	switch m := rm.ScopeMetrics[0].Metrics[0]; data := m.Data.(type) {
+	case *metricpb.Metric_Sum:
+		if len(data.Sum.DataPoints) != 2 {
+			t.Errorf("got %d data points, want the two counters merged", len(data.Sum.DataPoints))
+		}
+	case *metricpb.Metric_Gauge:
+		if len(data.Gauge.DataPoints) != 1 {
+			t.Errorf("got %d data points, want the gauge alone", len(data.Gauge.DataPoints))
+		}
+	default:
+		t.Errorf("got %T, want a sum or a gauge", data)
+	}
+}
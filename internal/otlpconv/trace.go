@@ -0,0 +1,273 @@
+package otlpconv
+
+import (
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/instrumentation"
+	tracesdk "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	tracepb "go.opentelemetry.io/proto/otlp/trace/v1"
+)
+
+// Spans transforms a slice of OpenTelemetry spans into a slice of OTLP
+// ResourceSpans, grouped by resource and instrumentation library.
+func Spans(sdl []tracesdk.ReadOnlySpan) []*tracepb.ResourceSpans {
+	if len(sdl) == 0 {
+		return nil
+	}
+
+	type key struct {
+		r  attribute.Distinct
+		il instrumentation.Library
+	}
+	rsm := make(map[attribute.Distinct]*tracepb.ResourceSpans)
+	ssm := make(map[key]*tracepb.ScopeSpans)
+
+	// Keep the order spans were received in, so output is deterministic.
+	var rss []*tracepb.ResourceSpans
+	for _, sd := range sdl {
+		if sd == nil {
+			continue
+		}
+
+		rKey := sd.Resource().Equivalent()
+		rs, ok := rsm[rKey]
+		if !ok {
+			rs = &tracepb.ResourceSpans{
+				Resource:  Resource(sd.Resource()),
+				SchemaUrl: sd.Resource().SchemaURL(),
+			}
+			rsm[rKey] = rs
+			rss = append(rss, rs)
+		}
+
+		k := key{r: rKey, il: sd.InstrumentationLibrary()}
+		ss, ok := ssm[k]
+		if !ok {
+			ss = &tracepb.ScopeSpans{
+				Scope:     InstrumentationScope(sd.InstrumentationLibrary()),
+				SchemaUrl: sd.InstrumentationLibrary().SchemaURL,
+			}
+			ssm[k] = ss
+			rs.ScopeSpans = append(rs.ScopeSpans, ss)
+		}
+		ss.Spans = append(ss.Spans, Span(sd))
+	}
+	return rss
+}
+
+// Span transforms a single OpenTelemetry span into an OTLP span.
+func Span(sd tracesdk.ReadOnlySpan) *tracepb.Span {
+	if sd == nil {
+		return nil
+	}
+
+	tid := sd.SpanContext().TraceID()
+	sid := sd.SpanContext().SpanID()
+
+	s := &tracepb.Span{
+		TraceId:                tid[:],
+		SpanId:                 sid[:],
+		TraceState:             sd.SpanContext().TraceState().String(),
+		Status:                 status(sd.Status().Code, sd.Status().Description),
+		StartTimeUnixNano:      toNanos(sd.StartTime()),
+		EndTimeUnixNano:        toNanos(sd.EndTime()),
+		Links:                  links(sd.Links()),
+		Kind:                   spanKind(sd.SpanKind()),
+		Name:                   sd.Name(),
+		Attributes:             KeyValues(sd.Attributes()),
+		Events:                 spanEvents(sd.Events()),
+		DroppedAttributesCount: uint32(sd.DroppedAttributes()),
+		DroppedEventsCount:     uint32(sd.DroppedEvents()),
+		DroppedLinksCount:      uint32(sd.DroppedLinks()),
+	}
+
+	if psid := sd.Parent().SpanID(); psid.IsValid() {
+		s.ParentSpanId = psid[:]
+	}
+
+	return s
+}
+
+func status(status codes.Code, message string) *tracepb.Status {
+	var c tracepb.Status_StatusCode
+	switch status {
+	case codes.Ok:
+		c = tracepb.Status_STATUS_CODE_OK
+	case codes.Error:
+		c = tracepb.Status_STATUS_CODE_ERROR
+	default:
+		c = tracepb.Status_STATUS_CODE_UNSET
+	}
+	return &tracepb.Status{
+		Code:    c,
+		Message: message,
+	}
+}
+
+func links(links []tracesdk.Link) []*tracepb.Span_Link {
+	if len(links) == 0 {
+		return nil
+	}
+
+	sl := make([]*tracepb.Span_Link, 0, len(links))
+	for _, l := range links {
+		tid := l.SpanContext.TraceID()
+		sid := l.SpanContext.SpanID()
+
+		sl = append(sl, &tracepb.Span_Link{
+			TraceId:                tid[:],
+			SpanId:                 sid[:],
+			TraceState:             l.SpanContext.TraceState().String(),
+			Attributes:             KeyValues(l.Attributes),
+			DroppedAttributesCount: uint32(l.DroppedAttributeCount),
+		})
+	}
+	return sl
+}
+
+func spanEvents(es []tracesdk.Event) []*tracepb.Span_Event {
+	if len(es) == 0 {
+		return nil
+	}
+
+	events := make([]*tracepb.Span_Event, len(es))
+	for i := 0; i < len(es); i++ {
+		events[i] = &tracepb.Span_Event{
+			Name:                   es[i].Name,
+			TimeUnixNano:           toNanos(es[i].Time),
+			Attributes:             KeyValues(es[i].Attributes),
+			DroppedAttributesCount: uint32(es[i].DroppedAttributeCount),
+		}
+	}
+	return events
+}
+
+func spanKind(kind trace.SpanKind) tracepb.Span_SpanKind {
+	switch kind {
+	case trace.SpanKindInternal:
+		return tracepb.Span_SPAN_KIND_INTERNAL
+	case trace.SpanKindClient:
+		return tracepb.Span_SPAN_KIND_CLIENT
+	case trace.SpanKindServer:
+		return tracepb.Span_SPAN_KIND_SERVER
+	case trace.SpanKindProducer:
+		return tracepb.Span_SPAN_KIND_PRODUCER
+	case trace.SpanKindConsumer:
+		return tracepb.Span_SPAN_KIND_CONSUMER
+	default:
+		return tracepb.Span_SPAN_KIND_UNSPECIFIED
+	}
+}
+
+// SpanStubs transforms OTLP ResourceSpans back into span stubs, which can
+// be turned into ReadOnlySpans with their Snapshots method.
+func SpanStubs(rss []*tracepb.ResourceSpans) tracetest.SpanStubs {
+	var stubs tracetest.SpanStubs
+	for _, rs := range rss {
+		res := ResourceFromProto(rs.GetResource(), rs.GetSchemaUrl())
+		for _, ss := range rs.GetScopeSpans() {
+			lib := InstrumentationLibrary(ss.GetScope(), ss.GetSchemaUrl())
+			for _, s := range ss.GetSpans() {
+				stub := SpanStub(s)
+				stub.Resource = res
+				stub.InstrumentationLibrary = lib
+				stubs = append(stubs, stub)
+			}
+		}
+	}
+	return stubs
+}
+
+// SpanStub transforms an OTLP span into a span stub. The Resource and
+// InstrumentationLibrary fields are left for the caller to fill.
+func SpanStub(s *tracepb.Span) tracetest.SpanStub {
+	sc := spanContext(s.GetTraceId(), s.GetSpanId(), s.GetTraceState())
+	stub := tracetest.SpanStub{
+		Name:              s.GetName(),
+		SpanContext:       sc,
+		SpanKind:          spanKindFromProto(s.GetKind()),
+		StartTime:         fromNanos(s.GetStartTimeUnixNano()),
+		EndTime:           fromNanos(s.GetEndTimeUnixNano()),
+		Attributes:        Attributes(s.GetAttributes()),
+		Status:            statusFromProto(s.GetStatus()),
+		DroppedAttributes: int(s.GetDroppedAttributesCount()),
+		DroppedEvents:     int(s.GetDroppedEventsCount()),
+		DroppedLinks:      int(s.GetDroppedLinksCount()),
+	}
+	if len(s.GetParentSpanId()) != 0 {
+		stub.Parent = spanContext(s.GetTraceId(), s.GetParentSpanId(), "").WithRemote(true)
+	}
+	for _, e := range s.GetEvents() {
+		stub.Events = append(stub.Events, tracesdk.Event{
+			Name:                  e.GetName(),
+			Attributes:            Attributes(e.GetAttributes()),
+			DroppedAttributeCount: int(e.GetDroppedAttributesCount()),
+			Time:                  fromNanos(e.GetTimeUnixNano()),
+		})
+	}
+	for _, l := range s.GetLinks() {
+		stub.Links = append(stub.Links, tracesdk.Link{
+			SpanContext:           spanContext(l.GetTraceId(), l.GetSpanId(), l.GetTraceState()),
+			Attributes:            Attributes(l.GetAttributes()),
+			DroppedAttributeCount: int(l.GetDroppedAttributesCount()),
+		})
+	}
+	return stub
+}
+
+func spanContext(traceID, spanID []byte, traceState string) trace.SpanContext {
+	var cfg trace.SpanContextConfig
+	copy(cfg.TraceID[:], traceID)
+	copy(cfg.SpanID[:], spanID)
+	cfg.TraceFlags = trace.FlagsSampled
+	if ts, err := trace.ParseTraceState(traceState); err == nil {
+		cfg.TraceState = ts
+	}
+	return trace.NewSpanContext(cfg)
+}
+
+func spanKindFromProto(kind tracepb.Span_SpanKind) trace.SpanKind {
+	switch kind {
+	case tracepb.Span_SPAN_KIND_INTERNAL:
+		return trace.SpanKindInternal
+	case tracepb.Span_SPAN_KIND_CLIENT:
+		return trace.SpanKindClient
+	case tracepb.Span_SPAN_KIND_SERVER:
+		return trace.SpanKindServer
+	case tracepb.Span_SPAN_KIND_PRODUCER:
+		return trace.SpanKindProducer
+	case tracepb.Span_SPAN_KIND_CONSUMER:
+		return trace.SpanKindConsumer
+	default:
+		return trace.SpanKindUnspecified
+	}
+}
+
+func statusFromProto(s *tracepb.Status) tracesdk.Status {
+	switch s.GetCode() {
+	case tracepb.Status_STATUS_CODE_OK:
+		return tracesdk.Status{Code: codes.Ok, Description: s.GetMessage()}
+	case tracepb.Status_STATUS_CODE_ERROR:
+		return tracesdk.Status{Code: codes.Error, Description: s.GetMessage()}
+	default:
+		return tracesdk.Status{Code: codes.Unset, Description: s.GetMessage()}
+	}
+}
+
+func toNanos(t time.Time) uint64 {
+	if t.IsZero() {
+		return 0
+	}
+	return uint64(t.UnixNano())
+}
+
+func fromNanos(n uint64) time.Time {
+	if n == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, int64(n))
+}
@@ -0,0 +1,98 @@
+package otlpjson
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// idFields are the bytes fields OTLP/JSON encodes as hex strings instead
+// of the base64 used by the canonical protobuf JSON mapping.
+var idFields = map[string]bool{
+	"traceId":      true,
+	"spanId":       true,
+	"parentSpanId": true,
+}
+
+var (
+	marshalOptions   = protojson.MarshalOptions{UseEnumNumbers: true}
+	unmarshalOptions = protojson.UnmarshalOptions{DiscardUnknown: true}
+)
+
+// Marshal encodes m using the OTLP/JSON encoding, as a single line.
+//
+// It differs from the canonical protobuf JSON mapping in that trace and
+// span IDs are hex-encoded and enums are encoded as integers.
+func Marshal(m proto.Message) ([]byte, error) {
+	b, err := marshalOptions.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	return convertIDs(b, func(s string) (string, error) {
+		id, err := base64.StdEncoding.DecodeString(s)
+		return hex.EncodeToString(id), err
+	})
+}
+
+// Unmarshal decodes an OTLP/JSON encoded message into m. Unknown fields are
+// ignored.
+func Unmarshal(b []byte, m proto.Message) error {
+	b, err := convertIDs(b, func(s string) (string, error) {
+		id, err := hex.DecodeString(s)
+		return base64.StdEncoding.EncodeToString(id), err
+	})
+	if err != nil {
+		return err
+	}
+	return unmarshalOptions.Unmarshal(b, m)
+}
+
+func convertIDs(b []byte, conv func(string) (string, error)) ([]byte, error) {
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+	if err := walk(v, conv); err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	e := json.NewEncoder(&buf)
+	e.SetEscapeHTML(false)
+	if err := e.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+func walk(v interface{}, conv func(string) (string, error)) error {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		for k, f := range v {
+			if s, ok := f.(string); ok && idFields[k] {
+				id, err := conv(s)
+				if err != nil {
+					return fmt.Errorf("otlpjson: invalid %s %q: %w", k, s, err)
+				}
+				v[k] = id
+				continue
+			}
+			if err := walk(f, conv); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, f := range v {
+			if err := walk(f, conv); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
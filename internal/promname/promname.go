@@ -0,0 +1,21 @@
+package promname
+
+import "unicode"
+
+// Sanitize replaces the characters Prometheus doesn't allow in metric and
+// label names with underscores.
+func Sanitize(name string) string {
+	if name == "" {
+		return name
+	}
+	s := []rune(name)
+	for i, r := range s {
+		if r > unicode.MaxASCII || !(r == '_' || r == ':' || unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			s[i] = '_'
+		}
+	}
+	if unicode.IsDigit(s[0]) {
+		return "key_" + string(s)
+	}
+	return string(s)
+}
@@ -0,0 +1,210 @@
+package tel
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Severity is the severity of a log record, as the SeverityNumber of the
+// OpenTelemetry log data model. Larger values are more severe.
+type Severity int
+
+// Severities of the log data model. Each range has four values, for the
+// sources having finer levels, e.g. SeverityInfo2 is more severe than
+// SeverityInfo.
+const (
+	SeverityUndefined Severity = iota
+	SeverityTrace
+	SeverityTrace2
+	SeverityTrace3
+	SeverityTrace4
+	SeverityDebug
+	SeverityDebug2
+	SeverityDebug3
+	SeverityDebug4
+	SeverityInfo
+	SeverityInfo2
+	SeverityInfo3
+	SeverityInfo4
+	SeverityWarn
+	SeverityWarn2
+	SeverityWarn3
+	SeverityWarn4
+	SeverityError
+	SeverityError2
+	SeverityError3
+	SeverityError4
+	SeverityFatal
+	SeverityFatal2
+	SeverityFatal3
+	SeverityFatal4
+)
+
+var severityNames = [...]string{"TRACE", "DEBUG", "INFO", "WARN", "ERROR", "FATAL"}
+
+// String returns the short name of the severity, such as INFO or ERROR2.
+func (s Severity) String() string {
+	if s < SeverityTrace || s > SeverityFatal4 {
+		return "UNDEFINED"
+	}
+	name := severityNames[(s-SeverityTrace)/4]
+	if n := (s - SeverityTrace) % 4; n > 0 {
+		name += strconv.Itoa(int(n) + 1)
+	}
+	return name
+}
+
+// LogRecord is a log entry.
+type LogRecord struct {
+	// Timestamp is the time of the event. If zero, the time it was emitted
+	// is used.
+	Timestamp time.Time
+	// ObservedTimestamp is the time the record was emitted, set by the
+	// Logger.
+	ObservedTimestamp time.Time
+	Severity          Severity
+	// SeverityText is the level as known by the source, such as "warning".
+	SeverityText string
+	// Body and Attributes are AnyValues, which unlike the attributes of
+	// spans can hold byte slices, maps and arrays of mixed types.
+	Body       AnyValue
+	Attributes []AnyKeyValue
+
+	// TraceID, SpanID and TraceFlags correlate the record with a span. If
+	// unset, the Logger sets them from the span of the context.
+	TraceID    TraceID
+	SpanID     SpanID
+	TraceFlags trace.TraceFlags
+}
+
+// Logger emits log records.
+type Logger interface {
+	// Emit emits the record, correlating it with the span of ctx.
+	Emit(ctx context.Context, record LogRecord)
+}
+
+// LoggerProvider provides access to named Logger instances.
+type LoggerProvider interface {
+	Logger(name string, opts ...LoggerOption) Logger
+}
+
+// LoggerConfig is a group of options for a Logger.
+type LoggerConfig struct {
+	instrumentationVersion string
+	schemaURL              string
+}
+
+// InstrumentationVersion returns the version of the library providing
+// instrumentation.
+func (cfg LoggerConfig) InstrumentationVersion() string {
+	return cfg.instrumentationVersion
+}
+
+// SchemaURL returns the Schema URL of the telemetry emitted by the Logger.
+func (cfg LoggerConfig) SchemaURL() string {
+	return cfg.schemaURL
+}
+
+// LoggerOption applies an option to a LoggerConfig.
+type LoggerOption func(*LoggerConfig)
+
+// NewLoggerConfig applies all the options to a returned LoggerConfig.
+func NewLoggerConfig(opts ...LoggerOption) LoggerConfig {
+	var cfg LoggerConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return cfg
+}
+
+// LogWithInstrumentationVersion sets the instrumentation version.
+func LogWithInstrumentationVersion(version string) LoggerOption {
+	return func(cfg *LoggerConfig) {
+		cfg.instrumentationVersion = version
+	}
+}
+
+// LogWithSchemaURL sets the schema URL for the Logger.
+func LogWithSchemaURL(schemaURL string) LoggerOption {
+	return func(cfg *LoggerConfig) {
+		cfg.schemaURL = schemaURL
+	}
+}
+
+var (
+	loggerProviderMu sync.RWMutex
+	loggerProvider   LoggerProvider = noopLoggerProvider{}
+	// loggerProviderGen counts the calls to SetLoggerProvider, so global
+	// loggers know when to get a new logger. Comparing the providers
+	// instead would panic for those of uncomparable types.
+	loggerProviderGen uint64
+)
+
+// GetLoggerProvider returns the global LoggerProvider. If none has been
+// set, a No-Op LoggerProvider is returned.
+func GetLoggerProvider() LoggerProvider {
+	loggerProviderMu.RLock()
+	defer loggerProviderMu.RUnlock()
+	return loggerProvider
+}
+
+// SetLoggerProvider sets lp as the global LoggerProvider.
+func SetLoggerProvider(lp LoggerProvider) {
+	loggerProviderMu.Lock()
+	defer loggerProviderMu.Unlock()
+	loggerProvider = lp
+	loggerProviderGen++
+}
+
+func getLoggerProviderGen() (LoggerProvider, uint64) {
+	loggerProviderMu.RLock()
+	defer loggerProviderMu.RUnlock()
+	return loggerProvider, loggerProviderGen
+}
+
+// NewLogger creates a named logger using the global LoggerProvider. The
+// logger uses the provider set when emitting, so it can be created before
+// SetLoggerProvider is called.
+func NewLogger(name string, opts ...LoggerOption) Logger {
+	return &globalLogger{name: name, opts: opts}
+}
+
+type globalLogger struct {
+	name string
+	opts []LoggerOption
+
+	mu     sync.Mutex
+	gen    uint64
+	logger Logger
+}
+
+func (l *globalLogger) Emit(ctx context.Context, record LogRecord) {
+	lp, gen := getLoggerProviderGen()
+	l.mu.Lock()
+	if l.logger == nil || l.gen != gen {
+		l.gen, l.logger = gen, lp.Logger(l.name, l.opts...)
+	}
+	logger := l.logger
+	l.mu.Unlock()
+	logger.Emit(ctx, record)
+}
+
+// NewNoopLoggerProvider returns a LoggerProvider whose loggers drop the
+// records.
+func NewNoopLoggerProvider() LoggerProvider {
+	return noopLoggerProvider{}
+}
+
+type noopLoggerProvider struct{}
+
+func (noopLoggerProvider) Logger(string, ...LoggerOption) Logger {
+	return noopLogger{}
+}
+
+type noopLogger struct{}
+
+func (noopLogger) Emit(context.Context, LogRecord) {}
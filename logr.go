@@ -0,0 +1,209 @@
+package tel
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// LogrContextKey is the key LogrWithContext carries the context under in the
+// values of a logger.
+const LogrContextKey = "context"
+
+// LogrWithContext returns a logger carrying ctx in its values, for a
+// LogrSink to correlate the entries with the span of ctx.
+func LogrWithContext(logger logr.Logger, ctx context.Context) logr.Logger {
+	return logger.WithValues(LogrContextKey, ctx)
+}
+
+// LogrSink is a logr.LogSink correlating the entries with the span of the
+// context carried in the values of the logger, as added by LogrWithContext.
+// It adds the trace_id and span_id values to the entries logged with such a
+// context before passing them to the wrapped sink, and records the errors
+// passed to Error on the span as exception events. The context itself is
+// not passed to the wrapped sink.
+type LogrSink struct {
+	next  logr.LogSink
+	ctx   context.Context
+	attrs []KeyValue
+}
+
+var (
+	_ logr.LogSink          = (*LogrSink)(nil)
+	_ logr.CallDepthLogSink = (*LogrSink)(nil)
+)
+
+// NewLogrSink returns a LogrSink wrapping next, which should already be
+// initialized, such as the sink of an existing logger. The call depth of
+// next is adjusted for the frame of the LogrSink if it implements
+// logr.CallDepthLogSink.
+//
+//	logger := logr.New(tel.NewLogrSink(stdr.New(nil).GetSink()))
+//	tel.LogrWithContext(logger, ctx).Error(err, "request failed")
+//
+// To route the internal diagnostics of OpenTelemetry through it as well,
+// pass the logger to SetLogger:
+//
+//	tel.SetLogger(logger)
+func NewLogrSink(next logr.LogSink) *LogrSink {
+	if cd, ok := next.(logr.CallDepthLogSink); ok {
+		next = cd.WithCallDepth(1)
+	}
+	return &LogrSink{next: next}
+}
+
+// Init does nothing, as the wrapped sink is already initialized.
+func (s *LogrSink) Init(info logr.RuntimeInfo) {}
+
+// Enabled tests whether the wrapped sink is enabled for level.
+func (s *LogrSink) Enabled(level int) bool {
+	return s.next.Enabled(level)
+}
+
+// Info logs a non-error message to the wrapped sink.
+func (s *LogrSink) Info(level int, msg string, keysAndValues ...interface{}) {
+	ctx, keysAndValues := s.context(keysAndValues)
+	s.next.Info(level, msg, appendLogrSpanContext(keysAndValues, ctx)...)
+}
+
+// Error logs an error to the wrapped sink and records it on the span of the
+// context, with the message and the values as attributes of the event.
+func (s *LogrSink) Error(err error, msg string, keysAndValues ...interface{}) {
+	ctx, keysAndValues := s.context(keysAndValues)
+	if span := SpanFromContext(ctx); err != nil && span.IsRecording() {
+		attrs := make([]KeyValue, 0, len(s.attrs)+len(keysAndValues)/2+1)
+		attrs = append(attrs, AttributeString("log.message", msg))
+		attrs = append(attrs, s.attrs...)
+		attrs = appendLogrValues(attrs, keysAndValues)
+		span.RecordError(err, WithAttributes(attrs...))
+	}
+	s.next.Error(err, msg, appendLogrSpanContext(keysAndValues, ctx)...)
+}
+
+// WithValues returns a LogrSink with the values, keeping the context out of
+// the wrapped sink.
+func (s *LogrSink) WithValues(keysAndValues ...interface{}) logr.LogSink {
+	ctx, kvs := splitLogrContext(keysAndValues)
+	s2 := *s
+	if ctx != nil {
+		s2.ctx = ctx
+	}
+	if len(kvs) > 0 {
+		s2.next = s.next.WithValues(kvs...)
+		s2.attrs = appendLogrValues(s.attrs[:len(s.attrs):len(s.attrs)], kvs)
+	}
+	return &s2
+}
+
+// WithName returns a LogrSink whose wrapped sink has the name appended.
+func (s *LogrSink) WithName(name string) logr.LogSink {
+	s2 := *s
+	s2.next = s.next.WithName(name)
+	return &s2
+}
+
+// WithCallDepth returns a LogrSink whose wrapped sink skips depth more
+// frames, if it supports it.
+func (s *LogrSink) WithCallDepth(depth int) logr.LogSink {
+	next, ok := s.next.(logr.CallDepthLogSink)
+	if !ok {
+		return s
+	}
+	s2 := *s
+	s2.next = next.WithCallDepth(depth)
+	return &s2
+}
+
+// context returns the context of the entry, which may be passed with the
+// values of the call, and the values without it.
+func (s *LogrSink) context(keysAndValues []interface{}) (context.Context, []interface{}) {
+	ctx, kvs := splitLogrContext(keysAndValues)
+	if ctx == nil {
+		ctx = s.ctx
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	return ctx, kvs
+}
+
+// splitLogrContext returns the last context in keysAndValues and the
+// values without the contexts.
+func splitLogrContext(keysAndValues []interface{}) (context.Context, []interface{}) {
+	var ctx context.Context
+	for i := 1; i < len(keysAndValues); i += 2 {
+		if c, ok := keysAndValues[i].(context.Context); ok {
+			ctx = c
+		}
+	}
+	if ctx == nil {
+		return nil, keysAndValues
+	}
+	kvs := make([]interface{}, 0, len(keysAndValues))
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if i+1 == len(keysAndValues) {
+			// Keep a dangling key for the wrapped sink to report.
+			kvs = append(kvs, keysAndValues[i])
+			break
+		}
+		if _, ok := keysAndValues[i+1].(context.Context); !ok {
+			kvs = append(kvs, keysAndValues[i], keysAndValues[i+1])
+		}
+	}
+	return ctx, kvs
+}
+
+func appendLogrSpanContext(keysAndValues []interface{}, ctx context.Context) []interface{} {
+	sc := SpanContextFromContext(ctx)
+	if !sc.IsValid() {
+		return keysAndValues
+	}
+	// Keep a dangling key last, for the wrapped sink to report.
+	n := len(keysAndValues) &^ 1
+	kvs := make([]interface{}, 0, len(keysAndValues)+4)
+	kvs = append(kvs, keysAndValues[:n]...)
+	kvs = append(kvs, "trace_id", sc.TraceID().String(), "span_id", sc.SpanID().String())
+	return append(kvs, keysAndValues[n:]...)
+}
+
+// appendLogrValues appends the key/value pairs to attrs as attributes.
+// Pairs whose key isn't a string are dropped.
+func appendLogrValues(attrs []KeyValue, keysAndValues []interface{}) []KeyValue {
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		k, ok := keysAndValues[i].(string)
+		if !ok {
+			continue
+		}
+		attrs = append(attrs, KeyValue{Key: Key(k), Value: logrValue(keysAndValues[i+1])})
+	}
+	return attrs
+}
+
+func logrValue(v interface{}) Value {
+	if m, ok := v.(logr.Marshaler); ok {
+		v = m.MarshalLog()
+	}
+	switch v := v.(type) {
+	case bool:
+		return BoolValue(v)
+	case int:
+		return IntValue(v)
+	case int64:
+		return Int64Value(v)
+	case int32:
+		return Int64Value(int64(v))
+	case float64:
+		return Float64Value(v)
+	case string:
+		return StringValue(v)
+	case time.Duration:
+		return Int64Value(int64(v))
+	case error:
+		return StringValue(v.Error())
+	case fmt.Stringer:
+		return StringValue(v.String())
+	}
+	return StringValue(fmt.Sprint(v))
+}
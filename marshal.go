@@ -0,0 +1,411 @@
+package tel
+
+import (
+	"encoding/base64"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// AttributeMarshaler is implemented by types encoding themselves as
+// attributes, such as with generated code, instead of by reflection. The
+// keys are qualified with the prefix of the field holding the value.
+type AttributeMarshaler interface {
+	MarshalAttributes() []KeyValue
+}
+
+type marshalConfig struct {
+	prefix      string
+	valueLength int
+}
+
+// MarshalOption applies an option to MarshalAttributes.
+type MarshalOption func(*marshalConfig)
+
+// MarshalWithPrefix qualifies the keys with prefix, followed by a dot.
+func MarshalWithPrefix(prefix string) MarshalOption {
+	return func(cfg *marshalConfig) {
+		cfg.prefix = prefix
+	}
+}
+
+// MarshalWithValueLengthLimit truncates the string values, including the
+// elements of string slices, to n characters, as the AttributeValueLengthLimit
+// of SpanLimits. A negative n means no limit, which is the default.
+func MarshalWithValueLengthLimit(n int) MarshalOption {
+	return func(cfg *marshalConfig) {
+		cfg.valueLength = n
+	}
+}
+
+// MarshalAttributes encodes a struct, or a pointer to one, as attributes.
+//
+// The exported fields are encoded, keyed by their name unless overridden by
+// a tag such as `tel:"http.method"`. The "omitempty" option omits the field
+// if it has the zero value of its type, as in `tel:"user.id,omitempty"`,
+// and a field tagged `tel:"-"` is ignored. Fields of embedded structs
+// without a tag, or of pointers to them, are promoted as with
+// encoding/json; those of a nil embedded pointer are omitted.
+//
+// Values are encoded by the first rule matching them:
+//   - nil pointers and interfaces are omitted;
+//   - AttributeMarshaler values contribute their own attributes;
+//   - time.Time is encoded as a string in the time.RFC3339Nano format;
+//   - time.Duration, and any other fmt.Stringer, as its String;
+//   - booleans, integers, floats and strings as the matching Value type,
+//     with uint64 values above math.MaxInt64 encoded as strings;
+//   - []byte as a base64 string;
+//   - slices and arrays of the above as the matching slice Value type;
+//   - structs, slices of structs and maps with string keys are flattened,
+//     with the field names, indexes or map keys added to the key after a
+//     dot, as in "request.headers.accept".
+//
+// Other values are encoded with fmt.Sprint. A pointer, map or slice
+// holding itself is encoded until it is reached again, where it is
+// omitted.
+func MarshalAttributes(v interface{}, opts ...MarshalOption) []KeyValue {
+	cfg := marshalConfig{valueLength: -1}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	e := attributeEncoder{valueLength: cfg.valueLength}
+	e.encode(cfg.prefix, reflect.ValueOf(v))
+	return e.kvs
+}
+
+type attributeEncoder struct {
+	kvs         []KeyValue
+	valueLength int
+	// seen holds the pointers, maps and slices being encoded, to stop at
+	// cycles.
+	seen map[seenKey]struct{}
+}
+
+type seenKey struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+// enter marks v as being encoded, returning false if it already is.
+func (e *attributeEncoder) enter(v reflect.Value) (seenKey, bool) {
+	k := seenKey{ptr: v.Pointer(), typ: v.Type()}
+	if v.Kind() == reflect.Slice {
+		k.len = v.Len()
+	}
+	if _, ok := e.seen[k]; ok {
+		return k, false
+	}
+	if e.seen == nil {
+		e.seen = map[seenKey]struct{}{}
+	}
+	e.seen[k] = struct{}{}
+	return k, true
+}
+
+var (
+	attributeMarshalerType = reflect.TypeOf((*AttributeMarshaler)(nil)).Elem()
+	stringerType           = reflect.TypeOf((*fmt.Stringer)(nil)).Elem()
+	timeType               = reflect.TypeOf(time.Time{})
+)
+
+func joinKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	if name == "" {
+		return prefix
+	}
+	return prefix + "." + name
+}
+
+func (e *attributeEncoder) encode(key string, v reflect.Value) {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return
+		}
+		if v.Kind() == reflect.Ptr {
+			k, ok := e.enter(v)
+			if !ok {
+				return
+			}
+			defer delete(e.seen, k)
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return
+	}
+	if v.Type().Implements(attributeMarshalerType) && v.CanInterface() {
+		for _, kv := range v.Interface().(AttributeMarshaler).MarshalAttributes() {
+			e.add(joinKey(key, string(kv.Key)), kv.Value)
+		}
+		return
+	}
+	if value, ok := e.scalar(v); ok {
+		e.add(key, value)
+		return
+	}
+	switch v.Kind() {
+	case reflect.Map, reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		k, ok := e.enter(v)
+		if !ok {
+			return
+		}
+		defer delete(e.seen, k)
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		e.encodeStruct(key, v)
+	case reflect.Slice, reflect.Array:
+		if value, ok := e.slice(v); ok {
+			e.add(key, value)
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			e.encode(joinKey(key, strconv.Itoa(i)), v.Index(i))
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			e.add(key, StringValue(fmt.Sprint(v.Interface())))
+			return
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool { return keys[i].String() < keys[j].String() })
+		for _, k := range keys {
+			e.encode(joinKey(key, k.String()), v.MapIndex(k))
+		}
+	default:
+		if v.CanInterface() {
+			e.add(key, StringValue(fmt.Sprint(v.Interface())))
+		}
+	}
+}
+
+func (e *attributeEncoder) encodeStruct(prefix string, v reflect.Value) {
+	for _, f := range cachedFields(v.Type()) {
+		fv, ok := fieldByIndex(v, f.index)
+		if !ok {
+			continue
+		}
+		if f.omitEmpty && fv.IsZero() {
+			continue
+		}
+		e.encode(joinKey(prefix, f.name), fv)
+	}
+}
+
+// fieldByIndex returns the field of v at index, or false if it is in a
+// nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// scalar encodes v as a single Value, if it isn't a container.
+func (e *attributeEncoder) scalar(v reflect.Value) (Value, bool) {
+	t := v.Type()
+	if t == timeType && v.CanInterface() {
+		return StringValue(v.Interface().(time.Time).Format(time.RFC3339Nano)), true
+	}
+	if t.Implements(stringerType) && v.CanInterface() {
+		return StringValue(v.Interface().(fmt.Stringer).String()), true
+	}
+	switch v.Kind() {
+	case reflect.Bool:
+		return BoolValue(v.Bool()), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return Int64Value(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if u := v.Uint(); u <= 1<<63-1 {
+			return Int64Value(int64(u)), true
+		}
+		return StringValue(strconv.FormatUint(v.Uint(), 10)), true
+	case reflect.Float32, reflect.Float64:
+		return Float64Value(v.Float()), true
+	case reflect.String:
+		return StringValue(v.String()), true
+	case reflect.Slice:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return StringValue(base64.StdEncoding.EncodeToString(v.Bytes())), true
+		}
+	}
+	return Value{}, false
+}
+
+// slice encodes v as a slice Value, if its elements are all of the same
+// scalar type.
+func (e *attributeEncoder) slice(v reflect.Value) (Value, bool) {
+	et := v.Type().Elem()
+	if et.Kind() == reflect.Ptr || et.Kind() == reflect.Interface ||
+		et.Implements(attributeMarshalerType) {
+		return Value{}, false
+	}
+	n := v.Len()
+	switch {
+	case et == timeType, et.Implements(stringerType):
+		s := make([]string, 0, n)
+		for i := 0; i < n; i++ {
+			value, _ := e.scalar(v.Index(i))
+			s = append(s, value.AsString())
+		}
+		return StringSliceValue(s), true
+	}
+	switch et.Kind() {
+	case reflect.Bool:
+		s := make([]bool, n)
+		for i := range s {
+			s[i] = v.Index(i).Bool()
+		}
+		return BoolSliceValue(s), true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		s := make([]int64, n)
+		for i := range s {
+			s[i] = v.Index(i).Int()
+		}
+		return Int64SliceValue(s), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		s := make([]int64, n)
+		for i := range s {
+			u := v.Index(i).Uint()
+			if u > 1<<63-1 {
+				return Value{}, false
+			}
+			s[i] = int64(u)
+		}
+		return Int64SliceValue(s), true
+	case reflect.Float32, reflect.Float64:
+		s := make([]float64, n)
+		for i := range s {
+			s[i] = v.Index(i).Float()
+		}
+		return Float64SliceValue(s), true
+	case reflect.String:
+		s := make([]string, n)
+		for i := range s {
+			s[i] = v.Index(i).String()
+		}
+		return StringSliceValue(s), true
+	}
+	return Value{}, false
+}
+
+func (e *attributeEncoder) add(key string, v Value) {
+	if key == "" {
+		return
+	}
+	if e.valueLength >= 0 {
+		v = truncateValue(v, e.valueLength)
+	}
+	e.kvs = append(e.kvs, KeyValue{Key: Key(key), Value: v})
+}
+
+// truncateValue truncates the strings of v to n characters.
+func truncateValue(v Value, n int) Value {
+	switch v.Type() {
+	case STRING:
+		if s := v.AsString(); utf8.RuneCountInString(s) > n {
+			return StringValue(truncateString(s, n))
+		}
+	case STRINGSLICE:
+		s := v.AsStringSlice()
+		var truncated bool
+		for i := range s {
+			if utf8.RuneCountInString(s[i]) > n {
+				s[i], truncated = truncateString(s[i], n), true
+			}
+		}
+		if truncated {
+			return StringSliceValue(s)
+		}
+	}
+	return v
+}
+
+func truncateString(s string, n int) string {
+	var i int
+	for j := range s {
+		if i == n {
+			return s[:j]
+		}
+		i++
+	}
+	return s
+}
+
+type encodedField struct {
+	name      string
+	index     []int
+	omitEmpty bool
+}
+
+var fieldCache sync.Map // reflect.Type -> []encodedField
+
+func cachedFields(t reflect.Type) []encodedField {
+	if f, ok := fieldCache.Load(t); ok {
+		return f.([]encodedField)
+	}
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t, nil, map[reflect.Type]bool{t: true}))
+	return f.([]encodedField)
+}
+
+// typeFields lists the fields of t to encode, promoting the fields of the
+// untagged embedded structs. Structs in visited, those embedding t, are not
+// promoted again.
+func typeFields(t reflect.Type, index []int, visited map[reflect.Type]bool) []encodedField {
+	var fields []encodedField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, hasTag := sf.Tag.Lookup("tel")
+		if tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		idx := append(index[:len(index):len(index)], i)
+		if sf.Anonymous && !hasTag {
+			ft := sf.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct && ft != timeType &&
+				!ft.Implements(attributeMarshalerType) && !ft.Implements(stringerType) &&
+				!reflect.PtrTo(ft).Implements(attributeMarshalerType) {
+				if visited[ft] {
+					continue
+				}
+				visited[ft] = true
+				fields = append(fields, typeFields(ft, idx, visited)...)
+				delete(visited, ft)
+				continue
+			}
+		}
+		if !sf.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = sf.Name
+		}
+		fields = append(fields, encodedField{
+			name:      name,
+			index:     idx,
+			omitEmpty: opts == "omitempty",
+		})
+	}
+	return fields
+}
@@ -0,0 +1,204 @@
+//go:build go1.21
+
+package tel
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"time"
+)
+
+// Keys of the attributes SlogHandler adds to the records logged in the
+// context of a span.
+const (
+	SlogTraceIDKey    = "trace_id"
+	SlogSpanIDKey     = "span_id"
+	SlogTraceFlagsKey = "trace_flags"
+)
+
+type slogHandlerConfig struct {
+	eventLevel  slog.Leveler
+	errorStatus bool
+}
+
+// SlogHandlerOption applies an option to a SlogHandler.
+type SlogHandlerOption func(*slogHandlerConfig)
+
+// SlogWithSpanEvents mirrors the records at or above level onto the span of
+// the context as events named after the message, carrying the attributes of
+// the record. Records below the level the wrapped handler is enabled for are
+// still mirrored.
+func SlogWithSpanEvents(level slog.Leveler) SlogHandlerOption {
+	return func(cfg *slogHandlerConfig) {
+		cfg.eventLevel = level
+	}
+}
+
+// SlogWithErrorStatus sets the status of the span of the context to Error,
+// described by the message, when a record at or above slog.LevelError is
+// logged.
+func SlogWithErrorStatus() SlogHandlerOption {
+	return func(cfg *slogHandlerConfig) {
+		cfg.errorStatus = true
+	}
+}
+
+// SlogHandler is a slog.Handler correlating the records with the span of
+// the context they are logged with. It adds the trace_id, span_id and
+// trace_flags attributes to every record logged in the context of a valid
+// span before passing it to the wrapped handler. Like the other attributes
+// of the record, they are qualified by the groups opened with WithGroup.
+type SlogHandler struct {
+	next slog.Handler
+	cfg  slogHandlerConfig
+
+	// attrs and group are the attributes and group prefix added with
+	// WithAttrs and WithGroup, kept for the span events.
+	attrs []KeyValue
+	group string
+}
+
+var _ slog.Handler = (*SlogHandler)(nil)
+
+// NewSlogHandler returns a SlogHandler wrapping next.
+//
+//	logger := slog.New(tel.NewSlogHandler(slog.NewJSONHandler(os.Stderr, nil),
+//		tel.SlogWithSpanEvents(slog.LevelWarn),
+//		tel.SlogWithErrorStatus()))
+//	logger.InfoContext(ctx, "hello")
+func NewSlogHandler(next slog.Handler, opts ...SlogHandlerOption) *SlogHandler {
+	h := &SlogHandler{next: next}
+	for _, opt := range opts {
+		opt(&h.cfg)
+	}
+	return h
+}
+
+// Enabled reports whether either the wrapped handler handles records at
+// level or the records at level are mirrored onto the span.
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level) || h.spanEnabled(level)
+}
+
+func (h *SlogHandler) spanEnabled(level slog.Level) bool {
+	return (h.cfg.eventLevel != nil && level >= h.cfg.eventLevel.Level()) ||
+		(h.cfg.errorStatus && level >= slog.LevelError)
+}
+
+// Handle passes the record, stamped with the span context of ctx, to the
+// wrapped handler and records it on the span of ctx.
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	if h.spanEnabled(r.Level) {
+		if span := SpanFromContext(ctx); span.IsRecording() {
+			h.recordOnSpan(span, r)
+		}
+	}
+	if !h.next.Enabled(ctx, r.Level) {
+		return nil
+	}
+	if sc := SpanContextFromContext(ctx); sc.IsValid() {
+		r = r.Clone()
+		r.AddAttrs(
+			slog.String(SlogTraceIDKey, sc.TraceID().String()),
+			slog.String(SlogSpanIDKey, sc.SpanID().String()),
+			slog.String(SlogTraceFlagsKey, sc.TraceFlags().String()),
+		)
+	}
+	return h.next.Handle(ctx, r)
+}
+
+func (h *SlogHandler) recordOnSpan(span Span, r slog.Record) {
+	if h.cfg.eventLevel != nil && r.Level >= h.cfg.eventLevel.Level() {
+		attrs := make([]KeyValue, 0, len(h.attrs)+r.NumAttrs()+1)
+		attrs = append(attrs, AttributeString("log.severity", r.Level.String()))
+		attrs = append(attrs, h.attrs...)
+		r.Attrs(func(a slog.Attr) bool {
+			attrs = appendSlogAttr(attrs, h.group, a)
+			return true
+		})
+		opts := []EventOption{WithAttributes(attrs...)}
+		if !r.Time.IsZero() {
+			opts = append(opts, WithTimestamp(r.Time))
+		}
+		span.AddEvent(r.Message, opts...)
+	}
+	if h.cfg.errorStatus && r.Level >= slog.LevelError {
+		span.SetStatus(Error, r.Message)
+	}
+}
+
+// WithAttrs returns a SlogHandler whose wrapped handler has the attributes.
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	h2 := *h
+	h2.next = h.next.WithAttrs(attrs)
+	h2.attrs = h.attrs[:len(h.attrs):len(h.attrs)]
+	for _, a := range attrs {
+		h2.attrs = appendSlogAttr(h2.attrs, h.group, a)
+	}
+	return &h2
+}
+
+// WithGroup returns a SlogHandler whose wrapped handler has the group.
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.next = h.next.WithGroup(name)
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+// appendSlogAttr appends a to kvs, flattening the groups into dotted keys.
+func appendSlogAttr(kvs []KeyValue, prefix string, a slog.Attr) []KeyValue {
+	v := a.Value.Resolve()
+	if v.Kind() == slog.KindGroup {
+		group := v.Group()
+		if len(group) == 0 {
+			return kvs
+		}
+		if a.Key != "" {
+			prefix += a.Key + "."
+		}
+		for _, ga := range group {
+			kvs = appendSlogAttr(kvs, prefix, ga)
+		}
+		return kvs
+	}
+	if a.Key == "" {
+		return kvs
+	}
+	return append(kvs, KeyValue{Key: Key(prefix + a.Key), Value: slogValue(v)})
+}
+
+func slogValue(v slog.Value) Value {
+	switch v.Kind() {
+	case slog.KindBool:
+		return BoolValue(v.Bool())
+	case slog.KindInt64:
+		return Int64Value(v.Int64())
+	case slog.KindUint64:
+		if u := v.Uint64(); u <= math.MaxInt64 {
+			return Int64Value(int64(u))
+		}
+	case slog.KindFloat64:
+		return Float64Value(v.Float64())
+	case slog.KindDuration:
+		return Int64Value(int64(v.Duration()))
+	case slog.KindTime:
+		return StringValue(v.Time().Format(time.RFC3339Nano))
+	case slog.KindAny:
+		switch a := v.Any().(type) {
+		case error:
+			return StringValue(a.Error())
+		case fmt.Stringer:
+			return StringValue(a.String())
+		}
+	}
+	return StringValue(v.String())
+}
@@ -0,0 +1,79 @@
+package tel
+
+import (
+	"context"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// EndFunc ends the span started by Start. It records the error err points
+// to, if any, and sets the status of the span accordingly. A nil pointer is
+// treated as no error.
+type EndFunc func(err *error)
+
+// Start starts a span with a tracer named after the package of the caller,
+// returning a context with the span and the function to end it with.
+//
+//	func (s *Store) Get(ctx context.Context, key string) (v []byte, err error) {
+//		ctx, end := tel.Start(ctx, "Store.Get")
+//		defer end(&err)
+//		// ...
+//	}
+//
+// If the function panics, the deferred end records the panic on the span
+// with a stack trace, sets the status to Error, ends the span and panics
+// again with the same value. Otherwise, it records a non-nil error with
+// RecordException, or sets the status to OK.
+func Start(ctx context.Context, name string, opts ...SpanStartOption) (context.Context, EndFunc) {
+	ctx, span := callerTracer().Start(ctx, name, opts...)
+	return ctx, endSpan(span)
+}
+
+func endSpan(span Span) EndFunc {
+	return func(err *error) {
+		if r := recover(); r != nil {
+			span.RecordError(fmt.Errorf("panic: %v", r),
+				WithAttributes(AttributeBool("exception.escaped", true)),
+				WithStackTrace(true))
+			span.SetStatus(Error, fmt.Sprint(r))
+			span.End()
+			panic(r)
+		}
+		if err != nil && *err != nil {
+			RecordException(span, *err)
+		} else {
+			span.SetStatus(OK, "")
+		}
+		span.End()
+	}
+}
+
+var callerTracers sync.Map // package path -> Tracer
+
+// callerTracer returns the tracer named after the package of the caller of
+// its caller.
+func callerTracer() Tracer {
+	pkg := "github.com/henvic/tel"
+	if pc, _, _, ok := runtime.Caller(2); ok {
+		if fn := runtime.FuncForPC(pc); fn != nil {
+			pkg = funcPackage(fn.Name())
+		}
+	}
+	if t, ok := callerTracers.Load(pkg); ok {
+		return t.(Tracer)
+	}
+	t, _ := callerTracers.LoadOrStore(pkg, NewTracer(pkg))
+	return t.(Tracer)
+}
+
+// funcPackage returns the package path of a function name as reported by
+// runtime.Func, such as example.com/foo.(*T).Method.
+func funcPackage(name string) string {
+	slash := strings.LastIndexByte(name, '/')
+	if dot := strings.IndexByte(name[slash+1:], '.'); dot >= 0 {
+		return name[:slash+1+dot]
+	}
+	return name
+}
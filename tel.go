@@ -54,7 +54,9 @@ func Handle(err error) {
 	otel.GetErrorHandler().Handle(err)
 }
 
-// SetLogger configures the logger used internally to opentelemetry.
+// SetLogger configures the logger used internally to opentelemetry. Pass a
+// logger using a LogrSink, as logr.New(tel.NewLogrSink(sink)), to route it
+// through the sink of the application logger instead.
 func SetLogger(logger logr.Logger) {
 	otel.SetLogger(logger)
 }
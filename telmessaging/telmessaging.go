@@ -0,0 +1,268 @@
+// Package telmessaging instruments message producers and consumers,
+// propagating the context of the producer through the headers of the
+// messages and creating spans with the messaging semantic conventions.
+package telmessaging
+
+import (
+	"context"
+
+	"github.com/henvic/tel"
+	semconv "go.opentelemetry.io/otel/semconv/v1.7.0"
+)
+
+const instrumentationName = "github.com/henvic/tel/telmessaging"
+
+// MessageCarrier gives access to the headers of a message. tel.MapCarrier
+// and tel.HeaderCarrier satisfy it.
+type MessageCarrier interface {
+	// Get returns the value of the header key, or "" if unset.
+	Get(key string) string
+	// Set sets the header key to value.
+	Set(key, value string)
+}
+
+// textMapCarrier adapts a MessageCarrier to a tel.TextMapCarrier. Keys
+// returns the keys of the carrier if it has a Keys method, as the
+// propagators of tel don't need them.
+type textMapCarrier struct {
+	MessageCarrier
+}
+
+func (c textMapCarrier) Keys() []string {
+	if k, ok := c.MessageCarrier.(interface{ Keys() []string }); ok {
+		return k.Keys()
+	}
+	return nil
+}
+
+// Inject sets the context of ctx in the headers of a message, using the
+// global propagator.
+func Inject(ctx context.Context, carrier MessageCarrier) {
+	tel.GetTextMapPropagator().Inject(ctx, textMapCarrier{carrier})
+}
+
+// Extract returns a copy of ctx with the context set in the headers of a
+// message, using the global propagator.
+func Extract(ctx context.Context, carrier MessageCarrier) context.Context {
+	return tel.GetTextMapPropagator().Extract(ctx, textMapCarrier{carrier})
+}
+
+type config struct {
+	tracerProvider tel.TracerProvider
+	propagator     tel.TextMapPropagator
+	attributes     []tel.KeyValue
+	destination    string
+	temporary      bool
+}
+
+// Option applies an option to a Tracer.
+type Option func(*config)
+
+// WithTracerProvider sets the TracerProvider of the spans. The default is
+// the global TracerProvider.
+func WithTracerProvider(tp tel.TracerProvider) Option {
+	return func(cfg *config) {
+		cfg.tracerProvider = tp
+	}
+}
+
+// WithPropagator sets the propagator of the context through the headers.
+// The default is the global propagator.
+func WithPropagator(p tel.TextMapPropagator) Option {
+	return func(cfg *config) {
+		cfg.propagator = p
+	}
+}
+
+// WithSystem sets the messaging.system attribute, identifying the messaging
+// system, such as kafka or rabbitmq.
+func WithSystem(system string) Option {
+	return func(cfg *config) {
+		cfg.attributes = append(cfg.attributes, semconv.MessagingSystemKey.String(system))
+	}
+}
+
+// WithDestination sets the messaging.destination attribute, the name of
+// the queue or topic, which also names the spans.
+func WithDestination(name string) Option {
+	return func(cfg *config) {
+		cfg.destination = name
+		cfg.attributes = append(cfg.attributes, semconv.MessagingDestinationKey.String(name))
+	}
+}
+
+// WithQueue sets the messaging.destination_kind attribute to queue.
+func WithQueue() Option {
+	return func(cfg *config) {
+		cfg.attributes = append(cfg.attributes, semconv.MessagingDestinationKindQueue)
+	}
+}
+
+// WithTopic sets the messaging.destination_kind attribute to topic.
+func WithTopic() Option {
+	return func(cfg *config) {
+		cfg.attributes = append(cfg.attributes, semconv.MessagingDestinationKindTopic)
+	}
+}
+
+// WithTemporaryDestination sets the messaging.temp_destination attribute,
+// for destinations that only exist as long as the application using them.
+func WithTemporaryDestination() Option {
+	return func(cfg *config) {
+		cfg.temporary = true
+		cfg.attributes = append(cfg.attributes, semconv.MessagingTempDestinationKey.Bool(true))
+	}
+}
+
+// WithProtocol sets the messaging.protocol and messaging.protocol_version
+// attributes.
+func WithProtocol(name, version string) Option {
+	return func(cfg *config) {
+		cfg.attributes = append(cfg.attributes, semconv.MessagingProtocolKey.String(name))
+		if version != "" {
+			cfg.attributes = append(cfg.attributes, semconv.MessagingProtocolVersionKey.String(version))
+		}
+	}
+}
+
+// WithAttributes adds attributes to every span.
+func WithAttributes(attributes ...tel.KeyValue) Option {
+	return func(cfg *config) {
+		cfg.attributes = append(cfg.attributes, attributes...)
+	}
+}
+
+type messageConfig struct {
+	attributes []tel.KeyValue
+}
+
+// MessageOption applies an option to the span of a message.
+type MessageOption func(*messageConfig)
+
+// WithMessageID sets the messaging.message_id attribute.
+func WithMessageID(id string) MessageOption {
+	return func(cfg *messageConfig) {
+		cfg.attributes = append(cfg.attributes, semconv.MessagingMessageIDKey.String(id))
+	}
+}
+
+// WithConversationID sets the messaging.conversation_id attribute.
+func WithConversationID(id string) MessageOption {
+	return func(cfg *messageConfig) {
+		cfg.attributes = append(cfg.attributes, semconv.MessagingConversationIDKey.String(id))
+	}
+}
+
+// WithPayloadSize sets the messaging.message_payload_size_bytes attribute.
+func WithPayloadSize(n int) MessageOption {
+	return func(cfg *messageConfig) {
+		cfg.attributes = append(cfg.attributes, semconv.MessagingMessagePayloadSizeBytesKey.Int(n))
+	}
+}
+
+// WithMessageAttributes adds attributes to the span of a message.
+func WithMessageAttributes(attributes ...tel.KeyValue) MessageOption {
+	return func(cfg *messageConfig) {
+		cfg.attributes = append(cfg.attributes, attributes...)
+	}
+}
+
+// BatchMessageCountKey is the attribute of the span of a batch holding the
+// number of messages in it.
+const BatchMessageCountKey = tel.Key("messaging.batch.message_count")
+
+// Tracer creates the spans of the messages sent to or received from a
+// destination.
+type Tracer struct {
+	tracer     tel.Tracer
+	propagator tel.TextMapPropagator
+	attributes []tel.KeyValue
+	spanName   string
+}
+
+// New returns a Tracer.
+func New(opts ...Option) *Tracer {
+	var cfg config
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	t := &Tracer{
+		propagator: cfg.propagator,
+		attributes: cfg.attributes,
+		spanName:   cfg.destination,
+	}
+	if cfg.tracerProvider != nil {
+		t.tracer = cfg.tracerProvider.Tracer(instrumentationName)
+	} else {
+		t.tracer = tel.NewTracer(instrumentationName)
+	}
+	switch {
+	case cfg.temporary:
+		t.spanName = "(temporary)"
+	case t.spanName == "":
+		t.spanName = "(anonymous)"
+	}
+	return t
+}
+
+func (t *Tracer) textMapPropagator() tel.TextMapPropagator {
+	if t.propagator != nil {
+		return t.propagator
+	}
+	return tel.GetTextMapPropagator()
+}
+
+func (t *Tracer) spanAttributes(opts []MessageOption, extra ...tel.KeyValue) []tel.KeyValue {
+	var cfg messageConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	attrs := make([]tel.KeyValue, 0, len(t.attributes)+len(extra)+len(cfg.attributes))
+	attrs = append(attrs, t.attributes...)
+	attrs = append(attrs, extra...)
+	return append(attrs, cfg.attributes...)
+}
+
+// StartPublish starts a producer span for sending a message and sets its
+// context in the headers of the message. The caller ends the span once the
+// message is sent.
+func (t *Tracer) StartPublish(ctx context.Context, carrier MessageCarrier, opts ...MessageOption) (context.Context, tel.Span) {
+	ctx, span := t.tracer.Start(ctx, t.spanName+" send",
+		tel.WithSpanKind(tel.SpanKindProducer),
+		tel.WithAttributes(t.spanAttributes(opts)...))
+	t.textMapPropagator().Inject(ctx, textMapCarrier{carrier})
+	return ctx, span
+}
+
+// StartProcess starts a consumer span for processing a received message, as
+// a child of the context of the producer found in its headers. The
+// returned context also carries the baggage of the message. The caller ends
+// the span once the message is processed.
+func (t *Tracer) StartProcess(ctx context.Context, carrier MessageCarrier, opts ...MessageOption) (context.Context, tel.Span) {
+	ctx = t.textMapPropagator().Extract(ctx, textMapCarrier{carrier})
+	return t.tracer.Start(ctx, t.spanName+" process",
+		tel.WithSpanKind(tel.SpanKindConsumer),
+		tel.WithAttributes(t.spanAttributes(opts, semconv.MessagingOperationProcess)...))
+}
+
+// StartBatch starts a single consumer span for processing a batch of
+// received messages, as a child of ctx and linked to the context of the
+// producer of each message. operation is either "receive" or "process". The
+// caller ends the span once the batch is processed.
+func (t *Tracer) StartBatch(ctx context.Context, operation string, carriers []MessageCarrier, opts ...MessageOption) (context.Context, tel.Span) {
+	prop := t.textMapPropagator()
+	links := make([]tel.Link, 0, len(carriers))
+	for _, c := range carriers {
+		mctx := prop.Extract(context.Background(), textMapCarrier{c})
+		if link := tel.LinkFromContext(mctx); link.SpanContext.IsValid() {
+			links = append(links, link)
+		}
+	}
+	attrs := t.spanAttributes(opts,
+		semconv.MessagingOperationKey.String(operation),
+		BatchMessageCountKey.Int(len(carriers)))
+	return t.tracer.Start(ctx, t.spanName+" "+operation,
+		tel.WithSpanKind(tel.SpanKindConsumer),
+		tel.WithLinks(links...),
+		tel.WithAttributes(attrs...))
+}
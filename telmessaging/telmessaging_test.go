@@ -0,0 +1,117 @@
+package telmessaging
+
+import (
+	"context"
+	"testing"
+
+	"github.com/henvic/tel"
+	"github.com/henvic/tel/telsdk"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// queue is an in-memory queue of messages, holding their headers.
+type queue struct {
+	messages []tel.MapCarrier
+}
+
+func (q *queue) publish(ctx context.Context, t *Tracer) {
+	headers := tel.MapCarrier{}
+	_, span := t.StartPublish(ctx, headers)
+	q.messages = append(q.messages, headers)
+	span.End()
+}
+
+func newTracer(opts ...Option) (*Tracer, *tracetest.SpanRecorder) {
+	sr := tracetest.NewSpanRecorder()
+	tp := telsdk.NewTracerProvider(telsdk.WithSpanProcessor(sr))
+	opts = append([]Option{
+		WithTracerProvider(tp),
+		WithPropagator(tel.TraceContext{}),
+	}, opts...)
+	return New(opts...), sr
+}
+
+func TestPublishProcess(t *testing.T) {
+	tracer, sr := newTracer(WithSystem("memory"), WithDestination("orders"))
+	q := &queue{}
+	q.publish(context.Background(), tracer)
+
+	_, span := tracer.StartProcess(context.Background(), q.messages[0], WithMessageID("1"))
+	span.End()
+
+	spans := sr.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("got %d spans, want 2", len(spans))
+	}
+	publish, process := spans[0], spans[1]
+	if publish.Name() != "orders send" || publish.SpanKind() != tel.SpanKindProducer {
+		t.Errorf("got publish span %q of kind %v, want %q of kind producer", publish.Name(), publish.SpanKind(), "orders send")
+	}
+	if process.Name() != "orders process" || process.SpanKind() != tel.SpanKindConsumer {
+		t.Errorf("got process span %q of kind %v, want %q of kind consumer", process.Name(), process.SpanKind(), "orders process")
+	}
+	if got, want := process.Parent(), publish.SpanContext(); got.TraceID() != want.TraceID() || got.SpanID() != want.SpanID() || !got.IsRemote() {
+		t.Errorf("got process span parent %v, want the remote publish span %v", got, want)
+	}
+	attrs := map[tel.Key]string{}
+	for _, kv := range process.Attributes() {
+		attrs[kv.Key] = kv.Value.Emit()
+	}
+	for k, v := range map[tel.Key]string{
+		"messaging.system":      "memory",
+		"messaging.destination": "orders",
+		"messaging.operation":   "process",
+		"messaging.message_id":  "1",
+	} {
+		if attrs[k] != v {
+			t.Errorf("got %s=%q, want %q", k, attrs[k], v)
+		}
+	}
+}
+
+func TestBatch(t *testing.T) {
+	tracer, sr := newTracer(WithDestination("orders"))
+	q := &queue{}
+	for i := 0; i < 3; i++ {
+		q.publish(context.Background(), tracer)
+	}
+	carriers := []MessageCarrier{tel.MapCarrier{}}
+	for _, m := range q.messages {
+		carriers = append(carriers, m)
+	}
+
+	ctx, parent := tracer.StartProcess(context.Background(), tel.MapCarrier{})
+	_, span := tracer.StartBatch(ctx, "receive", carriers)
+	span.End()
+	parent.End()
+
+	spans := sr.Ended()
+	if len(spans) != 5 {
+		t.Fatalf("got %d spans, want 5", len(spans))
+	}
+	batch := spans[3]
+	if batch.Name() != "orders receive" {
+		t.Errorf("got batch span %q, want %q", batch.Name(), "orders receive")
+	}
+	if got, want := batch.Parent().SpanID(), spans[4].SpanContext().SpanID(); got != want {
+		t.Errorf("got batch span parent %v, want %v", got, want)
+	}
+	links := batch.Links()
+	if len(links) != 3 {
+		t.Fatalf("got %d links, want 3, skipping the message without a context", len(links))
+	}
+	for i, link := range links {
+		if got, want := link.SpanContext.SpanID(), spans[i].SpanContext().SpanID(); got != want {
+			t.Errorf("got link %d to span %v, want %v", i, got, want)
+		}
+	}
+	var count int64 = -1
+	for _, kv := range batch.Attributes() {
+		if kv.Key == BatchMessageCountKey {
+			count = kv.Value.AsInt64()
+		}
+	}
+	if count != 4 {
+		t.Errorf("got %s=%d, want 4", BatchMessageCountKey, count)
+	}
+}
@@ -0,0 +1,239 @@
+package telsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/henvic/tel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+// OverflowAttribute is the attribute of the series the attribute sets over
+// the cardinality limit of an instrument are folded into.
+var OverflowAttribute = attribute.Bool("otel.metric.overflow", true)
+
+// ErrCardinalityLimit is reported to tel.Handle when an instrument reaches
+// its cardinality limit.
+var ErrCardinalityLimit = errors.New("metric cardinality limit reached")
+
+// OverflowMetricName is the name of the counter of the attribute sets folded
+// into the overflow series, by instrument name.
+const OverflowMetricName = "tel.metric.overflow"
+
+type cardinalityLimitConfig struct {
+	limits        map[string]int
+	meterProvider tel.MeterProvider
+}
+
+// CardinalityLimitOption configures a CardinalityLimiter.
+type CardinalityLimitOption func(*cardinalityLimitConfig)
+
+// CardinalityLimitWithInstrumentLimit sets the limit of the instrument of the
+// name, overriding the limit of the CardinalityLimiter.
+func CardinalityLimitWithInstrumentLimit(name string, limit int) CardinalityLimitOption {
+	return func(cfg *cardinalityLimitConfig) {
+		cfg.limits[name] = limit
+	}
+}
+
+// CardinalityLimitWithMeterProvider sets the MeterProvider of the
+// tel.metric.overflow counter. If unset, the global MeterProvider is used.
+func CardinalityLimitWithMeterProvider(mp tel.MeterProvider) CardinalityLimitOption {
+	return func(cfg *cardinalityLimitConfig) {
+		cfg.meterProvider = mp
+	}
+}
+
+// CardinalityLimiter is a Checkpointer limiting the number of attribute sets
+// each instrument exports. Once an instrument reaches its limit, the
+// measurements of any other attribute set are aggregated into a single
+// series with the OverflowAttribute, which is exported in addition to the
+// limit.
+//
+// The limit applies to the attribute sets the wrapped checkpointer
+// retains, which keep their series. With memory, as with WithMemory(true),
+// the checkpointer exports every attribute set it has seen, so an
+// instrument keeps the first attribute sets up to its limit and any other
+// is folded into the overflow series for good. Without memory, an
+// attribute set frees its place an interval after it stops being updated.
+// The limit of an instrument the checkpointer exports under another
+// descriptor, as when renamed by a view, applies per interval instead.
+type CardinalityLimiter struct {
+	export.Checkpointer
+	limit int
+	cfg   cardinalityLimitConfig
+
+	// order holds the descriptors in the order of their first accumulation
+	// of the interval.
+	order   []*APIDescriptor
+	pending map[*APIDescriptor][]export.Accumulation
+	// retained holds the attribute sets the wrapped checkpointer held
+	// after the last collection, but the overflow one.
+	retained map[*APIDescriptor]map[attribute.Distinct]struct{}
+
+	counterOnce sync.Once
+	counter     syncint64.Counter
+}
+
+var _ export.Checkpointer = (*CardinalityLimiter)(nil)
+
+// NewCardinalityLimiter returns a CardinalityLimiter passing at most limit
+// attribute sets per instrument to ckpter. A limit of zero or less disables
+// the limit.
+func NewCardinalityLimiter(limit int, ckpter Checkpointer, opts ...CardinalityLimitOption) *CardinalityLimiter {
+	cfg := cardinalityLimitConfig{limits: map[string]int{}}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &CardinalityLimiter{
+		Checkpointer: ckpter,
+		limit:        limit,
+		cfg:          cfg,
+		pending:      map[*APIDescriptor][]export.Accumulation{},
+		retained:     map[*APIDescriptor]map[attribute.Distinct]struct{}{},
+	}
+}
+
+// NewCardinalityLimitFactory returns a CheckpointerFactory wrapping the
+// checkpointers of factory with a CardinalityLimiter, for use with
+// NewBasicController.
+func NewCardinalityLimitFactory(limit int, factory CheckpointerFactory, opts ...CardinalityLimitOption) CheckpointerFactory {
+	return cardinalityLimitFactory{limit: limit, factory: factory, opts: opts}
+}
+
+type cardinalityLimitFactory struct {
+	limit   int
+	factory CheckpointerFactory
+	opts    []CardinalityLimitOption
+}
+
+func (f cardinalityLimitFactory) NewCheckpointer() export.Checkpointer {
+	return NewCardinalityLimiter(f.limit, f.factory.NewCheckpointer(), f.opts...)
+}
+
+// StartCollection implements export.Checkpointer.
+func (l *CardinalityLimiter) StartCollection() {
+	l.order = l.order[:0]
+	l.pending = map[*APIDescriptor][]export.Accumulation{}
+	l.Checkpointer.StartCollection()
+}
+
+// Process implements export.Processor. The accumulations are held until
+// FinishCollection, when all the attribute sets of the interval are known.
+func (l *CardinalityLimiter) Process(accum export.Accumulation) error {
+	desc := accum.Descriptor()
+	if _, ok := l.pending[desc]; !ok {
+		l.order = append(l.order, desc)
+	}
+	l.pending[desc] = append(l.pending[desc], accum)
+	return nil
+}
+
+// FinishCollection implements export.Checkpointer.
+func (l *CardinalityLimiter) FinishCollection() error {
+	admitted := make(map[*APIDescriptor]map[attribute.Distinct]struct{}, len(l.order))
+	for _, desc := range l.order {
+		admitted[desc] = l.processLimited(desc, l.pending[desc])
+	}
+	l.pending = nil
+	err := l.Checkpointer.FinishCollection()
+	if err == nil {
+		l.retained = l.retainedSets(admitted)
+	}
+	return err
+}
+
+// retainedSets returns the attribute sets held by the wrapped checkpointer.
+// The admitted sets are used for the instruments it holds under another
+// descriptor, as when renamed by views, or if they can't be read.
+func (l *CardinalityLimiter) retainedSets(admitted map[*APIDescriptor]map[attribute.Distinct]struct{}) map[*APIDescriptor]map[attribute.Distinct]struct{} {
+	set := attribute.NewSet(OverflowAttribute)
+	overflow := set.Equivalent()
+	retained := map[*APIDescriptor]map[attribute.Distinct]struct{}{}
+	// The records aren't exported, so any temporality does.
+	err := l.Checkpointer.Reader().ForEach(aggregation.CumulativeTemporalitySelector(), func(r export.Record) error {
+		sets, ok := retained[r.Descriptor()]
+		if !ok {
+			sets = map[attribute.Distinct]struct{}{}
+			retained[r.Descriptor()] = sets
+		}
+		if d := r.Attributes().Equivalent(); d != overflow {
+			sets[d] = struct{}{}
+		}
+		return nil
+	})
+	if err != nil {
+		tel.Handle(fmt.Errorf("cannot read the attribute sets held by the checkpointer: %w", err))
+		return admitted
+	}
+	for desc, sets := range admitted {
+		if _, ok := retained[desc]; !ok {
+			retained[desc] = sets
+		}
+	}
+	return retained
+}
+
+func (l *CardinalityLimiter) processLimited(desc *APIDescriptor, accums []export.Accumulation) map[attribute.Distinct]struct{} {
+	limit := l.limit
+	if n, ok := l.cfg.limits[desc.Name()]; ok {
+		limit = n
+	}
+	// The attribute sets retained by the checkpointer count toward the
+	// limit, even if not updated in this interval.
+	admitted := make(map[attribute.Distinct]struct{}, len(l.retained[desc])+len(accums))
+	for d := range l.retained[desc] {
+		admitted[d] = struct{}{}
+	}
+	overflow := attribute.NewSet(OverflowAttribute)
+	var overflowed int64
+	for _, accum := range accums {
+		d := accum.Attributes().Equivalent()
+		if _, ok := admitted[d]; ok || limit <= 0 || len(admitted) < limit {
+			admitted[d] = struct{}{}
+			l.process(accum)
+			continue
+		}
+		overflowed++
+		l.process(export.NewAccumulation(desc, &overflow, accum.Aggregator()))
+	}
+	if overflowed > 0 {
+		tel.Handle(fmt.Errorf("%w: %s has %d attribute sets, %d more were folded into the overflow series", ErrCardinalityLimit, desc.Name(), limit, overflowed))
+		if counter := l.overflowCounter(); counter != nil {
+			counter.Add(context.Background(), overflowed, attribute.String("instrument.name", desc.Name()))
+		}
+	}
+	return admitted
+}
+
+func (l *CardinalityLimiter) process(accum export.Accumulation) {
+	if err := l.Checkpointer.Process(accum); err != nil {
+		tel.Handle(err)
+	}
+}
+
+// overflowCounter returns the tel.metric.overflow counter, created on the
+// first overflow so that the limiter can count into its own controller.
+func (l *CardinalityLimiter) overflowCounter() syncint64.Counter {
+	l.counterOnce.Do(func() {
+		mp := l.cfg.meterProvider
+		if mp == nil {
+			mp = tel.GlobalMeterProvider()
+		}
+		counter, err := mp.Meter("github.com/henvic/tel/telsdk").SyncInt64().Counter(OverflowMetricName,
+			instrument.WithDescription("Number of attribute sets folded into the overflow series of an instrument"),
+		)
+		if err != nil {
+			tel.Handle(err)
+			return
+		}
+		l.counter = counter
+	})
+	return l.counter
+}
@@ -0,0 +1,198 @@
+package telsdk
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/henvic/tel"
+	"go.opentelemetry.io/otel/metric/instrument/syncint64"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+// errorRecorder records the errors reported to tel.Handle.
+type errorRecorder struct {
+	mu   sync.Mutex
+	errs []error
+}
+
+func (r *errorRecorder) Handle(err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.errs = append(r.errs, err)
+}
+
+func (r *errorRecorder) take() []error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	errs := r.errs
+	r.errs = nil
+	return errs
+}
+
+var (
+	handlerOnce sync.Once
+	handlerMu   sync.Mutex
+	handler     *errorRecorder
+)
+
+// recordErrors records the errors reported to tel.Handle during the test.
+// The global handler is set once: the handler returned by
+// tel.GetErrorHandler before it delegates to the one set.
+func recordErrors(t *testing.T) *errorRecorder {
+	handlerOnce.Do(func() {
+		tel.SetErrorHandler(tel.ErrorHandlerFunc(func(err error) {
+			handlerMu.Lock()
+			defer handlerMu.Unlock()
+			if handler != nil {
+				handler.Handle(err)
+			}
+		}))
+	})
+	r := &errorRecorder{}
+	handlerMu.Lock()
+	handler = r
+	handlerMu.Unlock()
+	t.Cleanup(func() {
+		handlerMu.Lock()
+		handler = nil
+		handlerMu.Unlock()
+	})
+	return r
+}
+
+// newLimitedController returns a controller limiting the cardinality of its
+// instruments, and the controller of the tel.metric.overflow counter.
+func newLimitedController(limit int, memory bool, opts ...CardinalityLimitOption) (ctrl, self *BasicController) {
+	self = NewBasicController(NewFactory(NewWithInexpensiveDistribution(), aggregation.CumulativeTemporalitySelector(), WithMemory(true)),
+		WithBasicControllerCollectPeriod(0))
+	tselector := aggregation.CumulativeTemporalitySelector()
+	if !memory {
+		tselector = aggregation.DeltaTemporalitySelector()
+	}
+	opts = append([]CardinalityLimitOption{CardinalityLimitWithMeterProvider(self)}, opts...)
+	ctrl = NewBasicController(
+		NewCardinalityLimitFactory(limit, NewFactory(NewWithInexpensiveDistribution(), tselector, WithMemory(memory)), opts...),
+		WithBasicControllerCollectPeriod(0),
+	)
+	return ctrl, self
+}
+
+func newCounter(t *testing.T, ctrl *BasicController, name string) syncint64.Counter {
+	t.Helper()
+	counter, err := ctrl.Meter("m").SyncInt64().Counter(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return counter
+}
+
+func add(counter syncint64.Counter, routes ...string) {
+	for _, route := range routes {
+		counter.Add(context.Background(), 1, tel.AttributeString("route", route))
+	}
+}
+
+func TestCardinalityLimitOverflow(t *testing.T) {
+	errs := recordErrors(t)
+	ctrl, self := newLimitedController(2, true)
+	requests := newCounter(t, ctrl, "requests")
+	add(requests, "a", "b", "c", "d", "e")
+
+	var kept, overflow int
+	for _, r := range records(t, ctrl) {
+		switch {
+		case strings.Contains(r, "{otel.metric.overflow=true} 3"):
+			overflow++
+		case strings.Contains(r, "{route="):
+			kept++
+		default:
+			t.Errorf("got unexpected record %q", r)
+		}
+	}
+	if kept != 2 || overflow != 1 {
+		t.Errorf("got %d series and %d overflow series, want 2 and 1", kept, overflow)
+	}
+	if got := errs.take(); len(got) != 1 || !errors.Is(got[0], ErrCardinalityLimit) ||
+		!strings.Contains(got[0].Error(), "requests has 2 attribute sets, 3 more") {
+		t.Errorf("got errors %v, want a single %v", got, ErrCardinalityLimit)
+	}
+	assertRecords(t, records(t, self), "github.com/henvic/tel/telsdk tel.metric.overflow Sum {instrument.name=requests} 3")
+}
+
+func TestCardinalityLimitRetainedState(t *testing.T) {
+	errs := recordErrors(t)
+	ctrl, self := newLimitedController(2, true)
+	requests := newCounter(t, ctrl, "requests")
+
+	add(requests, "a", "b")
+	assertRecords(t, records(t, ctrl),
+		"m requests Sum {route=a} 1",
+		"m requests Sum {route=b} 1",
+	)
+
+	// The processor keeps exporting a and b, which keep their places even
+	// without measurements.
+	add(requests, "c")
+	assertRecords(t, records(t, ctrl),
+		"m requests Sum {otel.metric.overflow=true} 1",
+		"m requests Sum {route=a} 1",
+		"m requests Sum {route=b} 1",
+	)
+	add(requests, "a", "d")
+	assertRecords(t, records(t, ctrl),
+		"m requests Sum {otel.metric.overflow=true} 2",
+		"m requests Sum {route=a} 2",
+		"m requests Sum {route=b} 1",
+	)
+	if got := errs.take(); len(got) != 2 {
+		t.Errorf("got errors %v, want one per interval with an overflow", got)
+	}
+	assertRecords(t, records(t, self), "github.com/henvic/tel/telsdk tel.metric.overflow Sum {instrument.name=requests} 2")
+}
+
+func TestCardinalityLimitWithoutMemory(t *testing.T) {
+	recordErrors(t)
+	ctrl, _ := newLimitedController(2, false)
+	requests := newCounter(t, ctrl, "requests")
+
+	add(requests, "a", "b")
+	assertRecords(t, records(t, ctrl),
+		"m requests Sum {route=a} 1",
+		"m requests Sum {route=b} 1",
+	)
+	// a and b were exported in the last interval.
+	add(requests, "c")
+	assertRecords(t, records(t, ctrl), "m requests Sum {otel.metric.overflow=true} 1")
+	// a and b were not updated in the last interval.
+	add(requests, "c", "d", "e")
+	var kept, overflow int
+	for _, r := range records(t, ctrl) {
+		if strings.Contains(r, "{otel.metric.overflow=true} 1") {
+			overflow++
+		} else {
+			kept++
+		}
+	}
+	if kept != 2 || overflow != 1 {
+		t.Errorf("got %d series and %d overflow series, want 2 and 1", kept, overflow)
+	}
+}
+
+func TestCardinalityLimitInstrumentLimit(t *testing.T) {
+	errs := recordErrors(t)
+	ctrl, self := newLimitedController(1, true, CardinalityLimitWithInstrumentLimit("requests", 0))
+	requests := newCounter(t, ctrl, "requests")
+	add(requests, "a", "b", "c")
+	if got := records(t, ctrl); len(got) != 3 || strings.Contains(strings.Join(got, "\n"), "overflow") {
+		t.Errorf("got records %v, want the three routes without a limit", got)
+	}
+	if got := errs.take(); len(got) != 0 {
+		t.Errorf("got errors %v, want none", got)
+	}
+	if got := records(t, self); len(got) != 0 {
+		t.Errorf("got self-metrics %v, want none", got)
+	}
+}
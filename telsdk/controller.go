@@ -461,3 +461,35 @@ func NewWithInexpensiveDistribution() AggregatorSelector {
 func NewWithHistogramDistribution(options ...HistogramOption) AggregatorSelector {
 	return simple.NewWithHistogramDistribution(options...)
 }
+
+// NewWithExponentialHistogramDistribution returns a simple aggregator
+// selector that uses exponential histogram aggregators for `Histogram`
+// instruments. Unlike explicit-boundary histograms, these adjust their
+// resolution to the range of the recorded values.
+func NewWithExponentialHistogramDistribution(options ...ExponentialHistogramOption) AggregatorSelector {
+	return exponentialHistogramSelector{options: options}
+}
+
+type exponentialHistogramSelector struct {
+	options []ExponentialHistogramOption
+}
+
+func (s exponentialHistogramSelector) AggregatorFor(descriptor *APIDescriptor, aggPtrs ...*Aggregator) {
+	switch descriptor.InstrumentKind() {
+	case sdkapi.GaugeObserverInstrumentKind:
+		aggs := NewLastValue(len(aggPtrs))
+		for i := range aggPtrs {
+			*aggPtrs[i] = &aggs[i]
+		}
+	case sdkapi.HistogramInstrumentKind:
+		aggs := NewExponentialHistogram(len(aggPtrs), descriptor, s.options...)
+		for i := range aggPtrs {
+			*aggPtrs[i] = &aggs[i]
+		}
+	default:
+		aggs := NewSumAggregator(len(aggPtrs))
+		for i := range aggPtrs {
+			*aggPtrs[i] = &aggs[i]
+		}
+	}
+}
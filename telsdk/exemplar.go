@@ -0,0 +1,197 @@
+package telsdk
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/aggregator"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exemplar is a measurement recorded while a sampled span was active,
+// linking a metric to the trace it was taken in.
+type Exemplar struct {
+	Value   float64
+	Time    time.Time
+	TraceID trace.TraceID
+	SpanID  trace.SpanID
+}
+
+// IsValid reports whether the exemplar was recorded.
+func (e Exemplar) IsValid() bool {
+	return e.TraceID.IsValid() && e.SpanID.IsValid()
+}
+
+// ExemplarAggregation is implemented by the Sum and Histogram aggregations
+// of aggregators selected with NewExemplarSelector.
+type ExemplarAggregation interface {
+	// Exemplars returns the most recent exemplar of each histogram
+	// bucket, with the same length as its Counts, or a single exemplar
+	// for sums. Buckets without exemplars hold an invalid Exemplar.
+	Exemplars() []Exemplar
+}
+
+// NewExemplarSelector returns an AggregatorSelector recording exemplars in
+// the Sum and Histogram aggregators selected by selector. Measurements
+// taken with a context holding a sampled span are kept as exemplars.
+func NewExemplarSelector(selector AggregatorSelector) AggregatorSelector {
+	return exemplarSelector{selector: selector}
+}
+
+type exemplarSelector struct {
+	selector AggregatorSelector
+}
+
+func (s exemplarSelector) AggregatorFor(descriptor *APIDescriptor, aggPtrs ...*Aggregator) {
+	inner := make([]Aggregator, len(aggPtrs))
+	ptrs := make([]*Aggregator, len(aggPtrs))
+	for i := range inner {
+		ptrs[i] = &inner[i]
+	}
+	s.selector.AggregatorFor(descriptor, ptrs...)
+	for i, agg := range inner {
+		*aggPtrs[i] = wrapExemplars(agg)
+	}
+}
+
+// wrapExemplars wraps agg if its aggregation supports exemplars.
+func wrapExemplars(agg Aggregator) Aggregator {
+	if agg == nil {
+		return nil
+	}
+	switch a := agg.Aggregation().(type) {
+	case aggregation.Histogram:
+		buckets, err := a.Histogram()
+		if err != nil {
+			return agg
+		}
+		bounds := append([]float64(nil), buckets.Boundaries...)
+		return &exemplarAggregator{Aggregator: agg, boundaries: bounds, exemplars: make([]Exemplar, len(bounds)+1)}
+	case aggregation.Sum:
+		return &exemplarAggregator{Aggregator: agg, exemplars: make([]Exemplar, 1)}
+	}
+	return agg
+}
+
+type exemplarAggregator struct {
+	aggregator.Aggregator
+	boundaries []float64
+
+	mu        sync.Mutex
+	exemplars []Exemplar
+}
+
+func (a *exemplarAggregator) Update(ctx context.Context, n number.Number, descriptor *sdkapi.Descriptor) error {
+	if err := a.Aggregator.Update(ctx, n, descriptor); err != nil {
+		return err
+	}
+	sc := trace.SpanContextFromContext(ctx)
+	if !sc.IsSampled() {
+		return nil
+	}
+	v := n.CoerceToFloat64(descriptor.NumberKind())
+	// Use the same bucket as the histogram aggregator, which places
+	// values equal to a boundary in the bucket above it.
+	i := sort.Search(len(a.boundaries), func(i int) bool { return v < a.boundaries[i] })
+	a.mu.Lock()
+	a.exemplars[i] = Exemplar{Value: v, Time: time.Now(), TraceID: sc.TraceID(), SpanID: sc.SpanID()}
+	a.mu.Unlock()
+	return nil
+}
+
+func (a *exemplarAggregator) SynchronizedMove(destination Aggregator, descriptor *sdkapi.Descriptor) error {
+	if destination == nil {
+		a.mu.Lock()
+		a.reset()
+		a.mu.Unlock()
+		return a.Aggregator.SynchronizedMove(nil, descriptor)
+	}
+	o, ok := destination.(*exemplarAggregator)
+	if !ok || len(o.exemplars) != len(a.exemplars) {
+		return aggregator.NewInconsistentAggregatorError(a, destination)
+	}
+	if err := a.Aggregator.SynchronizedMove(o.Aggregator, descriptor); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	copy(o.exemplars, a.exemplars)
+	a.reset()
+	return nil
+}
+
+func (a *exemplarAggregator) reset() {
+	for i := range a.exemplars {
+		a.exemplars[i] = Exemplar{}
+	}
+}
+
+func (a *exemplarAggregator) Merge(other Aggregator, descriptor *sdkapi.Descriptor) error {
+	o, ok := other.(*exemplarAggregator)
+	if !ok || len(o.exemplars) != len(a.exemplars) {
+		return aggregator.NewInconsistentAggregatorError(a, other)
+	}
+	if err := a.Aggregator.Merge(o.Aggregator, descriptor); err != nil {
+		return err
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	// Keep the most recent exemplar of each bucket.
+	for i, e := range o.exemplars {
+		if e.IsValid() && e.Time.After(a.exemplars[i].Time) {
+			a.exemplars[i] = e
+		}
+	}
+	return nil
+}
+
+func (a *exemplarAggregator) Aggregation() aggregation.Aggregation {
+	switch agg := a.Aggregator.Aggregation().(type) {
+	case aggregation.Histogram:
+		return exemplarHistogram{histogramAggregation: agg, a: a}
+	case aggregation.Sum:
+		return exemplarSum{sumAggregation: agg, a: a}
+	default:
+		return agg
+	}
+}
+
+func (a *exemplarAggregator) Exemplars() []Exemplar {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return append([]Exemplar(nil), a.exemplars...)
+}
+
+// The aggregations are embedded through aliases, as fields named Histogram
+// and Sum would hide the methods of the same names.
+type (
+	histogramAggregation = aggregation.Histogram
+	sumAggregation       = aggregation.Sum
+)
+
+type exemplarHistogram struct {
+	histogramAggregation
+	a *exemplarAggregator
+}
+
+func (h exemplarHistogram) Exemplars() []Exemplar {
+	return h.a.Exemplars()
+}
+
+type exemplarSum struct {
+	sumAggregation
+	a *exemplarAggregator
+}
+
+func (s exemplarSum) Exemplars() []Exemplar {
+	return s.a.Exemplars()
+}
@@ -0,0 +1,386 @@
+package telsdk
+
+import (
+	"context"
+	"math"
+	"sync"
+
+	"go.opentelemetry.io/otel/sdk/metric/aggregator"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+)
+
+// ExponentialHistogramKind is the aggregation.Kind of exponential
+// histograms.
+const ExponentialHistogramKind aggregation.Kind = "ExponentialHistogram"
+
+// ExponentialHistogram is a base-2 exponential histogram: bucket index i
+// of scale s counts the values in [base**i, base**(i+1)), where
+// base = 2**(2**-s).
+type ExponentialHistogram interface {
+	aggregation.Aggregation
+	Count() (uint64, error)
+	Sum() (number.Number, error)
+	Scale() int32
+	// ZeroCount is the number of values equal to zero.
+	ZeroCount() uint64
+	// Positive holds the buckets of the positive values.
+	Positive() ExponentialBuckets
+	// Negative holds the buckets of the absolute value of the negative
+	// values.
+	Negative() ExponentialBuckets
+}
+
+// ExponentialBuckets is a range of contiguous buckets of an
+// ExponentialHistogram.
+type ExponentialBuckets interface {
+	// Offset is the bucket index of the first bucket.
+	Offset() int32
+	// Len is the number of buckets.
+	Len() uint32
+	// At returns the count of the bucket at position pos, which has
+	// index Offset()+pos.
+	At(pos uint32) uint64
+}
+
+const (
+	// DefaultExponentialHistogramMaxSize is the default maximum number of
+	// buckets for each of the positive and negative ranges.
+	DefaultExponentialHistogramMaxSize = 160
+	// minExponentialHistogramMaxSize fits the whole range of float64
+	// at MinScale.
+	minExponentialHistogramMaxSize = 4
+)
+
+type exponentialHistogramConfig struct {
+	maxSize  int32
+	maxScale int32
+}
+
+// ExponentialHistogramOption configures an exponential histogram.
+type ExponentialHistogramOption func(*exponentialHistogramConfig)
+
+// ExponentialHistogramWithMaxSize sets the maximum number of buckets of each
+// of the positive and negative ranges. When a value falls out of them, the
+// scale is reduced, merging neighbouring buckets, until it fits. The default
+// is DefaultExponentialHistogramMaxSize.
+func ExponentialHistogramWithMaxSize(size int32) ExponentialHistogramOption {
+	return func(cfg *exponentialHistogramConfig) {
+		cfg.maxSize = size
+	}
+}
+
+// ExponentialHistogramWithMaxScale sets the initial, and largest, scale of
+// the histogram, between MinScale and LogarithmMaxScale. The default is
+// LogarithmMaxScale.
+func ExponentialHistogramWithMaxScale(scale int32) ExponentialHistogramOption {
+	return func(cfg *exponentialHistogramConfig) {
+		cfg.maxScale = scale
+	}
+}
+
+// ExponentialHistogramAggregator observes events and counts them in base-2
+// exponential buckets. It implements ExponentialHistogram.
+type ExponentialHistogramAggregator struct {
+	lock     sync.Mutex
+	maxSize  int32
+	maxScale int32
+	state    exponentialState
+}
+
+var (
+	_ Aggregator           = (*ExponentialHistogramAggregator)(nil)
+	_ ExponentialHistogram = (*ExponentialHistogramAggregator)(nil)
+)
+
+// NewExponentialHistogram returns cnt new exponential histogram aggregators.
+//
+// Non-finite values are ignored.
+func NewExponentialHistogram(cnt int, desc *APIDescriptor, opts ...ExponentialHistogramOption) []ExponentialHistogramAggregator {
+	cfg := exponentialHistogramConfig{
+		maxSize:  DefaultExponentialHistogramMaxSize,
+		maxScale: LogarithmMaxScale,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	if cfg.maxSize < minExponentialHistogramMaxSize {
+		cfg.maxSize = minExponentialHistogramMaxSize
+	}
+	if cfg.maxScale > LogarithmMaxScale {
+		cfg.maxScale = LogarithmMaxScale
+	}
+	if cfg.maxScale < MinScale {
+		cfg.maxScale = MinScale
+	}
+	aggs := make([]ExponentialHistogramAggregator, cnt)
+	for i := range aggs {
+		aggs[i] = ExponentialHistogramAggregator{
+			maxSize:  cfg.maxSize,
+			maxScale: cfg.maxScale,
+			state:    newExponentialState(cfg.maxScale),
+		}
+	}
+	return aggs
+}
+
+// Aggregation returns an interface for reading the state of this aggregator.
+func (a *ExponentialHistogramAggregator) Aggregation() aggregation.Aggregation {
+	return a
+}
+
+// Kind returns ExponentialHistogramKind.
+func (a *ExponentialHistogramAggregator) Kind() aggregation.Kind {
+	return ExponentialHistogramKind
+}
+
+// Count returns the number of values in the checkpoint.
+func (a *ExponentialHistogramAggregator) Count() (uint64, error) {
+	return a.state.count, nil
+}
+
+// Sum returns the sum of values in the checkpoint.
+func (a *ExponentialHistogramAggregator) Sum() (number.Number, error) {
+	return a.state.sum, nil
+}
+
+// Scale returns the scale of the checkpoint.
+func (a *ExponentialHistogramAggregator) Scale() int32 {
+	return a.state.scale
+}
+
+// ZeroCount returns the number of zeros in the checkpoint.
+func (a *ExponentialHistogramAggregator) ZeroCount() uint64 {
+	return a.state.zeroCount
+}
+
+// Positive returns the positive buckets of the checkpoint.
+func (a *ExponentialHistogramAggregator) Positive() ExponentialBuckets {
+	return &a.state.positive
+}
+
+// Negative returns the negative buckets of the checkpoint.
+func (a *ExponentialHistogramAggregator) Negative() ExponentialBuckets {
+	return &a.state.negative
+}
+
+// SynchronizedMove saves the current state into oa and resets the current
+// state to empty, as at the largest scale.
+func (a *ExponentialHistogramAggregator) SynchronizedMove(oa Aggregator, desc *sdkapi.Descriptor) error {
+	o, _ := oa.(*ExponentialHistogramAggregator)
+	if oa != nil && o == nil {
+		return aggregator.NewInconsistentAggregatorError(a, oa)
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	if o != nil {
+		o.state = a.state
+	}
+	a.state = newExponentialState(a.maxScale)
+	return nil
+}
+
+// Update adds the recorded measurement to the current data set.
+func (a *ExponentialHistogramAggregator) Update(_ context.Context, n number.Number, desc *sdkapi.Descriptor) error {
+	kind := desc.NumberKind()
+	v := n.CoerceToFloat64(kind)
+	if math.IsInf(v, 0) || math.IsNaN(v) {
+		return nil
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.state.count++
+	a.state.sum.AddNumber(kind, n)
+	switch {
+	case v > 0:
+		a.state.record(&a.state.positive, v, a.maxSize)
+	case v < 0:
+		a.state.record(&a.state.negative, -v, a.maxSize)
+	default:
+		a.state.zeroCount++
+	}
+	return nil
+}
+
+// Merge combines two histograms that have the same buckets into a single
+// one, at the smallest of their scales.
+func (a *ExponentialHistogramAggregator) Merge(oa Aggregator, desc *sdkapi.Descriptor) error {
+	o, _ := oa.(*ExponentialHistogramAggregator)
+	if o == nil {
+		return aggregator.NewInconsistentAggregatorError(a, oa)
+	}
+	a.lock.Lock()
+	defer a.lock.Unlock()
+	a.state.merge(&o.state, desc.NumberKind(), a.maxSize)
+	return nil
+}
+
+// exponentialState is the data of an exponential histogram.
+type exponentialState struct {
+	sum       number.Number
+	count     uint64
+	zeroCount uint64
+	scale     int32
+	mapping   Mapping
+	positive  exponentialBuckets
+	negative  exponentialBuckets
+}
+
+func newExponentialState(scale int32) exponentialState {
+	return exponentialState{scale: scale, mapping: newMapping(scale)}
+}
+
+// newMapping returns the mapping of a scale between MinScale and
+// LogarithmMaxScale.
+func newMapping(scale int32) Mapping {
+	var (
+		m   Mapping
+		err error
+	)
+	if scale > 0 {
+		m, err = NewLogarithmMapping(scale)
+	} else {
+		m, err = NewExponentMapping(scale)
+	}
+	if err != nil {
+		// The scale is always checked beforehand.
+		panic(err)
+	}
+	return m
+}
+
+// record counts v, a positive value, in b.
+func (s *exponentialState) record(b *exponentialBuckets, v float64, maxSize int32) {
+	index := s.mapping.MapToIndex(v)
+	if change := s.scaleChange(b, index, index, maxSize); change > 0 {
+		s.downscale(change)
+		index >>= change
+	}
+	b.increment(index, 1)
+}
+
+// scaleChange returns how much the scale must be reduced for b to hold the
+// buckets with indexes from low to high in at most maxSize buckets.
+func (s *exponentialState) scaleChange(b *exponentialBuckets, low, high int32, maxSize int32) int32 {
+	if b.Len() > 0 {
+		if b.offset < low {
+			low = b.offset
+		}
+		if last := b.offset + int32(b.Len()) - 1; last > high {
+			high = last
+		}
+	}
+	var change int32
+	for int64(high)-int64(low) >= int64(maxSize) && s.scale-change > MinScale {
+		high >>= 1
+		low >>= 1
+		change++
+	}
+	return change
+}
+
+// downscale reduces the scale by change, merging 2**change neighbouring
+// buckets together.
+func (s *exponentialState) downscale(change int32) {
+	s.positive.downscale(change)
+	s.negative.downscale(change)
+	s.scale -= change
+	s.mapping = newMapping(s.scale)
+}
+
+// merge adds the data of o into s.
+func (s *exponentialState) merge(o *exponentialState, kind number.Kind, maxSize int32) {
+	if o.scale < s.scale {
+		s.downscale(s.scale - o.scale)
+	}
+	// The buckets of o at the scale of s, before fitting them in maxSize.
+	shift := o.scale - s.scale
+	var change int32
+	if o.positive.Len() > 0 {
+		change = s.scaleChange(&s.positive, o.positive.offset>>shift, o.positive.last()>>shift, maxSize)
+	}
+	if o.negative.Len() > 0 {
+		if c := s.scaleChange(&s.negative, o.negative.offset>>shift, o.negative.last()>>shift, maxSize); c > change {
+			change = c
+		}
+	}
+	if change > 0 {
+		s.downscale(change)
+		shift += change
+	}
+	s.positive.add(&o.positive, shift)
+	s.negative.add(&o.negative, shift)
+	s.count += o.count
+	s.zeroCount += o.zeroCount
+	s.sum.AddNumber(kind, o.sum)
+}
+
+// exponentialBuckets holds contiguous bucket counts, starting at index
+// offset.
+type exponentialBuckets struct {
+	offset int32
+	counts []uint64
+}
+
+// Offset returns the index of the first bucket.
+func (b *exponentialBuckets) Offset() int32 {
+	return b.offset
+}
+
+// Len returns the number of buckets.
+func (b *exponentialBuckets) Len() uint32 {
+	return uint32(len(b.counts))
+}
+
+// At returns the count of the bucket at position pos.
+func (b *exponentialBuckets) At(pos uint32) uint64 {
+	return b.counts[pos]
+}
+
+// last returns the index of the last bucket, or offset if there are none.
+func (b *exponentialBuckets) last() int32 {
+	if len(b.counts) == 0 {
+		return b.offset
+	}
+	return b.offset + int32(len(b.counts)) - 1
+}
+
+// increment adds n to the bucket at index, growing the range as needed.
+func (b *exponentialBuckets) increment(index int32, n uint64) {
+	switch {
+	case len(b.counts) == 0:
+		b.offset = index
+		b.counts = append(b.counts, 0)
+	case index < b.offset:
+		grown := make([]uint64, int(b.offset-index)+len(b.counts))
+		copy(grown[b.offset-index:], b.counts)
+		b.counts = grown
+		b.offset = index
+	case index > b.last():
+		b.counts = append(b.counts, make([]uint64, index-b.last())...)
+	}
+	b.counts[index-b.offset] += n
+}
+
+// downscale merges the buckets for a scale reduced by change.
+func (b *exponentialBuckets) downscale(change int32) {
+	if len(b.counts) == 0 || change <= 0 {
+		return
+	}
+	offset := b.offset >> change
+	counts := make([]uint64, (b.last()>>change)-offset+1)
+	for i, n := range b.counts {
+		counts[((b.offset+int32(i))>>change)-offset] += n
+	}
+	b.offset, b.counts = offset, counts
+}
+
+// add adds the buckets of o, at a scale larger by shift.
+func (b *exponentialBuckets) add(o *exponentialBuckets, shift int32) {
+	for i, n := range o.counts {
+		if n != 0 {
+			b.increment((o.offset+int32(i))>>shift, n)
+		}
+	}
+}
@@ -0,0 +1,331 @@
+package telsdk
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+)
+
+// ErrFanOutBusy is the error of an exporter of a FanOutExporter skipped
+// because its previous export is still running.
+var ErrFanOutBusy = errors.New("previous export still running")
+
+// FanOutError holds the errors of the exporters of a FanOutExporter or of
+// the routes of a RoutingSpanExporter that failed, prefixed by their names.
+type FanOutError []error
+
+func (e FanOutError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Is reports whether any of the errors matches target.
+func (e FanOutError) Is(target error) bool {
+	for _, err := range e {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// As finds the first of the errors matching target, as by errors.As, and
+// if one does, sets target to it and returns true.
+func (e FanOutError) As(target interface{}) bool {
+	for _, err := range e {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// Unwrap returns the errors, for the errors package of Go 1.20 and later.
+// Is and As search them with earlier versions.
+func (e FanOutError) Unwrap() []error {
+	return e
+}
+
+// FanOutStats counts the exports of an exporter of a FanOutExporter.
+type FanOutStats struct {
+	Successes uint64
+	Failures  uint64
+}
+
+type fanOutConfig struct {
+	targets             []*fanOutTarget
+	temporalitySelector aggregation.TemporalitySelector
+}
+
+// FanOutOption configures a FanOutExporter.
+type FanOutOption func(*fanOutConfig)
+
+// FanOutWithExporter adds an exporter, identified by name in the errors and
+// stats. Each export is canceled after timeout, unless it is zero.
+func FanOutWithExporter(name string, exporter Exporter, timeout time.Duration) FanOutOption {
+	return func(cfg *fanOutConfig) {
+		cfg.targets = append(cfg.targets, &fanOutTarget{name: name, exporter: exporter, timeout: timeout})
+	}
+}
+
+// FanOutWithTemporalitySelector sets the temporality computed by the
+// processor. If unset, cumulative temporality is used, with which the basic
+// processor can read the synchronous instruments with either temporality.
+func FanOutWithTemporalitySelector(selector aggregation.TemporalitySelector) FanOutOption {
+	return func(cfg *fanOutConfig) {
+		cfg.temporalitySelector = selector
+	}
+}
+
+// FanOutExporter is an Exporter passing the checkpoint to several exporters
+// concurrently, such as OTLP and stdout.
+//
+// The checkpoint is copied for each exporter with its temporality, so that
+// an exporter still running after its timeout doesn't hold the controller
+// or read the next checkpoint. While it runs, the following exports skip it
+// with ErrFanOutBusy. Exporters needing delta temporality for asynchronous
+// counters must be wrapped with a TemporalityConverter.
+type FanOutExporter struct {
+	cfg fanOutConfig
+}
+
+type fanOutTarget struct {
+	name     string
+	exporter Exporter
+	timeout  time.Duration
+
+	mu      sync.Mutex
+	running bool
+	stats   FanOutStats
+}
+
+var _ Exporter = (*FanOutExporter)(nil)
+
+// NewFanOutExporter returns a FanOutExporter for the exporters added with
+// FanOutWithExporter.
+func NewFanOutExporter(opts ...FanOutOption) *FanOutExporter {
+	cfg := fanOutConfig{temporalitySelector: aggregation.CumulativeTemporalitySelector()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &FanOutExporter{cfg: cfg}
+}
+
+// TemporalityFor returns the temporality set with
+// FanOutWithTemporalitySelector.
+func (e *FanOutExporter) TemporalityFor(desc *APIDescriptor, kind aggregation.Kind) aggregation.Temporality {
+	return e.cfg.temporalitySelector.TemporalityFor(desc, kind)
+}
+
+// Export passes the checkpoint to the exporters, returning once each of
+// them finished or timed out. The errors are returned as a FanOutError.
+func (e *FanOutExporter) Export(ctx context.Context, res *Resource, reader InstrumentationLibraryReader) error {
+	type result struct {
+		target *fanOutTarget
+		done   chan error
+		ctx    context.Context
+		cancel context.CancelFunc
+	}
+	var (
+		errs    FanOutError
+		results []result
+	)
+	for _, t := range e.cfg.targets {
+		if !t.start() {
+			t.finish(ErrFanOutBusy, true)
+			errs = append(errs, fmt.Errorf("%s: %w", t.name, ErrFanOutBusy))
+			continue
+		}
+		ckpt, err := snapshotCheckpoint(reader, t.exporter)
+		if err != nil {
+			t.finish(err, false)
+			errs = append(errs, fmt.Errorf("%s: %w", t.name, err))
+			continue
+		}
+		r := result{target: t, done: make(chan error, 1)}
+		if t.timeout > 0 {
+			r.ctx, r.cancel = context.WithTimeout(ctx, t.timeout)
+		} else {
+			r.ctx, r.cancel = context.WithCancel(ctx)
+		}
+		go func(r result) {
+			r.done <- r.target.exporter.Export(r.ctx, res, ckpt)
+		}(r)
+		results = append(results, r)
+	}
+
+	for _, r := range results {
+		var err error
+		select {
+		case err = <-r.done:
+			r.target.finish(err, false)
+			r.cancel()
+		case <-r.ctx.Done():
+			err = r.ctx.Err()
+			r.target.finish(err, true)
+			// The exporter stays busy until it returns.
+			go func(r result) {
+				<-r.done
+				r.cancel()
+				r.target.release()
+			}(r)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", r.target.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// start marks the target as running, reporting false if it already is.
+func (t *fanOutTarget) start() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.running {
+		return false
+	}
+	t.running = true
+	return true
+}
+
+// finish counts the result of an export. The target keeps running if
+// pending, until released.
+func (t *fanOutTarget) finish(err error, pending bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if err != nil {
+		t.stats.Failures++
+	} else {
+		t.stats.Successes++
+	}
+	if !pending {
+		t.running = false
+	}
+}
+
+func (t *fanOutTarget) release() {
+	t.mu.Lock()
+	t.running = false
+	t.mu.Unlock()
+}
+
+// Stats returns the counts of the exports of each exporter, by name.
+func (e *FanOutExporter) Stats() map[string]FanOutStats {
+	stats := make(map[string]FanOutStats, len(e.cfg.targets))
+	for _, t := range e.cfg.targets {
+		t.mu.Lock()
+		stats[t.name] = t.stats
+		t.mu.Unlock()
+	}
+	return stats
+}
+
+// Shutdown shuts down the exporters having a Shutdown method, such as the
+// OTLP exporters. Stop the controller using the exporter first.
+func (e *FanOutExporter) Shutdown(ctx context.Context) error {
+	var errs FanOutError
+	for _, t := range e.cfg.targets {
+		s, ok := t.exporter.(interface {
+			Shutdown(context.Context) error
+		})
+		if !ok {
+			continue
+		}
+		if err := s.Shutdown(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", t.name, err))
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// snapshotCheckpoint copies the checkpoint read with the temporality of
+// selector.
+func snapshotCheckpoint(reader InstrumentationLibraryReader, selector aggregation.TemporalitySelector) (*convertedCheckpoint, error) {
+	ckpt := &convertedCheckpoint{}
+	err := reader.ForEach(func(library InstrumentationLibrary, r Reader) error {
+		return r.ForEach(selector, func(record Record) error {
+			ckpt.add(library, export.NewRecord(
+				record.Descriptor(),
+				record.Attributes(),
+				snapshotAggregation(record.Aggregation()),
+				record.StartTime(),
+				record.EndTime(),
+			))
+			return nil
+		})
+	})
+	return ckpt, err
+}
+
+// snapshotAggregation copies the value of agg. Aggregations of unknown
+// types are returned as is.
+func snapshotAggregation(agg aggregation.Aggregation) aggregation.Aggregation {
+	if lv, ok := agg.(aggregation.LastValue); ok {
+		v, t, err := lv.LastValue()
+		if err != nil {
+			return agg
+		}
+		return lastValuePoint{value: v, timestamp: t}
+	}
+	p, ok := newTemporalityPoint(agg)
+	if !ok {
+		return agg
+	}
+	snapshot := p.aggregation()
+	if e, ok := agg.(ExemplarAggregation); ok {
+		switch s := snapshot.(type) {
+		case histogramPoint:
+			return exemplarHistogramPoint{histogramAggregation: s, exemplars: e.Exemplars()}
+		case sumPoint:
+			return exemplarSumPoint{sumAggregation: s, exemplars: e.Exemplars()}
+		}
+	}
+	return snapshot
+}
+
+type lastValuePoint struct {
+	value     number.Number
+	timestamp time.Time
+}
+
+func (p lastValuePoint) Kind() aggregation.Kind {
+	return aggregation.LastValueKind
+}
+
+func (p lastValuePoint) LastValue() (number.Number, time.Time, error) {
+	return p.value, p.timestamp, nil
+}
+
+type exemplarHistogramPoint struct {
+	histogramAggregation
+	exemplars []Exemplar
+}
+
+func (h exemplarHistogramPoint) Exemplars() []Exemplar {
+	return h.exemplars
+}
+
+type exemplarSumPoint struct {
+	sumAggregation
+	exemplars []Exemplar
+}
+
+func (s exemplarSumPoint) Exemplars() []Exemplar {
+	return s.exemplars
+}
@@ -0,0 +1,462 @@
+package telsdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/henvic/tel"
+)
+
+// LogData is a log record emitted by a Logger of a LoggerProvider, as
+// passed to the LogProcessors and LogExporters.
+type LogData struct {
+	tel.LogRecord
+	Resource               *Resource
+	InstrumentationLibrary InstrumentationLibrary
+}
+
+// LogExporter exports log records, such as to stdout or an OTLP endpoint.
+type LogExporter interface {
+	// ExportLogs exports a batch of records. It must not retain the slice.
+	ExportLogs(ctx context.Context, records []LogData) error
+	// Shutdown flushes and releases the exporter. It is called once, after
+	// the last ExportLogs.
+	Shutdown(ctx context.Context) error
+}
+
+// LogProcessor processes the records emitted by the loggers of a
+// LoggerProvider, such as by passing them to a LogExporter.
+type LogProcessor interface {
+	OnEmit(ctx context.Context, record LogData)
+	ForceFlush(ctx context.Context) error
+	Shutdown(ctx context.Context) error
+}
+
+// ErrLoggerProviderShutdown is returned when using a LoggerProvider that was
+// shut down.
+var ErrLoggerProviderShutdown = errors.New("logger provider is shut down")
+
+type loggerProviderConfig struct {
+	resource   *Resource
+	processors []LogProcessor
+}
+
+// LoggerProviderOption configures a LoggerProvider.
+type LoggerProviderOption func(*loggerProviderConfig)
+
+// WithLogResource sets the resource of the records. If unset, Default() is
+// used.
+func WithLogResource(r *Resource) LoggerProviderOption {
+	return func(cfg *loggerProviderConfig) {
+		cfg.resource = r
+	}
+}
+
+// WithLogProcessor adds a processor of the records.
+func WithLogProcessor(p LogProcessor) LoggerProviderOption {
+	return func(cfg *loggerProviderConfig) {
+		cfg.processors = append(cfg.processors, p)
+	}
+}
+
+// WithLogBatcher adds a BatchLogProcessor exporting to e.
+func WithLogBatcher(e LogExporter, opts ...BatchLogProcessorOption) LoggerProviderOption {
+	return WithLogProcessor(NewBatchLogProcessor(e, opts...))
+}
+
+// WithLogSyncer adds a SimpleLogProcessor exporting to e, which is useful
+// for testing and debugging.
+func WithLogSyncer(e LogExporter) LoggerProviderOption {
+	return WithLogProcessor(NewSimpleLogProcessor(e))
+}
+
+// LoggerProvider is a tel.LoggerProvider passing the records of its loggers
+// to its processors.
+type LoggerProvider struct {
+	resource   *Resource
+	processors []LogProcessor
+
+	mu       sync.Mutex
+	loggers  map[InstrumentationLibrary]*logger
+	shutdown bool
+}
+
+var _ tel.LoggerProvider = (*LoggerProvider)(nil)
+
+// NewLoggerProvider returns a LoggerProvider configured by the options.
+func NewLoggerProvider(opts ...LoggerProviderOption) *LoggerProvider {
+	cfg := loggerProviderConfig{resource: Default()}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &LoggerProvider{
+		resource:   cfg.resource,
+		processors: cfg.processors,
+		loggers:    map[InstrumentationLibrary]*logger{},
+	}
+}
+
+// Logger returns the logger of the instrumentation library name.
+func (p *LoggerProvider) Logger(name string, opts ...tel.LoggerOption) tel.Logger {
+	cfg := tel.NewLoggerConfig(opts...)
+	library := InstrumentationLibrary{
+		Name:      name,
+		Version:   cfg.InstrumentationVersion(),
+		SchemaURL: cfg.SchemaURL(),
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	l, ok := p.loggers[library]
+	if !ok {
+		l = &logger{provider: p, library: library}
+		p.loggers[library] = l
+	}
+	return l
+}
+
+func (p *LoggerProvider) isShutdown() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.shutdown
+}
+
+// ForceFlush flushes the processors.
+func (p *LoggerProvider) ForceFlush(ctx context.Context) error {
+	if p.isShutdown() {
+		return ErrLoggerProviderShutdown
+	}
+	for _, lp := range p.processors {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if err := lp.ForceFlush(ctx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Shutdown shuts down the processors, after which the loggers drop the
+// records.
+func (p *LoggerProvider) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	if p.shutdown {
+		p.mu.Unlock()
+		return ErrLoggerProviderShutdown
+	}
+	p.shutdown = true
+	p.mu.Unlock()
+	var first error
+	for _, lp := range p.processors {
+		if err := lp.Shutdown(ctx); err != nil && first == nil {
+			first = err
+		}
+	}
+	return first
+}
+
+type logger struct {
+	provider *LoggerProvider
+	library  InstrumentationLibrary
+}
+
+func (l *logger) Emit(ctx context.Context, record tel.LogRecord) {
+	if l.provider.isShutdown() || len(l.provider.processors) == 0 {
+		return
+	}
+	now := time.Now()
+	record.ObservedTimestamp = now
+	if record.Timestamp.IsZero() {
+		record.Timestamp = now
+	}
+	if !record.TraceID.IsValid() {
+		if sc := tel.SpanContextFromContext(ctx); sc.IsValid() {
+			record.TraceID = sc.TraceID()
+			record.SpanID = sc.SpanID()
+			record.TraceFlags = sc.TraceFlags()
+		}
+	}
+	data := LogData{
+		LogRecord:              record,
+		Resource:               l.provider.resource,
+		InstrumentationLibrary: l.library,
+	}
+	for _, lp := range l.provider.processors {
+		lp.OnEmit(ctx, data)
+	}
+}
+
+// NewSimpleLogProcessor returns a LogProcessor exporting each record
+// synchronously as it is emitted. It is useful for testing and debugging;
+// use a BatchLogProcessor otherwise.
+func NewSimpleLogProcessor(exporter LogExporter) LogProcessor {
+	return &simpleLogProcessor{exporter: exporter}
+}
+
+type simpleLogProcessor struct {
+	mu       sync.Mutex
+	exporter LogExporter
+	shutdown bool
+}
+
+func (p *simpleLogProcessor) OnEmit(ctx context.Context, record LogData) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shutdown {
+		return
+	}
+	if err := p.exporter.ExportLogs(ctx, []LogData{record}); err != nil {
+		tel.Handle(err)
+	}
+}
+
+func (p *simpleLogProcessor) ForceFlush(context.Context) error {
+	return nil
+}
+
+func (p *simpleLogProcessor) Shutdown(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.shutdown {
+		return nil
+	}
+	p.shutdown = true
+	return p.exporter.Shutdown(ctx)
+}
+
+// Defaults for BatchLogProcessorOptions.
+const (
+	DefaultLogMaxQueueSize       = 2048
+	DefaultLogScheduleDelay      = 1000 * time.Millisecond
+	DefaultLogExportTimeout      = 30000 * time.Millisecond
+	DefaultLogMaxExportBatchSize = 512
+)
+
+// BatchLogProcessorOptions is configuration settings for a
+// BatchLogProcessor.
+type BatchLogProcessorOptions struct {
+	// MaxQueueSize is the maximum number of records held before they are
+	// dropped.
+	MaxQueueSize int
+	// BatchTimeout is the maximum delay before exporting the queued
+	// records.
+	BatchTimeout time.Duration
+	// ExportTimeout is the time an export can run before it is canceled.
+	ExportTimeout time.Duration
+	// MaxExportBatchSize is the maximum number of records exported at
+	// once.
+	MaxExportBatchSize int
+	// BlockOnQueueFull makes OnEmit wait for room in the queue instead of
+	// dropping the record.
+	BlockOnQueueFull bool
+}
+
+// BatchLogProcessorOption configures a BatchLogProcessor.
+type BatchLogProcessorOption func(o *BatchLogProcessorOptions)
+
+// WithLogMaxQueueSize sets the maximum queue size of a BatchLogProcessor.
+func WithLogMaxQueueSize(size int) BatchLogProcessorOption {
+	return func(o *BatchLogProcessorOptions) {
+		o.MaxQueueSize = size
+	}
+}
+
+// WithLogMaxExportBatchSize sets the maximum export batch size of a
+// BatchLogProcessor.
+func WithLogMaxExportBatchSize(size int) BatchLogProcessorOption {
+	return func(o *BatchLogProcessorOptions) {
+		o.MaxExportBatchSize = size
+	}
+}
+
+// WithLogBatchTimeout sets the maximum delay before a BatchLogProcessor
+// exports the queued records.
+func WithLogBatchTimeout(delay time.Duration) BatchLogProcessorOption {
+	return func(o *BatchLogProcessorOptions) {
+		o.BatchTimeout = delay
+	}
+}
+
+// WithLogExportTimeout sets the time a BatchLogProcessor waits for an
+// export before canceling it.
+func WithLogExportTimeout(timeout time.Duration) BatchLogProcessorOption {
+	return func(o *BatchLogProcessorOptions) {
+		o.ExportTimeout = timeout
+	}
+}
+
+// WithLogBlocking makes a BatchLogProcessor wait for room in its queue
+// instead of dropping records.
+func WithLogBlocking() BatchLogProcessorOption {
+	return func(o *BatchLogProcessorOptions) {
+		o.BlockOnQueueFull = true
+	}
+}
+
+// NewBatchLogProcessor returns a LogProcessor queuing the records and
+// exporting them in batches, in the background.
+func NewBatchLogProcessor(exporter LogExporter, options ...BatchLogProcessorOption) LogProcessor {
+	o := BatchLogProcessorOptions{
+		MaxQueueSize:       DefaultLogMaxQueueSize,
+		BatchTimeout:       DefaultLogScheduleDelay,
+		ExportTimeout:      DefaultLogExportTimeout,
+		MaxExportBatchSize: DefaultLogMaxExportBatchSize,
+	}
+	for _, opt := range options {
+		opt(&o)
+	}
+	if o.MaxExportBatchSize > o.MaxQueueSize {
+		o.MaxExportBatchSize = o.MaxQueueSize
+	}
+	p := &batchLogProcessor{
+		exporter: exporter,
+		o:        o,
+		queue:    make(chan LogData, o.MaxQueueSize),
+		flush:    make(chan chan error),
+		stop:     make(chan struct{}),
+		stopped:  make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+type batchLogProcessor struct {
+	exporter LogExporter
+	o        BatchLogProcessorOptions
+
+	queue   chan LogData
+	flush   chan chan error
+	stop    chan struct{}
+	stopped chan struct{}
+
+	stopOnce sync.Once
+	// mu guards sending to the queue against stopping.
+	mu       sync.RWMutex
+	shutdown bool
+	batch    []LogData
+}
+
+func (p *batchLogProcessor) OnEmit(ctx context.Context, record LogData) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	if p.shutdown {
+		return
+	}
+	if p.o.BlockOnQueueFull {
+		select {
+		case p.queue <- record:
+		case <-ctx.Done():
+		}
+		return
+	}
+	select {
+	case p.queue <- record:
+	default:
+	}
+}
+
+func (p *batchLogProcessor) run() {
+	defer close(p.stopped)
+	timer := time.NewTimer(p.o.BatchTimeout)
+	defer timer.Stop()
+	for {
+		select {
+		case r := <-p.queue:
+			p.batch = append(p.batch, r)
+			if len(p.batch) >= p.o.MaxExportBatchSize {
+				p.export()
+				resetTimer(timer, p.o.BatchTimeout)
+			}
+		case <-timer.C:
+			p.export()
+			timer.Reset(p.o.BatchTimeout)
+		case done := <-p.flush:
+			done <- p.drain()
+		case <-p.stop:
+			p.drain()
+			return
+		}
+	}
+}
+
+// drain exports the queued records.
+func (p *batchLogProcessor) drain() error {
+	var first error
+	for {
+		select {
+		case r := <-p.queue:
+			p.batch = append(p.batch, r)
+			if len(p.batch) >= p.o.MaxExportBatchSize {
+				if err := p.export(); err != nil && first == nil {
+					first = err
+				}
+			}
+		default:
+			if err := p.export(); err != nil && first == nil {
+				first = err
+			}
+			return first
+		}
+	}
+}
+
+func (p *batchLogProcessor) export() error {
+	if len(p.batch) == 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), p.o.ExportTimeout)
+	defer cancel()
+	err := p.exporter.ExportLogs(ctx, p.batch)
+	if err != nil {
+		tel.Handle(err)
+	}
+	p.batch = p.batch[:0]
+	return err
+}
+
+func resetTimer(t *time.Timer, d time.Duration) {
+	if !t.Stop() {
+		select {
+		case <-t.C:
+		default:
+		}
+	}
+	t.Reset(d)
+}
+
+func (p *batchLogProcessor) ForceFlush(ctx context.Context) error {
+	done := make(chan error, 1)
+	select {
+	case p.flush <- done:
+	case <-p.stopped:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *batchLogProcessor) Shutdown(ctx context.Context) error {
+	var err error
+	p.stopOnce.Do(func() {
+		p.mu.Lock()
+		p.shutdown = true
+		p.mu.Unlock()
+		close(p.stop)
+		select {
+		case <-p.stopped:
+		case <-ctx.Done():
+			err = ctx.Err()
+			return
+		}
+		err = p.exporter.Shutdown(ctx)
+	})
+	return err
+}
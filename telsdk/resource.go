@@ -4,6 +4,7 @@ import (
 	"context"
 	"time"
 
+	"github.com/henvic/tel"
 	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/sdk/resource"
 	"go.opentelemetry.io/otel/sdk/trace"
@@ -564,6 +565,13 @@ func NewSpanLimits() SpanLimits {
 	return trace.NewSpanLimits()
 }
 
+// MarshalWithSpanLimits truncates the string values encoded by
+// tel.MarshalAttributes to the AttributeValueLengthLimit of sl, as the spans
+// would.
+func MarshalWithSpanLimits(sl SpanLimits) tel.MarshalOption {
+	return tel.MarshalWithValueLengthLimit(sl.AttributeValueLengthLimit)
+}
+
 // SpanProcessor is a processing pipeline for spans in the trace signal.
 // SpanProcessors registered with a TracerProvider and are called at the start
 // and end of a Span's lifecycle, and are called in the order they are
@@ -0,0 +1,232 @@
+package telsdk
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/henvic/tel"
+)
+
+// SpanPredicate reports whether a span matches a route of a
+// RoutingSpanExporter.
+type SpanPredicate func(ReadOnlySpan) bool
+
+// SpanMatchResourceAttribute matches the spans whose resource has the
+// attribute.
+func SpanMatchResourceAttribute(kv tel.KeyValue) SpanPredicate {
+	return func(s ReadOnlySpan) bool {
+		if s.Resource() == nil {
+			return false
+		}
+		v, ok := s.Resource().Set().Value(kv.Key)
+		return ok && v == kv.Value
+	}
+}
+
+// SpanMatchInstrumentationLibrary matches the spans of the tracers of the
+// instrumentation library name.
+func SpanMatchInstrumentationLibrary(name string) SpanPredicate {
+	return func(s ReadOnlySpan) bool {
+		return s.InstrumentationLibrary().Name == name
+	}
+}
+
+// SpanMatchKind matches the spans of any of the kinds.
+func SpanMatchKind(kinds ...tel.SpanKind) SpanPredicate {
+	return func(s ReadOnlySpan) bool {
+		for _, k := range kinds {
+			if s.SpanKind() == k {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SpanMatchAttribute matches the spans having the attribute.
+func SpanMatchAttribute(kv tel.KeyValue) SpanPredicate {
+	return func(s ReadOnlySpan) bool {
+		for _, a := range s.Attributes() {
+			if a.Key == kv.Key {
+				return a.Value == kv.Value
+			}
+		}
+		return false
+	}
+}
+
+// SpanMatchAttributeKey matches the spans having an attribute of the key,
+// whatever its value.
+func SpanMatchAttributeKey(key tel.Key) SpanPredicate {
+	return func(s ReadOnlySpan) bool {
+		for _, a := range s.Attributes() {
+			if a.Key == key {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// SpanMatchAll matches the spans matching all the predicates.
+func SpanMatchAll(predicates ...SpanPredicate) SpanPredicate {
+	return func(s ReadOnlySpan) bool {
+		for _, p := range predicates {
+			if !p(s) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+// SpanMatchAny matches the spans matching any of the predicates.
+func SpanMatchAny(predicates ...SpanPredicate) SpanPredicate {
+	return func(s ReadOnlySpan) bool {
+		for _, p := range predicates {
+			if p(s) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type spanRoute struct {
+	name      string
+	predicate SpanPredicate
+	exporter  SpanExporter
+}
+
+type routingConfig struct {
+	routes       []spanRoute
+	defaultRoute *spanRoute
+}
+
+// RoutingSpanExporterOption configures a RoutingSpanExporter.
+type RoutingSpanExporterOption func(*routingConfig)
+
+// RoutingWithRoute adds a route sending the spans matching predicate to
+// exporter, identified by name in the errors. Routes are tried in the order
+// they are added.
+func RoutingWithRoute(name string, predicate SpanPredicate, exporter SpanExporter) RoutingSpanExporterOption {
+	return func(cfg *routingConfig) {
+		cfg.routes = append(cfg.routes, spanRoute{name: name, predicate: predicate, exporter: exporter})
+	}
+}
+
+// RoutingWithDefault sets the exporter of the spans matching no route. If
+// unset, they are dropped.
+func RoutingWithDefault(exporter SpanExporter) RoutingSpanExporterOption {
+	return func(cfg *routingConfig) {
+		cfg.defaultRoute = &spanRoute{name: "default", exporter: exporter}
+	}
+}
+
+// RoutingSpanExporter is a SpanExporter sending each span to the exporter
+// of the first route it matches, such as audit spans to a separate
+// backend.
+//
+// The exporters are called concurrently, and the failure or panic of one
+// doesn't keep the others from exporting. Their errors are returned as a
+// FanOutError.
+type RoutingSpanExporter struct {
+	routes []spanRoute
+}
+
+var _ SpanExporter = (*RoutingSpanExporter)(nil)
+
+// NewRoutingSpanExporter returns a RoutingSpanExporter for the routes added
+// with RoutingWithRoute and RoutingWithDefault.
+func NewRoutingSpanExporter(opts ...RoutingSpanExporterOption) *RoutingSpanExporter {
+	var cfg routingConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	routes := cfg.routes
+	if cfg.defaultRoute != nil {
+		routes = append(routes, *cfg.defaultRoute)
+		routes[len(routes)-1].predicate = func(ReadOnlySpan) bool { return true }
+	}
+	return &RoutingSpanExporter{routes: routes}
+}
+
+// ExportSpans sends the spans to the exporters of their routes.
+func (e *RoutingSpanExporter) ExportSpans(ctx context.Context, spans []ReadOnlySpan) error {
+	batches := make([][]ReadOnlySpan, len(e.routes))
+	for _, s := range spans {
+		for i, r := range e.routes {
+			if r.predicate(s) {
+				batches[i] = append(batches[i], s)
+				break
+			}
+		}
+	}
+	return e.each(func(r spanRoute, i int) error {
+		if len(batches[i]) == 0 {
+			return nil
+		}
+		return r.exporter.ExportSpans(ctx, batches[i])
+	}, false)
+}
+
+// Shutdown shuts down the exporters of all the routes. Exporters of several
+// routes are shut down once.
+func (e *RoutingSpanExporter) Shutdown(ctx context.Context) error {
+	return e.each(func(r spanRoute, _ int) error {
+		return r.exporter.Shutdown(ctx)
+	}, true)
+}
+
+// each calls f for the routes concurrently, recovering from panics.
+func (e *RoutingSpanExporter) each(f func(r spanRoute, i int) error, unique bool) error {
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs FanOutError
+	)
+	for i, r := range e.routes {
+		if unique && e.seen(i) {
+			continue
+		}
+		wg.Add(1)
+		go func(r spanRoute, i int) {
+			defer wg.Done()
+			err := func() (err error) {
+				defer func() {
+					if v := recover(); v != nil {
+						err = fmt.Errorf("panic: %v", v)
+					}
+				}()
+				return f(r, i)
+			}()
+			if err != nil {
+				mu.Lock()
+				errs = append(errs, fmt.Errorf("%s: %w", r.name, err))
+				mu.Unlock()
+			}
+		}(r, i)
+	}
+	wg.Wait()
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// seen reports whether the exporter of route i is the exporter of a
+// previous route.
+func (e *RoutingSpanExporter) seen(i int) bool {
+	exp := e.routes[i].exporter
+	if !reflect.TypeOf(exp).Comparable() {
+		return false
+	}
+	for _, r := range e.routes[:i] {
+		if reflect.TypeOf(r.exporter) == reflect.TypeOf(exp) && r.exporter == exp {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,509 @@
+package telsdk
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	"go.opentelemetry.io/otel/sdk/metric/number"
+)
+
+// DefaultTemporalityConverterStaleness is the default time a
+// TemporalityConverter keeps the state of a series that stopped being
+// exported.
+const DefaultTemporalityConverterStaleness = 5 * time.Minute
+
+type temporalityConverterConfig struct {
+	staleness time.Duration
+}
+
+// TemporalityConverterOption configures a TemporalityConverter.
+type TemporalityConverterOption func(*temporalityConverterConfig)
+
+// TemporalityConverterWithStaleness sets how long the state of a series is
+// kept after it was last exported. If unset,
+// DefaultTemporalityConverterStaleness is used.
+func TemporalityConverterWithStaleness(d time.Duration) TemporalityConverterOption {
+	return func(cfg *temporalityConverterConfig) {
+		cfg.staleness = d
+	}
+}
+
+// TemporalityConverter is an Exporter reading the checkpoint with a source
+// temporality and converting the sums and histograms to the temporality of
+// the exporter it wraps, so that exporters with different needs can share a
+// BasicController.
+//
+// Converting cumulative to delta subtracts the last value of the series. A
+// series whose start time changed, or whose monotonic sum or counts went
+// down, was reset, and its whole value is the delta. Converting delta to
+// cumulative adds the deltas from the start time of the first one, and
+// keeps exporting the series while they are not updated, until they are
+// stale. Last values are passed unchanged.
+type TemporalityConverter struct {
+	exporter Exporter
+	source   aggregation.TemporalitySelector
+	cfg      temporalityConverterConfig
+
+	mu      sync.Mutex
+	streams map[temporalityStreamKey]*temporalityStream
+}
+
+var _ Exporter = (*TemporalityConverter)(nil)
+
+// NewTemporalityConverter returns a TemporalityConverter for exporter,
+// reading the checkpoint with the source temporality. Use the converter as
+// the TemporalitySelector of the processor, such as with NewFactory, so
+// that the processor computes the source temporality.
+//
+// As the basic processor can't compute deltas of asynchronous counters,
+// use aggregation.CumulativeTemporalitySelector as the source to export
+// them as deltas.
+func NewTemporalityConverter(exporter Exporter, source aggregation.TemporalitySelector, opts ...TemporalityConverterOption) *TemporalityConverter {
+	cfg := temporalityConverterConfig{staleness: DefaultTemporalityConverterStaleness}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	return &TemporalityConverter{
+		exporter: exporter,
+		source:   source,
+		cfg:      cfg,
+		streams:  map[temporalityStreamKey]*temporalityStream{},
+	}
+}
+
+// TemporalityFor returns the source temporality.
+func (c *TemporalityConverter) TemporalityFor(desc *APIDescriptor, kind aggregation.Kind) aggregation.Temporality {
+	return c.source.TemporalityFor(desc, kind)
+}
+
+type temporalityStreamKey struct {
+	library    InstrumentationLibrary
+	descriptor *APIDescriptor
+	attrs      attribute.Distinct
+}
+
+// temporalityStream is the state of a converted series.
+type temporalityStream struct {
+	library    InstrumentationLibrary
+	descriptor *APIDescriptor
+	attrs      *attribute.Set
+	// cumulative tells whether value is the sum of the deltas, exported
+	// until stale, or the last cumulative value.
+	cumulative bool
+	value      temporalityPoint
+	start, end time.Time
+	seen       time.Time
+	exported   bool
+}
+
+// Export converts the checkpoint and passes it to the wrapped exporter.
+func (c *TemporalityConverter) Export(ctx context.Context, res *Resource, reader InstrumentationLibraryReader) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for _, s := range c.streams {
+		s.exported = false
+	}
+	ckpt := &convertedCheckpoint{}
+	var end time.Time
+	err := reader.ForEach(func(library InstrumentationLibrary, r Reader) error {
+		return r.ForEach(c.source, func(record Record) error {
+			if record.EndTime().After(end) {
+				end = record.EndTime()
+			}
+			ckpt.add(library, c.convert(library, record, now))
+			return nil
+		})
+	})
+	if err != nil {
+		return err
+	}
+	if end.IsZero() {
+		end = now
+	}
+	for key, s := range c.streams {
+		switch {
+		case now.Sub(s.seen) > c.cfg.staleness:
+			delete(c.streams, key)
+		case s.cumulative && !s.exported:
+			ckpt.add(s.library, export.NewRecord(s.descriptor, s.attrs, s.value.clone().aggregation(), s.start, end))
+		}
+	}
+	return c.exporter.Export(ctx, res, ckpt)
+}
+
+func (c *TemporalityConverter) convert(library InstrumentationLibrary, record Record, now time.Time) Record {
+	desc := record.Descriptor()
+	kind := record.Aggregation().Kind()
+	from := c.source.TemporalityFor(desc, kind)
+	to := c.exporter.TemporalityFor(desc, kind)
+	if from == to {
+		return record
+	}
+	p, ok := newTemporalityPoint(record.Aggregation())
+	if !ok {
+		return record
+	}
+	key := temporalityStreamKey{library: library, descriptor: desc, attrs: record.Attributes().Equivalent()}
+	s, found := c.streams[key]
+	if !found {
+		s = &temporalityStream{
+			library:    library,
+			descriptor: desc,
+			attrs:      record.Attributes(),
+			cumulative: to == aggregation.CumulativeTemporality,
+		}
+		c.streams[key] = s
+	}
+	s.seen = now
+	s.exported = true
+	nkind := desc.NumberKind()
+	monotonic := desc.InstrumentKind().Monotonic()
+
+	if s.cumulative {
+		if !found || !s.value.add(p, nkind) {
+			s.value, s.start = p.clone(), record.StartTime()
+		}
+		s.end = record.EndTime()
+		return export.NewRecord(desc, record.Attributes(), s.value.clone().aggregation(), s.start, s.end)
+	}
+
+	delta, start := p, record.StartTime()
+	if found && record.StartTime().Equal(s.start) {
+		if d, ok := p.sub(s.value, nkind, monotonic); ok {
+			delta, start = d, s.end
+		}
+	}
+	s.value, s.start, s.end = p, record.StartTime(), record.EndTime()
+	return export.NewRecord(desc, record.Attributes(), delta.aggregation(), start, record.EndTime())
+}
+
+// convertedCheckpoint is an InstrumentationLibraryReader holding converted
+// records.
+type convertedCheckpoint struct {
+	libraries []*convertedReader
+}
+
+func (c *convertedCheckpoint) add(library InstrumentationLibrary, record Record) {
+	for _, r := range c.libraries {
+		if r.library == library {
+			r.records = append(r.records, record)
+			return
+		}
+	}
+	c.libraries = append(c.libraries, &convertedReader{library: library, records: []Record{record}})
+}
+
+func (c *convertedCheckpoint) ForEach(readerFunc func(InstrumentationLibrary, Reader) error) error {
+	for _, r := range c.libraries {
+		if err := readerFunc(r.library, r); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertedReader is a Reader of the converted records of a library. The
+// records are already of the temporality of the exporter, so the selector
+// passed to ForEach is ignored.
+type convertedReader struct {
+	sync.RWMutex
+	library InstrumentationLibrary
+	records []Record
+}
+
+func (r *convertedReader) ForEach(_ aggregation.TemporalitySelector, recordFunc func(Record) error) error {
+	for _, record := range r.records {
+		if err := recordFunc(record); err != nil && !errors.Is(err, aggregation.ErrNoData) {
+			return err
+		}
+	}
+	return nil
+}
+
+// temporalityPoint is the value of a sum or histogram being converted.
+type temporalityPoint struct {
+	kind        aggregation.Kind
+	sum         number.Number
+	count       uint64
+	boundaries  []float64
+	counts      []uint64
+	exponential *exponentialState
+}
+
+// newTemporalityPoint copies the value of agg, if it is a sum or a
+// histogram.
+func newTemporalityPoint(agg aggregation.Aggregation) (temporalityPoint, bool) {
+	switch agg := agg.(type) {
+	case ExponentialHistogram:
+		sum, err := agg.Sum()
+		if err != nil {
+			return temporalityPoint{}, false
+		}
+		count, err := agg.Count()
+		if err != nil {
+			return temporalityPoint{}, false
+		}
+		s := &exponentialState{sum: sum, count: count, zeroCount: agg.ZeroCount(), scale: agg.Scale()}
+		copyExponentialBuckets(&s.positive, agg.Positive())
+		copyExponentialBuckets(&s.negative, agg.Negative())
+		return temporalityPoint{kind: ExponentialHistogramKind, exponential: s}, true
+	case aggregation.Histogram:
+		sum, err := agg.Sum()
+		if err != nil {
+			return temporalityPoint{}, false
+		}
+		count, err := agg.Count()
+		if err != nil {
+			return temporalityPoint{}, false
+		}
+		buckets, err := agg.Histogram()
+		if err != nil {
+			return temporalityPoint{}, false
+		}
+		return temporalityPoint{
+			kind:       aggregation.HistogramKind,
+			sum:        sum,
+			count:      count,
+			boundaries: append([]float64(nil), buckets.Boundaries...),
+			counts:     append([]uint64(nil), buckets.Counts...),
+		}, true
+	case aggregation.Sum:
+		sum, err := agg.Sum()
+		if err != nil {
+			return temporalityPoint{}, false
+		}
+		return temporalityPoint{kind: aggregation.SumKind, sum: sum}, true
+	}
+	return temporalityPoint{}, false
+}
+
+func copyExponentialBuckets(b *exponentialBuckets, o ExponentialBuckets) {
+	b.offset = o.Offset()
+	b.counts = make([]uint64, o.Len())
+	for i := range b.counts {
+		b.counts[i] = o.At(uint32(i))
+	}
+}
+
+func (p temporalityPoint) clone() temporalityPoint {
+	p.boundaries = append([]float64(nil), p.boundaries...)
+	p.counts = append([]uint64(nil), p.counts...)
+	if p.exponential != nil {
+		s := *p.exponential
+		s.positive.counts = append([]uint64(nil), s.positive.counts...)
+		s.negative.counts = append([]uint64(nil), s.negative.counts...)
+		p.exponential = &s
+	}
+	return p
+}
+
+// add adds o to p, reporting false if they can't be added.
+func (p *temporalityPoint) add(o temporalityPoint, kind number.Kind) bool {
+	if p.kind != o.kind {
+		return false
+	}
+	switch p.kind {
+	case ExponentialHistogramKind:
+		p.exponential.merge(o.exponential, kind, DefaultExponentialHistogramMaxSize)
+		return true
+	case aggregation.HistogramKind:
+		if !equalBoundaries(p.boundaries, o.boundaries) {
+			return false
+		}
+		for i, n := range o.counts {
+			p.counts[i] += n
+		}
+		p.count += o.count
+	}
+	p.sum.AddNumber(kind, o.sum)
+	return true
+}
+
+// sub returns p minus prev, reporting false if the series was reset since
+// prev.
+func (p temporalityPoint) sub(prev temporalityPoint, kind number.Kind, monotonic bool) (temporalityPoint, bool) {
+	if p.kind != prev.kind {
+		return temporalityPoint{}, false
+	}
+	d := temporalityPoint{kind: p.kind, sum: subNumber(kind, p.sum, prev.sum)}
+	switch p.kind {
+	case ExponentialHistogramKind:
+		s, ok := subExponential(p.exponential, prev.exponential, kind)
+		if !ok {
+			return temporalityPoint{}, false
+		}
+		d.exponential = s
+		return d, true
+	case aggregation.HistogramKind:
+		if !equalBoundaries(p.boundaries, prev.boundaries) || p.count < prev.count {
+			return temporalityPoint{}, false
+		}
+		d.boundaries = p.boundaries
+		d.counts = make([]uint64, len(p.counts))
+		for i, n := range p.counts {
+			if n < prev.counts[i] {
+				return temporalityPoint{}, false
+			}
+			d.counts[i] = n - prev.counts[i]
+		}
+		d.count = p.count - prev.count
+	case aggregation.SumKind:
+		if monotonic && d.sum.IsNegative(kind) {
+			return temporalityPoint{}, false
+		}
+	}
+	return d, true
+}
+
+func (p temporalityPoint) aggregation() aggregation.Aggregation {
+	switch p.kind {
+	case ExponentialHistogramKind:
+		return exponentialHistogramPoint{p.exponential}
+	case aggregation.HistogramKind:
+		return histogramPoint{p}
+	}
+	return sumPoint{p.sum}
+}
+
+func equalBoundaries(a, b []float64) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func subNumber(kind number.Kind, a, b number.Number) number.Number {
+	if kind == number.Int64Kind {
+		return number.NewInt64Number(a.AsInt64() - b.AsInt64())
+	}
+	return number.NewFloat64Number(a.AsFloat64() - b.AsFloat64())
+}
+
+// subExponential returns a minus b at the smallest of their scales,
+// reporting false if any count of b is larger than that of a.
+func subExponential(a, b *exponentialState, kind number.Kind) (*exponentialState, bool) {
+	if a.count < b.count || a.zeroCount < b.zeroCount {
+		return nil, false
+	}
+	scale := a.scale
+	if b.scale < scale {
+		scale = b.scale
+	}
+	d := &exponentialState{
+		sum:       subNumber(kind, a.sum, b.sum),
+		count:     a.count - b.count,
+		zeroCount: a.zeroCount - b.zeroCount,
+		scale:     scale,
+	}
+	var ok bool
+	if d.positive, ok = subExponentialBuckets(&a.positive, &b.positive, a.scale-scale, b.scale-scale); !ok {
+		return nil, false
+	}
+	if d.negative, ok = subExponentialBuckets(&a.negative, &b.negative, a.scale-scale, b.scale-scale); !ok {
+		return nil, false
+	}
+	return d, true
+}
+
+// subExponentialBuckets returns a minus b, where their scales are larger
+// than the scale of the result by shiftA and shiftB.
+func subExponentialBuckets(a, b *exponentialBuckets, shiftA, shiftB int32) (exponentialBuckets, bool) {
+	var d exponentialBuckets
+	d.add(a, shiftA)
+	var o exponentialBuckets
+	o.add(b, shiftB)
+	for i, n := range o.counts {
+		index := o.offset + int32(i)
+		if n == 0 {
+			continue
+		}
+		if index < d.offset || index > d.last() || len(d.counts) == 0 || d.counts[index-d.offset] < n {
+			return exponentialBuckets{}, false
+		}
+		d.counts[index-d.offset] -= n
+	}
+	for len(d.counts) > 0 && d.counts[0] == 0 {
+		d.counts = d.counts[1:]
+		d.offset++
+	}
+	for len(d.counts) > 0 && d.counts[len(d.counts)-1] == 0 {
+		d.counts = d.counts[:len(d.counts)-1]
+	}
+	return d, true
+}
+
+type sumPoint struct {
+	sum number.Number
+}
+
+func (p sumPoint) Kind() aggregation.Kind {
+	return aggregation.SumKind
+}
+
+func (p sumPoint) Sum() (number.Number, error) {
+	return p.sum, nil
+}
+
+type histogramPoint struct {
+	p temporalityPoint
+}
+
+func (h histogramPoint) Kind() aggregation.Kind {
+	return aggregation.HistogramKind
+}
+
+func (h histogramPoint) Count() (uint64, error) {
+	return h.p.count, nil
+}
+
+func (h histogramPoint) Sum() (number.Number, error) {
+	return h.p.sum, nil
+}
+
+func (h histogramPoint) Histogram() (aggregation.Buckets, error) {
+	return aggregation.Buckets{Boundaries: h.p.boundaries, Counts: h.p.counts}, nil
+}
+
+type exponentialHistogramPoint struct {
+	s *exponentialState
+}
+
+func (h exponentialHistogramPoint) Kind() aggregation.Kind {
+	return ExponentialHistogramKind
+}
+
+func (h exponentialHistogramPoint) Count() (uint64, error) {
+	return h.s.count, nil
+}
+
+func (h exponentialHistogramPoint) Sum() (number.Number, error) {
+	return h.s.sum, nil
+}
+
+func (h exponentialHistogramPoint) Scale() int32 {
+	return h.s.scale
+}
+
+func (h exponentialHistogramPoint) ZeroCount() uint64 {
+	return h.s.zeroCount
+}
+
+func (h exponentialHistogramPoint) Positive() ExponentialBuckets {
+	return &h.s.positive
+}
+
+func (h exponentialHistogramPoint) Negative() ExponentialBuckets {
+	return &h.s.negative
+}
@@ -0,0 +1,367 @@
+package telsdk
+
+import (
+	"sync"
+
+	"github.com/henvic/tel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+	basicProcessor "go.opentelemetry.io/otel/sdk/metric/processor/basic"
+	"go.opentelemetry.io/otel/sdk/metric/sdkapi"
+)
+
+// View customizes the metric stream of the instruments it matches: their
+// name, description, aggregation and attributes.
+//
+// A View matches the instruments satisfying all of its match options, or
+// every instrument if it has none.
+type View struct {
+	instrumentName string
+	instrumentKind *InstrumentKind
+	meterName      *string
+
+	name          string
+	description   *string
+	aggregation   AggregatorSelector
+	attributeKeys map[attribute.Key]struct{}
+	filter        bool
+}
+
+// ViewOption configures a View.
+type ViewOption func(*View)
+
+// NewView returns a View configured by the options.
+func NewView(opts ...ViewOption) View {
+	var v View
+	for _, opt := range opts {
+		opt(&v)
+	}
+	return v
+}
+
+// ViewMatchInstrumentName matches the instruments whose name matches the
+// pattern, where '*' matches any sequence of characters and '?' any single
+// character, e.g. "http.server.*".
+func ViewMatchInstrumentName(pattern string) ViewOption {
+	return func(v *View) {
+		v.instrumentName = pattern
+	}
+}
+
+// ViewMatchInstrumentKind matches the instruments of the kind.
+func ViewMatchInstrumentKind(kind InstrumentKind) ViewOption {
+	return func(v *View) {
+		v.instrumentKind = &kind
+	}
+}
+
+// ViewMatchMeterName matches the instruments created by the meter of the
+// name. BasicController doesn't tell the processors the meter they
+// aggregate, so such views only apply to the meters obtained from
+// ViewFactory.MeterProvider.
+func ViewMatchMeterName(name string) ViewOption {
+	return func(v *View) {
+		v.meterName = &name
+	}
+}
+
+// ViewWithName renames the matched instruments.
+func ViewWithName(name string) ViewOption {
+	return func(v *View) {
+		v.name = name
+	}
+}
+
+// ViewWithDescription sets the description of the matched instruments.
+func ViewWithDescription(description string) ViewOption {
+	return func(v *View) {
+		v.description = &description
+	}
+}
+
+// ViewWithAggregation sets the aggregation of the matched instruments, such
+// as ViewAggregationExplicitHistogram. Any AggregatorSelector can be used.
+func ViewWithAggregation(selector AggregatorSelector) ViewOption {
+	return func(v *View) {
+		v.aggregation = selector
+	}
+}
+
+// ViewWithAttributeKeys keeps only the attributes of the keys, removing the
+// others from the measurements of the matched instruments. Measurements
+// left with the same attributes are aggregated together. Without keys, all
+// the attributes are removed.
+func ViewWithAttributeKeys(keys ...tel.Key) ViewOption {
+	return func(v *View) {
+		v.filter = true
+		v.attributeKeys = make(map[attribute.Key]struct{}, len(keys))
+		for _, k := range keys {
+			v.attributeKeys[k] = struct{}{}
+		}
+	}
+}
+
+func (v *View) matches(library InstrumentationLibrary, descriptor *APIDescriptor) bool {
+	if v.meterName != nil && *v.meterName != library.Name {
+		return false
+	}
+	if v.instrumentKind != nil && *v.instrumentKind != descriptor.InstrumentKind() {
+		return false
+	}
+	return v.instrumentName == "" || matchGlob(v.instrumentName, descriptor.Name())
+}
+
+// matchGlob reports whether name matches pattern, where '*' matches any
+// sequence of bytes and '?' any single byte.
+func matchGlob(pattern, name string) bool {
+	// star and next are the positions to resume from after the last '*'.
+	star, next := -1, 0
+	p, n := 0, 0
+	for n < len(name) {
+		switch {
+		case p < len(pattern) && (pattern[p] == '?' || pattern[p] == name[n]):
+			p++
+			n++
+		case p < len(pattern) && pattern[p] == '*':
+			star, next = p, n
+			p++
+		case star >= 0:
+			next++
+			p, n = star+1, next
+		default:
+			return false
+		}
+	}
+	for p < len(pattern) && pattern[p] == '*' {
+		p++
+	}
+	return p == len(pattern)
+}
+
+type aggregatorSelectorFunc func(descriptor *APIDescriptor, aggPtrs ...*Aggregator)
+
+func (f aggregatorSelectorFunc) AggregatorFor(descriptor *APIDescriptor, aggPtrs ...*Aggregator) {
+	f(descriptor, aggPtrs...)
+}
+
+// ViewAggregationSum aggregates the measurements into their sum.
+func ViewAggregationSum() AggregatorSelector {
+	return aggregatorSelectorFunc(func(_ *APIDescriptor, aggPtrs ...*Aggregator) {
+		aggs := NewSumAggregator(len(aggPtrs))
+		for i := range aggPtrs {
+			*aggPtrs[i] = &aggs[i]
+		}
+	})
+}
+
+// ViewAggregationLastValue keeps the last measurement.
+func ViewAggregationLastValue() AggregatorSelector {
+	return aggregatorSelectorFunc(func(_ *APIDescriptor, aggPtrs ...*Aggregator) {
+		aggs := NewLastValue(len(aggPtrs))
+		for i := range aggPtrs {
+			*aggPtrs[i] = &aggs[i]
+		}
+	})
+}
+
+// ViewAggregationExplicitHistogram aggregates the measurements into a
+// histogram with the bucket boundaries.
+func ViewAggregationExplicitHistogram(boundaries []float64) AggregatorSelector {
+	return aggregatorSelectorFunc(func(descriptor *APIDescriptor, aggPtrs ...*Aggregator) {
+		aggs := NewHistogram(len(aggPtrs), descriptor, HistogramWithExplicitBoundaries(boundaries))
+		for i := range aggPtrs {
+			*aggPtrs[i] = &aggs[i]
+		}
+	})
+}
+
+// ViewAggregationExponentialHistogram aggregates the measurements into an
+// exponential histogram.
+func ViewAggregationExponentialHistogram(opts ...ExponentialHistogramOption) AggregatorSelector {
+	return aggregatorSelectorFunc(func(descriptor *APIDescriptor, aggPtrs ...*Aggregator) {
+		aggs := NewExponentialHistogram(len(aggPtrs), descriptor, opts...)
+		for i := range aggPtrs {
+			*aggPtrs[i] = &aggs[i]
+		}
+	})
+}
+
+// ViewAggregationDrop drops the measurements, disabling the instrument.
+func ViewAggregationDrop() AggregatorSelector {
+	return aggregatorSelectorFunc(func(_ *APIDescriptor, aggPtrs ...*Aggregator) {
+		for i := range aggPtrs {
+			*aggPtrs[i] = nil
+		}
+	})
+}
+
+// ViewFactory is a CheckpointerFactory applying views to the instruments,
+// for use with NewBasicController.
+type ViewFactory struct {
+	aselector AggregatorSelector
+	tselector aggregation.TemporalitySelector
+	views     []View
+	opts      []BasicProcessorOption
+
+	// mu guards pending, the library of the meter being created through
+	// a MeterProvider, which is given to the checkpointer created for it.
+	mu      sync.Mutex
+	pending *InstrumentationLibrary
+}
+
+var _ export.CheckpointerFactory = (*ViewFactory)(nil)
+
+// NewViewFactory returns a factory of basic processors applying the views.
+// The first view matching an instrument applies to it; the instruments no
+// view matches are aggregated as chosen by aselector.
+//
+// Views renaming different instruments to the same name produce separate
+// streams of the same name, which most exporters can't tell apart. The
+// OTLP exporters merge them into a single metric, and report an error for
+// the streams of a different kind or temporality, which are dropped.
+func NewViewFactory(aselector AggregatorSelector, tselector aggregation.TemporalitySelector, views []View, opts ...BasicProcessorOption) *ViewFactory {
+	return &ViewFactory{
+		aselector: aselector,
+		tselector: tselector,
+		views:     views,
+		opts:      opts,
+	}
+}
+
+// NewCheckpointer implements export.CheckpointerFactory.
+func (f *ViewFactory) NewCheckpointer() export.Checkpointer {
+	var library InstrumentationLibrary
+	f.mu.Lock()
+	if f.pending != nil {
+		library, f.pending = *f.pending, nil
+	}
+	f.mu.Unlock()
+	p := &viewProcessor{
+		factory: f,
+		library: library,
+		streams: map[*APIDescriptor]*viewStream{},
+	}
+	p.Processor = basicProcessor.New(p, f.tselector, f.opts...)
+	return p
+}
+
+// MeterProvider returns a MeterProvider creating the meters of ctrl, which
+// must use the factory, so that views matching the meter name apply to
+// them.
+//
+// The controller creates a checkpointer for each new meter, which is given
+// the library of the meter. Meters created directly by ctrl have no name
+// for the views, and so neither do the meters of the same library obtained
+// from the MeterProvider afterwards: obtain all the meters of ctrl from it.
+func (f *ViewFactory) MeterProvider(ctrl *BasicController) tel.MeterProvider {
+	return &viewMeterProvider{factory: f, ctrl: ctrl}
+}
+
+type viewMeterProvider struct {
+	factory *ViewFactory
+	ctrl    *BasicController
+	// mu serializes creating meters, so that each pending library is
+	// given to the checkpointer of its meter.
+	mu sync.Mutex
+}
+
+func (p *viewMeterProvider) Meter(instrumentationName string, opts ...metric.MeterOption) metric.Meter {
+	cfg := metric.NewMeterConfig(opts...)
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.factory.mu.Lock()
+	p.factory.pending = &InstrumentationLibrary{
+		Name:      instrumentationName,
+		Version:   cfg.InstrumentationVersion(),
+		SchemaURL: cfg.SchemaURL(),
+	}
+	p.factory.mu.Unlock()
+	// The controller creates no checkpointer for a meter it already has.
+	defer func() {
+		p.factory.mu.Lock()
+		p.factory.pending = nil
+		p.factory.mu.Unlock()
+	}()
+	return p.ctrl.Meter(instrumentationName, opts...)
+}
+
+// viewStream is the stream an instrument is exported as.
+type viewStream struct {
+	view       *View
+	descriptor *APIDescriptor
+}
+
+// viewProcessor is a basic processor receiving the accumulations of the
+// instruments as the streams of their views. The basic processor keys its
+// state by descriptor, so each instrument has a single renamed descriptor.
+type viewProcessor struct {
+	*basicProcessor.Processor
+	factory *ViewFactory
+	library InstrumentationLibrary
+
+	mu sync.Mutex
+	// streams holds the stream of both the descriptors of the instruments
+	// and of the streams, as the basic processor selects the aggregators
+	// of the latter.
+	streams map[*APIDescriptor]*viewStream
+}
+
+var _ export.Checkpointer = (*viewProcessor)(nil)
+
+func (p *viewProcessor) stream(descriptor *APIDescriptor) *viewStream {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if s, ok := p.streams[descriptor]; ok {
+		return s
+	}
+	s := &viewStream{descriptor: descriptor}
+	for i := range p.factory.views {
+		v := &p.factory.views[i]
+		if !v.matches(p.library, descriptor) {
+			continue
+		}
+		s.view = v
+		if v.name != "" || v.description != nil {
+			name, description := descriptor.Name(), descriptor.Description()
+			if v.name != "" {
+				name = v.name
+			}
+			if v.description != nil {
+				description = *v.description
+			}
+			d := sdkapi.NewDescriptor(name, descriptor.InstrumentKind(), descriptor.NumberKind(), description, descriptor.Unit())
+			s.descriptor = &d
+		}
+		break
+	}
+	p.streams[descriptor] = s
+	p.streams[s.descriptor] = s
+	return s
+}
+
+// AggregatorFor implements export.AggregatorSelector.
+func (p *viewProcessor) AggregatorFor(descriptor *APIDescriptor, aggPtrs ...*Aggregator) {
+	s := p.stream(descriptor)
+	if s.view != nil && s.view.aggregation != nil {
+		s.view.aggregation.AggregatorFor(s.descriptor, aggPtrs...)
+		return
+	}
+	p.factory.aselector.AggregatorFor(s.descriptor, aggPtrs...)
+}
+
+// Process implements export.Processor.
+func (p *viewProcessor) Process(accum export.Accumulation) error {
+	s := p.stream(accum.Descriptor())
+	attrs := accum.Attributes()
+	if s.view != nil && s.view.filter {
+		keys := s.view.attributeKeys
+		reduced, _ := attrs.Filter(func(kv attribute.KeyValue) bool {
+			_, ok := keys[kv.Key]
+			return ok
+		})
+		attrs = &reduced
+	}
+	return p.Processor.Process(export.NewAccumulation(s.descriptor, attrs, accum.Aggregator()))
+}
@@ -0,0 +1,209 @@
+package telsdk
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/henvic/tel"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/instrument"
+	"go.opentelemetry.io/otel/sdk/metric/export"
+	"go.opentelemetry.io/otel/sdk/metric/export/aggregation"
+)
+
+func TestMatchGlob(t *testing.T) {
+	tests := []struct {
+		pattern, name string
+		want          bool
+	}{
+		{"", "", true},
+		{"", "a", false},
+		{"*", "", true},
+		{"*", "http.server.duration", true},
+		{"http.server.*", "http.server.duration", true},
+		{"http.server.*", "http.client.duration", false},
+		{"http.*.duration", "http.server.duration", true},
+		{"*.duration", "http.server.duration", true},
+		{"*.duration", "http.server.size", false},
+		{"?pc", "rpc", true},
+		{"?pc", "grpc", false},
+		{"a*b*c", "axxbyyc", true},
+		{"a*b*c", "axxbyy", false},
+		{"a*b", "abab", true},
+		{"**", "x", true},
+		{"a?", "a", false},
+	}
+	for _, tt := range tests {
+		if got := matchGlob(tt.pattern, tt.name); got != tt.want {
+			t.Errorf("matchGlob(%q, %q) = %v, want %v", tt.pattern, tt.name, got, tt.want)
+		}
+	}
+}
+
+// records returns the records collected by ctrl as lines of the form
+// "<meter> <name> <kind> <attributes> <sum>", sorted.
+func records(t *testing.T, ctrl *BasicController) []string {
+	t.Helper()
+	if err := ctrl.Collect(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	var lines []string
+	err := ctrl.ForEach(func(lib InstrumentationLibrary, r export.Reader) error {
+		return r.ForEach(aggregation.CumulativeTemporalitySelector(), func(rec export.Record) error {
+			var attrs []string
+			for _, kv := range rec.Attributes().ToSlice() {
+				attrs = append(attrs, string(kv.Key)+"="+kv.Value.Emit())
+			}
+			agg := rec.Aggregation()
+			var value string
+			if s, ok := agg.(aggregation.Sum); ok {
+				n, err := s.Sum()
+				if err != nil {
+					return err
+				}
+				value = n.Emit(rec.Descriptor().NumberKind())
+			}
+			lines = append(lines, fmt.Sprintf("%s %s %s {%s} %s",
+				lib.Name, rec.Descriptor().Name(), agg.Kind(), strings.Join(attrs, ","), value))
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(lines)
+	return lines
+}
+
+func assertRecords(t *testing.T, got []string, want ...string) {
+	t.Helper()
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got records\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func newViewController(views ...View) (*BasicController, *ViewFactory) {
+	f := NewViewFactory(NewWithInexpensiveDistribution(), aggregation.CumulativeTemporalitySelector(), views, WithMemory(true))
+	return NewBasicController(f, WithBasicControllerCollectPeriod(0)), f
+}
+
+func TestViewRename(t *testing.T) {
+	ctrl, _ := newViewController(
+		NewView(ViewMatchInstrumentName("http.*"), ViewWithName("requests"), ViewWithDescription("Requests.")),
+		NewView(ViewMatchInstrumentName("*"), ViewMatchInstrumentKind(HistogramInstrumentKind), ViewWithAggregation(ViewAggregationSum())),
+	)
+	meter := ctrl.Meter("m")
+	counter, err := meter.SyncInt64().Counter("http.requests", instrument.WithDescription("HTTP requests."))
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist, err := meter.SyncInt64().Histogram("size")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := meter.SyncInt64().Counter("rpc.requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	counter.Add(ctx, 2)
+	hist.Record(ctx, 3)
+	hist.Record(ctx, 4)
+	other.Add(ctx, 1)
+
+	assertRecords(t, records(t, ctrl),
+		"m requests Sum {} 2",
+		"m rpc.requests Sum {} 1",
+		"m size Sum {} 7",
+	)
+	err = ctrl.ForEach(func(_ InstrumentationLibrary, r export.Reader) error {
+		return r.ForEach(aggregation.CumulativeTemporalitySelector(), func(rec export.Record) error {
+			if d := rec.Descriptor(); d.Name() == "requests" && d.Description() != "Requests." {
+				t.Errorf("got description %q, want the one of the view", d.Description())
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestViewAttributeKeys(t *testing.T) {
+	ctrl, _ := newViewController(
+		NewView(ViewMatchInstrumentName("requests"), ViewWithAttributeKeys("route")),
+		NewView(ViewMatchInstrumentName("errors"), ViewWithAttributeKeys()),
+	)
+	meter := ctrl.Meter("m")
+	requests, err := meter.SyncInt64().Counter("requests")
+	if err != nil {
+		t.Fatal(err)
+	}
+	errs, err := meter.SyncInt64().Counter("errors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	requests.Add(ctx, 1, tel.AttributeString("route", "/a"), tel.AttributeString("user", "1"))
+	requests.Add(ctx, 2, tel.AttributeString("route", "/a"), tel.AttributeString("user", "2"))
+	requests.Add(ctx, 4, tel.AttributeString("route", "/b"))
+	errs.Add(ctx, 1, tel.AttributeString("code", "500"))
+	errs.Add(ctx, 1, tel.AttributeString("code", "503"))
+
+	assertRecords(t, records(t, ctrl),
+		"m errors Sum {} 2",
+		"m requests Sum {route=/a} 3",
+		"m requests Sum {route=/b} 4",
+	)
+}
+
+func TestViewDrop(t *testing.T) {
+	ctrl, _ := newViewController(NewView(ViewMatchInstrumentName("debug.*"), ViewWithAggregation(ViewAggregationDrop())))
+	meter := ctrl.Meter("m")
+	dropped, err := meter.SyncInt64().Counter("debug.calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	kept, err := meter.SyncInt64().Counter("calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	dropped.Add(context.Background(), 1)
+	kept.Add(context.Background(), 1)
+	assertRecords(t, records(t, ctrl), "m calls Sum {} 1")
+}
+
+func TestViewMatchMeterName(t *testing.T) {
+	ctrl, f := newViewController(NewView(ViewMatchMeterName("db"), ViewWithName("db.calls")))
+	mp := f.MeterProvider(ctrl)
+	for _, name := range []string{"db", "http", "db"} {
+		counter, err := mp.Meter(name).SyncInt64().Counter("calls")
+		if err != nil {
+			t.Fatal(err)
+		}
+		counter.Add(context.Background(), 1)
+	}
+	// A meter of a different version is a different library.
+	counter, err := mp.Meter("db", metric.WithInstrumentationVersion("v2")).SyncInt64().Counter("calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 1)
+	// Meters created directly by the controller have no name for the
+	// views.
+	counter, err = ctrl.Meter("db", metric.WithInstrumentationVersion("v3")).SyncInt64().Counter("calls")
+	if err != nil {
+		t.Fatal(err)
+	}
+	counter.Add(context.Background(), 1)
+
+	assertRecords(t, records(t, ctrl),
+		"db calls Sum {} 1",
+		"db db.calls Sum {} 1",
+		"db db.calls Sum {} 2",
+		"http calls Sum {} 1",
+	)
+}